@@ -16,7 +16,8 @@
  *     "title":   "SIGNATURE REQUIRED",
  *     "message": "Action: bash",
  *     "type":    "permission",
- *     "chat":    "abc123"
+ *     "chat":    "abc123",
+ *     "priority": "low|default|high|urgent"   (optional, defaults to high)
  *   }
  *
  * Notification types (drives Flutter navigation):
@@ -178,7 +179,8 @@ async function checkAndIncrementQuota(userId, env) {
 // ---------------------------------------------------------------------------
 
 async function sendFCM(payload, env) {
-	const { token, title, message, type, chat } = payload;
+	const { token, title, message, type, chat, priority } = payload;
+	const urgency = priority || 'high';
 
 	const accessToken = await getAccessToken(env);
 
@@ -191,17 +193,19 @@ async function sendFCM(payload, env) {
 			},
 			data: {
 				type: type || 'general',
+				priority: urgency,
 				...(chat && { chat }),
 				click_url: chat ? `pocketcoder://chat/${chat}` : 'pocketcoder://',
 			},
 			android: {
-				priority: 'high',
+				priority: urgency === 'high' || urgency === 'urgent' ? 'high' : 'normal',
 			},
 			apns: {
 				payload: {
 					aps: {
 						sound: 'default',
 						'mutable-content': 1,
+						...(urgency === 'urgent' && { 'interruption-level': 'time-sensitive' }),
 					},
 				},
 			},
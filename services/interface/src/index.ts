@@ -306,6 +306,8 @@ async function handleUserMessage(input: any) {
 }
 
 async function handlePermissionReply(record: any) {
+    // Requests keyed only by call_id came from another integration, not OpenCode
+    if (!record.ai_engine_permission_id) return;
     console.log(`[Interface] Replying to permission: ${record.ai_engine_permission_id} -> ${record.status}`);
     const response: 'always' | 'reject' = record.status === 'authorized' ? 'always' : 'reject';
 
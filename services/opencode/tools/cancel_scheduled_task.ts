@@ -39,11 +39,11 @@ async function getAgentToken(): Promise<string> {
 }
 
 export default tool({
-  description: "Cancel (disable) a scheduled task by its ID. The task can be re-enabled from the PocketCoder dashboard. The user will be asked to approve this action.",
+  description: "Cancel (disable) a scheduled task by its ID. The task can be re-enabled with resume_scheduled_task or from the PocketCoder dashboard. The user will be asked to approve this action.",
   args: {
     task_id: tool.schema.string().describe("The ID of the scheduled task to cancel"),
   },
-  async execute(args) {
+  async execute(args, context) {
     const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
     const token = await getAgentToken()
 
@@ -55,6 +55,7 @@ export default tool({
       },
       body: JSON.stringify({
         task_id: args.task_id,
+        session_id: context.sessionID,
       }),
     })
 
@@ -64,6 +65,6 @@ export default tool({
     }
 
     const data = await resp.json()
-    return `Cancelled scheduled task '${data.name}'. It can be re-enabled from the PocketCoder dashboard.`
+    return `Cancelled scheduled task '${data.name}'. It can be re-enabled with resume_scheduled_task.`
   },
 })
@@ -0,0 +1,70 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Delete Scheduled Task Tool. Permanently removes a cron job and its run history.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Permanently delete a scheduled task by its ID, together with its run history. This cannot be undone; use pause_scheduled_task to only stop it. The user will be asked to approve this action.",
+  args: {
+    task_id: tool.schema.string().describe("The ID of the scheduled task to delete"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/delete_scheduled_task`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "Authorization": `Bearer ${token}`,
+      },
+      body: JSON.stringify({
+        task_id: args.task_id,
+        session_id: context.sessionID,
+      }),
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Failed to delete task: ${err}`
+    }
+
+    const data = await resp.json()
+    return `Deleted scheduled task '${data.name}'.`
+  },
+})
@@ -65,7 +65,8 @@ export default tool({
     const lines = tasks.map((t: any) => {
       const status = t.enabled ? "ACTIVE" : "DISABLED"
       const lastRun = t.last_executed ? `Last run: ${t.last_executed} (${t.last_status || "unknown"})` : "Never run"
-      return `- [${status}] ${t.name} (${t.cron_expression}) — ID: ${t.id}\n  Prompt: ${t.prompt}\n  ${lastRun}`
+      const nextRun = t.next_run ? `\n  Next run: ${t.next_run.local} (${t.next_run.utc})` : ""
+      return `- [${status}] ${t.name} (${t.cron_expression}, ${t.timezone}) — ID: ${t.id}\n  Prompt: ${t.prompt}\n  ${lastRun}${nextRun}`
     })
 
     return `Scheduled tasks:\n\n${lines.join("\n\n")}`
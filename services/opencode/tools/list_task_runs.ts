@@ -0,0 +1,81 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Task Runs Tool. Shows the run history of a scheduled task so Poco can report how it has been doing.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Show the recent runs of a scheduled task: when each fired, whether the agent's reply completed or failed, and how long it took. Use list_scheduled_tasks to find the task ID.",
+  args: {
+    task_id: tool.schema.string().describe("The ID of the scheduled task"),
+    limit: tool.schema.number().optional().describe("How many recent runs to show (default 10)"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const query = new URLSearchParams({
+      task_id: args.task_id,
+      session_id: context.sessionID,
+      limit: String(args.limit || 10),
+    })
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/scheduled_task_runs?${query}`, {
+      headers: {
+        "Authorization": `Bearer ${token}`,
+      },
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Failed to list task runs: ${err}`
+    }
+
+    const data = await resp.json()
+    if (!data.runs || data.runs.length === 0) {
+      return `'${data.name}' has not run yet.`
+    }
+
+    const s = data.summary
+    const lines = data.runs.map((r: any) => {
+      const took = r.finished_at ? ` in ${Math.round(r.duration_ms / 1000)}s` : ""
+      const err = r.error ? ` — ${r.error}` : ""
+      return `- ${r.triggered_at_local} [${r.status.toUpperCase()}]${took}${err}`
+    })
+
+    return `Last ${s.runs} run(s) of '${data.name}' (${data.timezone}): ${s.completed} completed, ${s.failed} failed, ${s.aborted} aborted, ${s.skipped} skipped, ${s.queued} queued, ${s.running} running.\n\n${lines.join("\n")}`
+  },
+})
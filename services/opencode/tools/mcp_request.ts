@@ -50,6 +50,7 @@ export default tool({
 
     let image = ""
     let configSchema: Record<string, string> = {}
+    let tools: { name: string; description?: string; read_only?: boolean }[] = []
 
     // 1. Auto-Research: Query the official catalog for technical metadata
     try {
@@ -98,6 +99,17 @@ export default tool({
               }
             })
           }
+
+          // Extract the tool list, recorded on approval for per-tool rules
+          if (Array.isArray(serverEntry.tools)) {
+            tools = serverEntry.tools
+              .filter((t: any) => t && t.name)
+              .map((t: any) => ({
+                name: t.name,
+                description: t.description || undefined,
+                read_only: t.annotations?.readOnlyHint === true || undefined,
+              }))
+          }
         } else {
           console.warn(`⚠️ [mcp_request] Server '${args.server_name}' not found in catalog.`)
         }
@@ -120,6 +132,7 @@ export default tool({
         session_id: context.sessionID,
         image: image,
         config_schema: configSchema,
+        tools: tools,
       }),
     })
 
@@ -138,6 +151,9 @@ export default tool({
     if (Object.keys(configSchema).length > 0) {
       result += ` Identified required configuration: ${Object.keys(configSchema).join(", ")}.`
     }
+    if (tools.length > 0) {
+      result += ` Exposes ${tools.length} tools.`
+    }
     return result + " Waiting for user approval and configuration entry in the PocketCoder dashboard."
   },
 })
\ No newline at end of file
@@ -0,0 +1,70 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Pause Scheduled Task Tool. Stops a cron job from running until it is resumed.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Pause a scheduled task by its ID so it stops running on schedule. Its settings and run history are kept, and resume_scheduled_task turns it back on. Use list_scheduled_tasks to find the task ID.",
+  args: {
+    task_id: tool.schema.string().describe("The ID of the scheduled task to pause"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/pause_scheduled_task`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "Authorization": `Bearer ${token}`,
+      },
+      body: JSON.stringify({
+        task_id: args.task_id,
+        session_id: context.sessionID,
+      }),
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Failed to pause task: ${err}`
+    }
+
+    const data = await resp.json()
+    return `Paused scheduled task '${data.name}'. Use resume_scheduled_task to turn it back on.`
+  },
+})
@@ -0,0 +1,73 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Preview Schedule Tool. Shows the next fire times of a cron expression before Poco schedules it.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Preview a cron expression without scheduling anything. Returns the normalized expression and its next fire times, or the reason it is invalid. Use this to confirm a schedule with the user before calling schedule_task.",
+  args: {
+    cron_expression: tool.schema.string().describe("Cron expression to check (e.g., '0 9 * * 1-5' for every weekday at 9am)"),
+    timezone: tool.schema.string().optional().describe("IANA timezone to read the expression in (e.g., 'Europe/Berlin'). Defaults to the user's preferred timezone, or UTC"),
+    count: tool.schema.number().optional().describe("How many upcoming runs to list (default 5, max 50)"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const query = new URLSearchParams({
+      cron_expression: args.cron_expression,
+      count: String(args.count || 5),
+      timezone: args.timezone || "",
+      session_id: context.sessionID,
+    })
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/schedule_preview?${query}`, {
+      headers: {
+        "Authorization": `Bearer ${token}`,
+      },
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Invalid schedule: ${err}`
+    }
+
+    const data = await resp.json()
+    const runs = (data.next_runs || []).map((t: any) => `  - ${t.local} (${t.utc})`).join("\n")
+    return `'${data.cron_expression}' next runs (${data.timezone}):\n${runs}`
+  },
+})
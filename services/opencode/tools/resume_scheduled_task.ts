@@ -0,0 +1,71 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Resume Scheduled Task Tool. Re-enables a paused cron job.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Resume a paused or cancelled scheduled task by its ID so it runs on its schedule again. Use list_scheduled_tasks to find the task ID.",
+  args: {
+    task_id: tool.schema.string().describe("The ID of the scheduled task to resume"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/resume_scheduled_task`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "Authorization": `Bearer ${token}`,
+      },
+      body: JSON.stringify({
+        task_id: args.task_id,
+        session_id: context.sessionID,
+      }),
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Failed to resume task: ${err}`
+    }
+
+    const data = await resp.json()
+    const runs = (data.next_runs || []).map((t: any) => `  - ${t.local} (${t.utc})`).join("\n")
+    return `Resumed scheduled task '${data.name}'.\nNext runs:\n${runs}`
+  },
+})
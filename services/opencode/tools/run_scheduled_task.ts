@@ -0,0 +1,73 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Run Scheduled Task Tool. Runs a cron job immediately to test it.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Run a scheduled task right now, even if it is paused, to test it without waiting for its schedule. The run is recorded as a manual trigger; if the task is still busy, its overlap policy decides whether the run is skipped, queued, or moved to a new chat. Use list_task_runs to follow the result. The user will be asked to approve this action.",
+  args: {
+    task_id: tool.schema.string().describe("The ID of the scheduled task to run"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/run_scheduled_task`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "Authorization": `Bearer ${token}`,
+      },
+      body: JSON.stringify({
+        task_id: args.task_id,
+        session_id: context.sessionID,
+      }),
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Failed to run task: ${err}`
+    }
+
+    const data = await resp.json()
+    const run = data.run
+    const where = run.chat ? ` in chat ${run.chat}` : ""
+    const why = run.error ? ` (${run.error})` : ""
+    return `Triggered '${data.name}': run ${run.id} is ${run.status}${where}${why}. Use list_task_runs to follow it.`
+  },
+})
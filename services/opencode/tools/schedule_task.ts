@@ -42,7 +42,9 @@ export default tool({
   description: "Schedule a recurring task. Creates a cron job that will execute a prompt on a schedule. The user will be asked to approve this action.",
   args: {
     task_name: tool.schema.string().describe("A short name for the scheduled task (e.g., 'Nightly Tests', 'PR Review Reminder')"),
-    cron_expression: tool.schema.string().describe("Standard cron expression for the schedule (e.g., '0 9 * * 1' for every Monday at 9am)"),
+    cron_expression: tool.schema.string().describe("Standard cron expression for the schedule, evaluated in the task's timezone (e.g., '0 9 * * 1' for every Monday at 9am). Use preview_schedule to confirm it first"),
+    overlap_policy: tool.schema.string().optional().describe("What to do if the task fires while its previous run (or the linked chat) is still busy: 'skip', 'queue' to run it once the previous run finishes, or 'new_chat' to run it in a fresh chat. Defaults to 'new_chat' for session_mode 'new' and 'skip' for 'existing'"),
+    timezone: tool.schema.string().optional().describe("IANA timezone the schedule is read in (e.g., 'Europe/Berlin'). Defaults to the user's preferred timezone, or UTC"),
     prompt: tool.schema.string().describe("The prompt/instruction to execute on each run"),
     session_mode: tool.schema.string().optional().describe("'new' to create a fresh chat each run (default), or 'existing' to reuse the current chat"),
     description: tool.schema.string().optional().describe("Optional longer description of what this task does"),
@@ -64,6 +66,8 @@ export default tool({
         session_mode: args.session_mode || "new",
         description: args.description || "",
         session_id: context.sessionID,
+        timezone: args.timezone || "",
+        overlap_policy: args.overlap_policy || "",
       }),
     })
 
@@ -73,6 +77,7 @@ export default tool({
     }
 
     const data = await resp.json()
-    return `Scheduled '${data.name}' (${data.cron_expression}). ID: ${data.id}. The task is now active and will run on schedule.`
+    const runs = (data.next_runs || []).map((t: any) => `  - ${t.local} (${t.utc})`).join("\n")
+    return `Scheduled '${data.name}' (${data.cron_expression}, ${data.timezone}). ID: ${data.id}. The task is now active and will run on schedule.\nNext runs:\n${runs}`
   },
 })
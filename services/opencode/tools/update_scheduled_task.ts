@@ -0,0 +1,86 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Update Scheduled Task Tool. Changes the schedule, prompt, or settings of a cron job.
+import { tool } from "@opencode-ai/plugin"
+
+let cachedToken: string | null = null
+
+async function getAgentToken(): Promise<string> {
+  if (cachedToken) return cachedToken
+  const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+  const resp = await fetch(`${pbUrl}/api/collections/users/auth-with-password`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      identity: process.env.AGENT_EMAIL,
+      password: process.env.AGENT_PASSWORD,
+    }),
+  })
+  if (!resp.ok) throw new Error(`Agent auth failed: ${resp.status}`)
+  const data = await resp.json()
+  cachedToken = data.token
+  return cachedToken!
+}
+
+export default tool({
+  description: "Change a scheduled task by its ID. Only the fields you pass are changed; the new schedule is validated and takes effect immediately. Use list_scheduled_tasks to find the task ID and preview_schedule to confirm a new expression. The user will be asked to approve this action.",
+  args: {
+    task_id: tool.schema.string().describe("The ID of the scheduled task to change"),
+    task_name: tool.schema.string().optional().describe("A new name for the task"),
+    cron_expression: tool.schema.string().optional().describe("A new cron expression, evaluated in the task's timezone"),
+    timezone: tool.schema.string().optional().describe("A new IANA timezone for the schedule (e.g., 'Europe/Berlin')"),
+    prompt: tool.schema.string().optional().describe("A new prompt/instruction to execute on each run"),
+    session_mode: tool.schema.string().optional().describe("'new' to create a fresh chat each run, or 'existing' to run in the current chat"),
+    overlap_policy: tool.schema.string().optional().describe("'skip', 'queue' or 'new_chat': what to do if the task fires while its previous run is still busy"),
+    description: tool.schema.string().optional().describe("A new description of what this task does"),
+  },
+  async execute(args, context) {
+    const pbUrl = process.env.POCKETBASE_URL || "http://pocketbase:8090"
+    const token = await getAgentToken()
+
+    const resp = await fetch(`${pbUrl}/api/pocketcoder/update_scheduled_task`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        "Authorization": `Bearer ${token}`,
+      },
+      body: JSON.stringify({
+        task_id: args.task_id,
+        session_id: context.sessionID,
+        name: args.task_name,
+        cron_expression: args.cron_expression,
+        timezone: args.timezone,
+        prompt: args.prompt,
+        session_mode: args.session_mode,
+        overlap_policy: args.overlap_policy,
+        description: args.description,
+      }),
+    })
+
+    if (!resp.ok) {
+      const err = await resp.text()
+      return `Failed to update task: ${err}`
+    }
+
+    const data = await resp.json()
+    const state = data.enabled ? "" : " The task is paused; use resume_scheduled_task to turn it back on."
+    const runs = (data.next_runs || []).map((t: any) => `  - ${t.local} (${t.utc})`).join("\n")
+    return `Updated '${data.name}' (${data.cron_expression}, ${data.timezone}, ${data.session_mode} chat, overlap: ${data.overlap_policy}).${state}\nNext runs:\n${runs}`
+  },
+})
@@ -16,16 +16,28 @@ You should have received a copy of the GNU Affero General Public License
 along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
-// @pocketcoder-core: Cron API. Endpoints for Poco to schedule, list, and cancel cron jobs.
+// @pocketcoder-core: Cron API. Endpoints for Poco to schedule, preview, and list cron jobs and their runs.
 package api
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/schedule"
+)
+
+const (
+	// defaultPreviewRuns is how many upcoming fire times a schedule preview lists.
+	defaultPreviewRuns = 5
+	defaultRunHistory  = 10
+	maxRunHistory      = 200
 )
 
 // RegisterCronApi registers the cron task management endpoints.
@@ -47,6 +59,9 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 			SessionMode    string `json:"session_mode"`
 			Description    string `json:"description"`
 			SessionID      string `json:"session_id"`
+			Timezone       string `json:"timezone"`
+			OverlapPolicy  string `json:"overlap_policy"`
+			Preview        int    `json:"preview"`
 		}
 		if err := re.BindBody(&input); err != nil {
 			return re.JSON(400, map[string]string{"error": "Invalid request body"})
@@ -54,6 +69,10 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 		if input.Name == "" || input.CronExpression == "" || input.Prompt == "" {
 			return re.JSON(400, map[string]string{"error": "name, cron_expression, and prompt are required"})
 		}
+		sched, err := schedule.Parse(input.CronExpression)
+		if err != nil {
+			return re.JSON(400, map[string]string{"error": err.Error()})
+		}
 		if input.SessionMode == "" {
 			input.SessionMode = "new"
 		}
@@ -63,6 +82,14 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 		if input.SessionID == "" {
 			return re.JSON(400, map[string]string{"error": "session_id is required"})
 		}
+		if input.OverlapPolicy == "" {
+			input.OverlapPolicy = hooks.DefaultOverlapPolicy(input.SessionMode)
+		}
+		switch input.OverlapPolicy {
+		case hooks.OverlapSkip, hooks.OverlapQueue, hooks.OverlapNewChat:
+		default:
+			return re.JSON(400, map[string]string{"error": "overlap_policy must be 'skip', 'queue' or 'new_chat'"})
+		}
 
 		// Resolve the human user from the session_id via the chats collection
 		humanUserID, chatID, err := resolveHumanUser(app, input.SessionID)
@@ -71,6 +98,15 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 			return re.JSON(400, map[string]string{"error": "Could not resolve user from session"})
 		}
 
+		// Without an explicit timezone the job follows the user's preference
+		if input.Timezone == "" {
+			input.Timezone = hooks.UserTimezone(app, humanUserID)
+		}
+		loc, err := time.LoadLocation(input.Timezone)
+		if err != nil {
+			return re.JSON(400, map[string]string{"error": fmt.Sprintf("invalid timezone %q (use an IANA name such as Europe/Berlin)", input.Timezone)})
+		}
+
 		// Create the cron_jobs record
 		collection, err := app.FindCollectionByNameOrId("cron_jobs")
 		if err != nil {
@@ -80,9 +116,11 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 
 		record := core.NewRecord(collection)
 		record.Set("name", input.Name)
-		record.Set("cron_expression", input.CronExpression)
+		record.Set("cron_expression", sched.String())
+		record.Set("timezone", loc.String())
 		record.Set("prompt", input.Prompt)
 		record.Set("session_mode", input.SessionMode)
+		record.Set("overlap_policy", input.OverlapPolicy)
 		record.Set("description", input.Description)
 		record.Set("user", humanUserID)
 		record.Set("enabled", true)
@@ -98,11 +136,57 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 		}
 
 		log.Printf("⏰ [CronAPI] Created cron job '%s' for user %s", input.Name, humanUserID)
+		if input.Preview <= 0 {
+			input.Preview = defaultPreviewRuns
+		}
 		return re.JSON(200, map[string]any{
 			"id":              record.Id,
 			"name":            input.Name,
-			"cron_expression": input.CronExpression,
+			"cron_expression": sched.String(),
+			"timezone":        loc.String(),
 			"status":          "scheduled",
+			"next_runs":       previewRuns(sched, loc, input.Preview),
+		})
+	}).Bind(apis.RequireAuth())
+
+	// GET /api/pocketcoder/schedule_preview?cron_expression=0+9+*+*+1-5&timezone=&session_id=&count=5
+	// Parses an expression without scheduling anything, so Poco can confirm
+	// the fire times with the user first. Without a timezone, the session
+	// user's preference applies.
+	e.Router.GET("/api/pocketcoder/schedule_preview", func(re *core.RequestEvent) error {
+		if re.Auth == nil {
+			return re.JSON(401, map[string]string{"error": "Authentication required"})
+		}
+		role := re.Auth.GetString("role")
+		if role != "agent" && role != "admin" {
+			return re.JSON(403, map[string]string{"error": "Insufficient permissions"})
+		}
+
+		query := re.Request.URL.Query()
+		sched, err := schedule.Parse(query.Get("cron_expression"))
+		if err != nil {
+			return re.JSON(400, map[string]string{"error": err.Error()})
+		}
+		count, err := strconv.Atoi(query.Get("count"))
+		if err != nil || count <= 0 {
+			count = defaultPreviewRuns
+		}
+
+		tz := query.Get("timezone")
+		if tz == "" && query.Get("session_id") != "" {
+			if userID, _, err := resolveHumanUser(app, query.Get("session_id")); err == nil {
+				tz = hooks.UserTimezone(app, userID)
+			}
+		}
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return re.JSON(400, map[string]string{"error": fmt.Sprintf("invalid timezone %q (use an IANA name such as Europe/Berlin)", tz)})
+		}
+
+		return re.JSON(200, map[string]any{
+			"cron_expression": sched.String(),
+			"timezone":        loc.String(),
+			"next_runs":       previewRuns(sched, loc, count),
 		})
 	}).Bind(apis.RequireAuth())
 
@@ -141,60 +225,142 @@ func RegisterCronApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 
 		tasks := make([]map[string]any, 0, len(records))
 		for _, r := range records {
-			tasks = append(tasks, map[string]any{
-				"id":              r.Id,
-				"name":            r.GetString("name"),
-				"cron_expression": r.GetString("cron_expression"),
-				"prompt":          r.GetString("prompt"),
-				"session_mode":    r.GetString("session_mode"),
-				"enabled":         r.GetBool("enabled"),
-				"last_executed":   r.GetString("last_executed"),
-				"last_status":     r.GetString("last_status"),
-			})
+			tasks = append(tasks, scheduledTask(r))
 		}
 
 		return re.JSON(200, tasks)
 	}).Bind(apis.RequireAuth())
 
-	// POST /api/pocketcoder/cancel_scheduled_task
-	e.Router.POST("/api/pocketcoder/cancel_scheduled_task", func(re *core.RequestEvent) error {
-		if re.Auth == nil {
-			return re.JSON(401, map[string]string{"error": "Authentication required"})
-		}
-		role := re.Auth.GetString("role")
-		if role != "agent" && role != "admin" {
-			return re.JSON(403, map[string]string{"error": "Insufficient permissions"})
+	// GET /api/pocketcoder/scheduled_task_runs?task_id=&session_id=&limit=10
+	// Run history of a task, newest first, with a tally of the outcomes.
+	// Agents name the session they act for; users see their own tasks.
+	e.Router.GET("/api/pocketcoder/scheduled_task_runs", func(re *core.RequestEvent) error {
+		query := re.Request.URL.Query()
+		job, err := ownedCronJob(app, re, query.Get("task_id"), query.Get("session_id"))
+		if err != nil {
+			return taskLookupResponse(re, err)
 		}
 
-		var input struct {
-			TaskID string `json:"task_id"`
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = defaultRunHistory
 		}
-		if err := re.BindBody(&input); err != nil {
-			return re.JSON(400, map[string]string{"error": "Invalid request body"})
-		}
-		if input.TaskID == "" {
-			return re.JSON(400, map[string]string{"error": "task_id is required"})
+		if limit > maxRunHistory {
+			limit = maxRunHistory
 		}
 
-		record, err := app.FindRecordById("cron_jobs", input.TaskID)
+		records, err := app.FindRecordsByFilter(
+			"cron_runs",
+			"job = {:job}",
+			"-triggered_at",
+			limit, 0,
+			map[string]any{"job": job.Id},
+		)
 		if err != nil {
-			return re.JSON(404, map[string]string{"error": "Scheduled task not found"})
+			log.Printf("❌ [CronAPI] Failed to query cron runs: %v", err)
+			return re.JSON(500, map[string]string{"error": "Internal error"})
 		}
 
-		taskName := record.GetString("name")
-		record.Set("enabled", false)
-		if err := app.Save(record); err != nil {
-			log.Printf("❌ [CronAPI] Failed to disable cron job: %v", err)
-			return re.JSON(500, map[string]string{"error": "Failed to cancel scheduled task"})
+		summary := map[string]int{
+			"runs":                 len(records),
+			hooks.CronRunCompleted: 0,
+			hooks.CronRunFailed:    0,
+			hooks.CronRunAborted:   0,
+			hooks.CronRunSkipped:   0,
+			hooks.CronRunQueued:    0,
+			hooks.CronRunRunning:   0,
+		}
+		loc := hooks.CronJobLocation(job)
+		runs := make([]map[string]any, 0, len(records))
+		for _, r := range records {
+			summary[r.GetString("status")]++
+			runs = append(runs, map[string]any{
+				"id":                 r.Id,
+				"trigger":            r.GetString("trigger"),
+				"triggered_at":       r.GetString("triggered_at"),
+				"triggered_at_local": r.GetDateTime("triggered_at").Time().In(loc).Format(time.RFC3339),
+				"status":             r.GetString("status"),
+				"error":              r.GetString("error"),
+				"chat":               r.GetString("chat"),
+				"message":            r.GetString("message"),
+				"reply":              r.GetString("reply"),
+				"finished_at":        r.GetString("finished_at"),
+				"duration_ms":        r.GetInt("duration_ms"),
+			})
 		}
 
-		log.Printf("⏰ [CronAPI] Disabled cron job '%s' (%s)", taskName, input.TaskID)
 		return re.JSON(200, map[string]any{
-			"id":     input.TaskID,
-			"name":   taskName,
-			"status": "cancelled",
+			"task_id":  job.Id,
+			"name":     job.GetString("name"),
+			"timezone": loc.String(),
+			"summary":  summary,
+			"runs":     runs,
 		})
 	}).Bind(apis.RequireAuth())
+
+	registerCronLifecycleApi(app, e)
+}
+
+// cronJobOwner returns the user whose tasks the caller may see: anyone's for
+// admins (""), the session's human for agents, and their own for users.
+func cronJobOwner(app *pocketbase.PocketBase, re *core.RequestEvent, sessionID string) (string, error) {
+	switch re.Auth.GetString("role") {
+	case "admin":
+		return "", nil
+	case "agent":
+		if sessionID == "" {
+			return "", errors.New("session_id is required")
+		}
+		userID, _, err := resolveHumanUser(app, sessionID)
+		if err != nil {
+			log.Printf("❌ [CronAPI] Failed to resolve human user: %v", err)
+			return "", errors.New("Could not resolve user from session")
+		}
+		return userID, nil
+	default:
+		return re.Auth.Id, nil
+	}
+}
+
+// scheduledTask renders a cron job for the task APIs, with its next fire time
+// while it is enabled.
+func scheduledTask(r *core.Record) map[string]any {
+	loc := hooks.CronJobLocation(r)
+	var nextRun *scheduledRun
+	if sched, err := schedule.Parse(r.GetString("cron_expression")); err == nil && r.GetBool("enabled") {
+		if runs := previewRuns(sched, loc, 1); len(runs) > 0 {
+			nextRun = &runs[0]
+		}
+	}
+	return map[string]any{
+		"id":              r.Id,
+		"name":            r.GetString("name"),
+		"cron_expression": r.GetString("cron_expression"),
+		"timezone":        loc.String(),
+		"prompt":          r.GetString("prompt"),
+		"session_mode":    r.GetString("session_mode"),
+		"overlap_policy":  r.GetString("overlap_policy"),
+		"enabled":         r.GetBool("enabled"),
+		"last_executed":   r.GetString("last_executed"),
+		"last_status":     r.GetString("last_status"),
+		"next_run":        nextRun,
+	}
+}
+
+// scheduledRun is a fire time in UTC and in the job's timezone.
+type scheduledRun struct {
+	UTC   string `json:"utc"`
+	Local string `json:"local"`
+}
+
+// previewRuns lists the next n fire times of sched read in loc.
+func previewRuns(sched *schedule.Schedule, loc *time.Location, n int) []scheduledRun {
+	runs := sched.Preview(time.Now().In(loc), n)
+	out := make([]scheduledRun, len(runs))
+	for i, t := range runs {
+		out[i] = scheduledRun{UTC: t.UTC().Format(time.RFC3339), Local: t.Format(time.RFC3339)}
+	}
+	return out
 }
 
 // resolveHumanUser finds the human user ID and chat ID from an OpenCode session ID.
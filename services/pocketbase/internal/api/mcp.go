@@ -26,6 +26,7 @@ import (
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
 // RegisterMcpApi registers the MCP server request endpoint.
@@ -49,6 +50,7 @@ func RegisterMcpApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 			SessionID    string         `json:"session_id"`
 			Image        string         `json:"image"`
 			ConfigSchema map[string]any `json:"config_schema"`
+			Tools        []any          `json:"tools"`
 		}
 
 		if err := re.BindBody(&input); err != nil {
@@ -92,6 +94,11 @@ func RegisterMcpApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 			existing.Set("image", input.Image)
 			existing.Set("config_schema", input.ConfigSchema)
 			existing.Set("requested_by", input.SessionID)
+			// The tool list is recorded on approval; later research must
+			// not widen what was approved.
+			if existing.GetString("status") == "pending" && input.Tools != nil {
+				existing.Set("tools", permission.NormalizeMcpTools(input.Tools))
+			}
 
 			if err := app.Save(existing); err != nil {
 				log.Printf("❌ Failed to update existing MCP server record: %v", err)
@@ -113,6 +120,7 @@ func RegisterMcpApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 		record.Set("catalog", "docker-mcp") // Default catalog
 		record.Set("image", input.Image)
 		record.Set("config_schema", input.ConfigSchema)
+		record.Set("tools", permission.NormalizeMcpTools(input.Tools))
 
 		if err := app.Save(record); err != nil {
 			log.Printf("❌ Failed to create MCP server record: %v", err)
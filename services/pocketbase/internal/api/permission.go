@@ -21,17 +21,27 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
 	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
+const (
+	defaultPermissionWait = 30 * time.Second
+	maxPermissionWait     = 120 * time.Second
+)
+
 // RegisterPermissionApi registers the Sovereign Authority evaluation endpoint.
-func RegisterPermissionApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
+func RegisterPermissionApi(app core.App, e *core.ServeEvent) {
 	e.Router.POST("/api/pocketcoder/permission", func(re *core.RequestEvent) error {
 		var input struct {
 			Permission string         `json:"permission"`
@@ -43,20 +53,13 @@ func RegisterPermissionApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 			Message    string         `json:"message"`
 			MessageID  string         `json:"message_id"`
 			CallID     string         `json:"call_id"`
+			Agent      string         `json:"agent"`
 		}
 
 		if err := re.BindBody(&input); err != nil {
 			return re.JSON(400, map[string]string{"error": "Invalid request body"})
 		}
 
-		// 1. Evaluate using the shared permission service
-		isPermitted, status := permission.Evaluate(app, permission.EvaluationInput{
-			Permission: input.Permission,
-			Patterns:   input.Patterns,
-			Metadata:   input.Metadata,
-		})
-
-		// 2. Create Audit Record
 		permColl, _ := app.FindCollectionByNameOrId("permissions")
 		record := core.NewRecord(permColl)
 
@@ -68,20 +71,195 @@ func RegisterPermissionApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 		record.Set("metadata", input.Metadata)
 		record.Set("message_id", input.MessageID)
 		record.Set("call_id", input.CallID)
-		record.Set("status", status)
+
+		// 1. A retry of a request we already recorded gets that record back.
+		// Requests without opencode_id or call_id cannot be matched and are
+		// always recorded anew.
+		existing, err := hooks.FindPermissionRetry(app, record)
+		if err != nil {
+			return permissionRetryError(re, err)
+		}
+		if existing != nil {
+			return permissionRetryResponse(re, existing)
+		}
+
+		// 2. Evaluate using the shared permission service
+		agentID, agentName := resolveAgent(app, input.Agent, input.ChatID)
+		decision := permission.Evaluate(app, permission.EvaluationInput{
+			Permission: input.Permission,
+			Patterns:   input.Patterns,
+			Metadata:   input.Metadata,
+			AgentID:    agentID,
+			Agent:      agentName,
+			ChatID:     input.ChatID,
+			SessionID:  input.SessionID,
+			CronJobID:  hooks.ChatCronJobID(app, input.ChatID),
+		})
+
+		// 3. Create Audit Record
+		record.Set("status", decision.Status)
+		record.Set("trace", decision.Trace)
+		if decision.Status == permission.StatusDraft {
+			if ttl := permission.DraftTTL(app, input.Permission, agentID); ttl > 0 {
+				record.Set("expires_at", time.Now().UTC().Add(ttl))
+			}
+		}
 		record.Set("source", "interface") // Clarify source
 		record.Set("message", input.Message)
 		record.Set("challenge", uuid.NewString())
 
 		if err := app.Save(record); err != nil {
+			// A concurrent retry won the unique idempotency_key
+			existing, findErr := hooks.FindPermissionRetry(app, record)
+			if findErr != nil {
+				return permissionRetryError(re, findErr)
+			}
+			if existing != nil {
+				return permissionRetryResponse(re, existing)
+			}
 			log.Printf("❌ Failed to save audit: %v", err)
 			return re.JSON(500, map[string]string{"error": "Persistence error"})
 		}
 
 		return re.JSON(200, map[string]any{
-			"permitted": isPermitted,
-			"id":        record.Id,
-			"status":    status,
+			"permitted":  decision.Permitted,
+			"id":         record.Id,
+			"status":     decision.Status,
+			"trace":      record.Get("trace"),
+			"expires_at": record.GetString("expires_at"),
+			"existing":   false,
 		})
 	}).Bind(apis.RequireAuth())
+
+	// GET /api/pocketcoder/permission/{id}/wait?timeout=30
+	// Long-polls until the permission is authorized or denied, so callers don't
+	// need their own polling loop or realtime subscription.
+	e.Router.GET("/api/pocketcoder/permission/{id}/wait", func(re *core.RequestEvent) error {
+		id := re.Request.PathValue("id")
+
+		timeout, err := parseWaitTimeout(re.Request.URL.Query().Get("timeout"))
+		if err != nil {
+			return re.JSON(400, map[string]string{"error": err.Error()})
+		}
+
+		ctx, cancel := context.WithTimeout(re.Request.Context(), timeout)
+		defer cancel()
+
+		record, decided, err := hooks.WaitForPermissionDecision(ctx, app, id)
+		if err != nil {
+			return re.JSON(404, map[string]string{"error": "Permission not found"})
+		}
+
+		return re.JSON(200, map[string]any{
+			"id":          record.Id,
+			"status":      record.GetString("status"),
+			"decided":     decided,
+			"timed_out":   !decided,
+			"approved_by": record.GetString("approved_by"),
+			"approved_at": record.GetString("approved_at"),
+			"reason":      record.GetString("decision_reason"),
+		})
+	}).Bind(apis.RequireAuth())
+
+	// GET /api/pocketcoder/permission/{id}/rule_preview?scope=chat&pattern=...
+	// Shows the tool_permissions rules that approving with the given scope
+	// would save, so the user can check the generated pattern first.
+	e.Router.GET("/api/pocketcoder/permission/{id}/rule_preview", func(re *core.RequestEvent) error {
+		record, err := app.FindRecordById("permissions", re.Request.PathValue("id"))
+		if err != nil {
+			return re.JSON(404, map[string]string{"error": "Permission not found"})
+		}
+
+		query := re.Request.URL.Query()
+		scope := query.Get("scope")
+		if scope == "" {
+			scope = permission.ApprovalOnce
+		}
+
+		plan, err := hooks.PlanPermissionApproval(app, record, scope, query.Get("pattern"))
+		if err != nil {
+			return re.JSON(400, map[string]string{"error": err.Error()})
+		}
+		return re.JSON(200, plan)
+	}).Bind(apis.RequireAuth())
+
+	registerPermissionSimulateApi(app, e)
+	registerPermissionLedgerApi(app, e)
+	registerPermissionSuggestApi(app, e)
+}
+
+// permissionRetryResponse answers a retried permission request with the
+// record created the first time, in its current state.
+func permissionRetryResponse(re *core.RequestEvent, record *core.Record) error {
+	var trace permission.Trace
+	_ = record.UnmarshalJSONField("trace", &trace)
+	status := record.GetString("status")
+
+	log.Printf("🔁 [Authority] Retry of %s, returning existing permission %s (%s)",
+		record.GetString("idempotency_key"), record.Id, status)
+	return re.JSON(200, map[string]any{
+		"permitted":  status == permission.StatusAuthorized,
+		"id":         record.Id,
+		"status":     status,
+		"trace":      trace,
+		"expires_at": record.GetString("expires_at"),
+		"existing":   true,
+	})
+}
+
+func permissionRetryError(re *core.RequestEvent, err error) error {
+	if errors.Is(err, hooks.ErrIdempotencyConflict) {
+		return re.JSON(409, map[string]string{"error": err.Error()})
+	}
+	log.Printf("❌ Failed to look up permission retry: %v", err)
+	return re.JSON(500, map[string]string{"error": "Persistence error"})
+}
+
+// parseWaitTimeout accepts either plain seconds ("45") or a Go duration
+// ("90s", "2m"), capped at maxPermissionWait.
+func parseWaitTimeout(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultPermissionWait, nil
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil {
+		secs, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid timeout %q", raw)
+		}
+		timeout = time.Duration(secs) * time.Second
+	}
+
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	if timeout > maxPermissionWait {
+		timeout = maxPermissionWait
+	}
+	return timeout, nil
+}
+
+// resolveAgent finds the ai_agents record (ID and name) a request runs under,
+// preferring an explicit agent name and falling back to the agent linked on the chat.
+func resolveAgent(app core.App, agentName string, chatID string) (string, string) {
+	if agentName != "" {
+		agent, err := app.FindFirstRecordByFilter("ai_agents", "name = {:name}", map[string]any{"name": agentName})
+		if err == nil {
+			return agent.Id, agent.GetString("name")
+		}
+		log.Printf("⚠️ [Authority] Unknown agent '%s', evaluating against global rules", agentName)
+	}
+
+	if chatID != "" {
+		chat, err := app.FindRecordById("chats", chatID)
+		if err == nil && chat.GetString("agent") != "" {
+			agent, err := app.FindRecordById("ai_agents", chat.GetString("agent"))
+			if err == nil {
+				return agent.Id, agent.GetString("name")
+			}
+		}
+	}
+
+	return "", ""
 }
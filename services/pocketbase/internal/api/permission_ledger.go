@@ -0,0 +1,92 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Ledger API. Walks the permissions hash chain and reports the first broken link.
+package api
+
+import (
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+const ledgerPageSize = 500
+
+// registerPermissionLedgerApi registers the ledger verification endpoint.
+// It is admin-only, since every call walks the whole permissions table.
+func registerPermissionLedgerApi(app core.App, e *core.ServeEvent) {
+	// GET /api/pocketcoder/permission/ledger/verify
+	// Recomputes every entry hash in seq order, one page at a time. Decided
+	// records outside the chain are reported as well, since the backend
+	// chains every decision.
+	e.Router.GET("/api/pocketcoder/permission/ledger/verify", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can verify the permission ledger.", nil)
+		}
+
+		var verifier permission.ChainVerifier
+		var broken *permission.ChainBreak
+		for broken == nil {
+			records, err := app.FindRecordsByFilter(
+				"permissions", "seq > {:after}", "seq", ledgerPageSize, 0,
+				map[string]any{"after": verifier.Entries},
+			)
+			if err != nil {
+				return re.JSON(500, map[string]string{"error": "Failed to load the ledger"})
+			}
+			links := make([]permission.ChainLink, 0, len(records))
+			for _, record := range records {
+				links = append(links, permission.ChainLink{
+					ID:        record.Id,
+					Seq:       record.GetInt("seq"),
+					PrevHash:  record.GetString("prev_hash"),
+					EntryHash: record.GetString("entry_hash"),
+					Computed:  permission.ChainHash(record.GetString("prev_hash"), hooks.PermissionLedgerEntry(record)),
+				})
+			}
+			broken = verifier.Verify(links)
+			if len(records) < ledgerPageSize {
+				break
+			}
+		}
+
+		result := map[string]any{
+			"valid":   true,
+			"entries": verifier.Entries,
+		}
+		if verifier.Head != "" {
+			result["head"] = verifier.Head
+		}
+		if broken != nil {
+			result["valid"] = false
+			result["broken"] = broken
+			return re.JSON(200, result)
+		}
+
+		unchained, err := app.FindRecordsByFilter("permissions", "seq = 0 && status != 'draft'", "created", 1, 0)
+		if err != nil {
+			return re.JSON(500, map[string]string{"error": "Failed to load the ledger"})
+		}
+		if len(unchained) > 0 {
+			result["valid"] = false
+			result["broken"] = permission.ChainBreak{ID: unchained[0].Id, Reason: "decided record is not in the chain"}
+		}
+		return re.JSON(200, result)
+	}).Bind(apis.RequireAuth())
+}
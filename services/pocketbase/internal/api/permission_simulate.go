@@ -0,0 +1,284 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Policy Simulator. Dry-runs permission requests and replays history against proposed rules.
+package api
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+const maxSimulationReplay = 500
+
+// simulatedRule is a proposed tool_permissions row. Agent is an ai_agents
+// name or ID; empty means global.
+type simulatedRule struct {
+	Agent       string `json:"agent"`
+	Chat        string `json:"chat"`
+	Tool        string `json:"tool"`
+	Pattern     string `json:"pattern"`
+	Action      string `json:"action"`
+	Active      *bool  `json:"active"`
+	Quorum      int    `json:"quorum"`
+	QuorumGroup string `json:"quorum_group"`
+	permission.Conditions
+}
+
+// simulatedOutcome is one side of a replayed comparison.
+type simulatedOutcome struct {
+	Action string `json:"action"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// replayedRequest is a past permission whose outcome would change.
+type replayedRequest struct {
+	ID             string           `json:"id"`
+	Permission     string           `json:"permission"`
+	Request        string           `json:"request"`
+	Created        string           `json:"created"`
+	RecordedStatus string           `json:"recorded_status"`
+	Current        simulatedOutcome `json:"current"`
+	Proposed       simulatedOutcome `json:"proposed"`
+}
+
+// registerPermissionSimulateApi registers the dry-run evaluation endpoint.
+// It is admin-only, since it evaluates any chat's rules and a replay
+// returns every chat's history.
+func registerPermissionSimulateApi(app core.App, e *core.ServeEvent) {
+	// POST /api/pocketcoder/permission/simulate
+	// Runs the evaluator without writing an audit record. With "replay": N it
+	// instead re-evaluates the last N permissions under the current rules and
+	// under "rules", and reports the requests whose outcome would flip.
+	e.Router.POST("/api/pocketcoder/permission/simulate", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can simulate permission rules.", nil)
+		}
+
+		var input struct {
+			Permission string          `json:"permission"`
+			Patterns   []string        `json:"patterns"`
+			Metadata   map[string]any  `json:"metadata"`
+			Agent      string          `json:"agent"`
+			ChatID     string          `json:"chat_id"`
+			SessionID  string          `json:"session_id"`
+			At         string          `json:"at"`
+			Rules      []simulatedRule `json:"rules"`
+			Replay     int             `json:"replay"`
+		}
+
+		if err := re.BindBody(&input); err != nil {
+			return re.JSON(400, map[string]string{"error": "Invalid request body"})
+		}
+
+		current, err := currentPolicy(app)
+		if err != nil {
+			return re.JSON(500, map[string]string{"error": "Failed to load tool_permissions"})
+		}
+
+		proposed := current
+		if input.Rules != nil {
+			rules, err := proposedRules(app, input.Rules)
+			if err != nil {
+				return re.JSON(400, map[string]string{"error": err.Error()})
+			}
+			proposed.Rules = rules
+		}
+
+		if input.Replay > 0 {
+			if input.Rules == nil {
+				return re.JSON(400, map[string]string{"error": "replay requires a proposed rule set in rules"})
+			}
+			return replayPermissions(app, re, current, proposed, input.Replay)
+		}
+
+		if input.Permission == "" {
+			return re.JSON(400, map[string]string{"error": "permission is required"})
+		}
+
+		var at time.Time
+		if input.At != "" {
+			if at, err = time.Parse(time.RFC3339, input.At); err != nil {
+				return re.JSON(400, map[string]string{"error": "at must be an RFC 3339 time"})
+			}
+		}
+
+		agentID, agentName := resolveAgent(app, input.Agent, input.ChatID)
+		evalInput := permission.EvaluationInput{
+			Permission: input.Permission,
+			Patterns:   input.Patterns,
+			Metadata:   input.Metadata,
+			AgentID:    agentID,
+			Agent:      agentName,
+			ChatID:     input.ChatID,
+			SessionID:  input.SessionID,
+			CronJobID:  hooks.ChatCronJobID(app, input.ChatID),
+			Now:        at,
+		}
+		proposed.Usage = permission.LoadRuleUsage(app, proposed.Rules, input.SessionID)
+		decision := permission.Resolve(proposed, evalInput)
+
+		return re.JSON(200, map[string]any{
+			"simulated": true,
+			"permitted": decision.Permitted,
+			"status":    decision.Status,
+			"trace":     decision.Trace,
+			"risk":      permission.AssessRisk(evalInput),
+		})
+	}).Bind(apis.RequireAuth())
+}
+
+// currentPolicy loads every active rule and protected path.
+func currentPolicy(app core.App) (permission.Policy, error) {
+	rules, err := permission.LoadAllRules(app)
+	if err != nil {
+		return permission.Policy{}, err
+	}
+	protected, err := permission.LoadProtectedPaths(app)
+	if err != nil {
+		log.Printf("⚠️ [Authority] Failed to load protected_paths, using built-in list only: %v", err)
+	}
+	mcpTools, err := permission.LoadMcpTools(app)
+	if err != nil {
+		log.Printf("⚠️ [Authority] Failed to load MCP server tools: %v", err)
+	}
+	return permission.Policy{Rules: rules, Protected: protected, McpTools: mcpTools}, nil
+}
+
+// proposedRules validates a proposed rule set and resolves agent names.
+func proposedRules(app core.App, proposed []simulatedRule) ([]permission.Rule, error) {
+	agentIDs := map[string]string{}
+	rules := make([]permission.Rule, 0, len(proposed))
+
+	for i, r := range proposed {
+		if r.Active != nil && !*r.Active {
+			continue
+		}
+		if r.Tool == "" || r.Pattern == "" {
+			return nil, fmt.Errorf("rules[%d]: tool and pattern are required", i)
+		}
+		switch r.Action {
+		case permission.ActionAllow, permission.ActionAsk, permission.ActionDeny:
+		default:
+			return nil, fmt.Errorf("rules[%d]: action must be allow, ask or deny", i)
+		}
+		if err := r.Conditions.Validate(); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		if err := permission.ValidatePatterns(r.Tool, r.Pattern); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+
+		agentID := ""
+		if r.Agent != "" {
+			id, ok := agentIDs[r.Agent]
+			if !ok {
+				agent, err := app.FindRecordById("ai_agents", r.Agent)
+				if err != nil {
+					agent, err = app.FindFirstRecordByFilter("ai_agents", "name = {:name}", map[string]any{"name": r.Agent})
+				}
+				if err != nil {
+					return nil, fmt.Errorf("rules[%d]: unknown agent %q", i, r.Agent)
+				}
+				id = agent.Id
+				agentIDs[r.Agent] = id
+			}
+			agentID = id
+		}
+
+		rules = append(rules, permission.Rule{
+			ID:          fmt.Sprintf("proposed:%d", i),
+			AgentID:     agentID,
+			ChatID:      r.Chat,
+			Tool:        r.Tool,
+			Pattern:     r.Pattern,
+			Action:      r.Action,
+			Quorum:      r.Quorum,
+			QuorumGroup: r.QuorumGroup,
+			Conditions:  r.Conditions,
+		})
+	}
+	return rules, nil
+}
+
+// replayPermissions re-evaluates the last n permissions under both policies.
+func replayPermissions(app core.App, re *core.RequestEvent, current, proposed permission.Policy, n int) error {
+	if n > maxSimulationReplay {
+		n = maxSimulationReplay
+	}
+
+	records, err := app.FindRecordsByFilter("permissions", "1=1", "-created", n, 0)
+	if err != nil {
+		return re.JSON(500, map[string]string{"error": "Failed to load permissions"})
+	}
+
+	changes := []replayedRequest{}
+	summary := map[string]int{"replayed": len(records), "changed": 0, "widened": 0, "narrowed": 0}
+
+	for _, record := range records {
+		input := hooks.PermissionInput(record)
+		input.AgentID = hooks.PermissionAgentID(app, record)
+		input.CronJobID = hooks.ChatCronJobID(app, input.ChatID)
+		input.Now = record.GetDateTime("created").Time()
+
+		before := permission.Resolve(current, input)
+		after := permission.Resolve(proposed, input)
+		if before.Action == after.Action {
+			continue
+		}
+
+		summary["changed"]++
+		if permission.Severity(after.Action) < permission.Severity(before.Action) {
+			summary["widened"]++
+		} else {
+			summary["narrowed"]++
+		}
+
+		changes = append(changes, replayedRequest{
+			ID:             record.Id,
+			Permission:     input.Permission,
+			Request:        describeRequest(input),
+			Created:        record.GetString("created"),
+			RecordedStatus: record.GetString("status"),
+			Current:        simulatedOutcome{before.Action, before.Status, before.Trace.Reason},
+			Proposed:       simulatedOutcome{after.Action, after.Status, after.Trace.Reason},
+		})
+	}
+
+	return re.JSON(200, map[string]any{
+		"simulated": true,
+		"summary":   summary,
+		"changes":   changes,
+	})
+}
+
+// describeRequest renders a request for display: the bash command, or the patterns.
+func describeRequest(input permission.EvaluationInput) string {
+	if cmd, _ := input.Metadata["command"].(string); cmd != "" {
+		return cmd
+	}
+	return strings.Join(input.Patterns, ", ")
+}
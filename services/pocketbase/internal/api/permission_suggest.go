@@ -0,0 +1,209 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Suggestions API. Proposes allow rules from approval history and saves accepted ones.
+package api
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+const (
+	maxSuggestionHistory  = 2000
+	defaultSuggestionDays = 30
+	defaultSuggestionMin  = 3
+)
+
+// registerPermissionSuggestApi registers the rule suggestion endpoints. Both
+// are admin-only, since suggestions expose every chat's history and accepted
+// rules apply globally or to a whole agent.
+func registerPermissionSuggestApi(app core.App, e *core.ServeEvent) {
+	// GET /api/pocketcoder/permission/suggestions?days=30&min=3&limit=20
+	// Groups the human approvals of the last `days` days by tool and request
+	// shape and proposes an allow rule for every group of at least `min`.
+	e.Router.GET("/api/pocketcoder/permission/suggestions", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can review rule suggestions.", nil)
+		}
+
+		query := re.Request.URL.Query()
+		days := queryInt(query.Get("days"), defaultSuggestionDays)
+		minCovered := queryInt(query.Get("min"), defaultSuggestionMin)
+		limit := queryInt(query.Get("limit"), 0)
+
+		policy, history, err := suggestionInputs(app, days)
+		if err != nil {
+			return re.JSON(500, map[string]string{"error": err.Error()})
+		}
+
+		suggestions := permission.SuggestRules(policy, history, minCovered)
+		if limit > 0 && len(suggestions) > limit {
+			suggestions = suggestions[:limit]
+		}
+		if suggestions == nil {
+			suggestions = []permission.Suggestion{}
+		}
+		return re.JSON(200, map[string]any{
+			"days":        days,
+			"history":     len(history),
+			"suggestions": suggestions,
+		})
+	}).Bind(apis.RequireAuth())
+
+	// POST /api/pocketcoder/permission/suggestions
+	// Accepts a suggestion: { "tool", "pattern", "agent", "force" }. The rule
+	// is checked against the same history first, and one that would have
+	// allowed a denied request is refused with 409 unless force is set.
+	e.Router.POST("/api/pocketcoder/permission/suggestions", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can accept rule suggestions.", nil)
+		}
+
+		var input struct {
+			Tool    string `json:"tool"`
+			Pattern string `json:"pattern"`
+			Agent   string `json:"agent"`
+			Days    int    `json:"days"`
+			Force   bool   `json:"force"`
+		}
+		if err := re.BindBody(&input); err != nil {
+			return re.JSON(400, map[string]string{"error": "Invalid request body"})
+		}
+		if input.Tool == "" || input.Pattern == "" {
+			return re.JSON(400, map[string]string{"error": "tool and pattern are required"})
+		}
+		if err := permission.ValidatePatterns(input.Tool, input.Pattern); err != nil {
+			return re.JSON(400, map[string]string{"error": err.Error()})
+		}
+		if input.Agent != "" {
+			if _, err := app.FindRecordById("ai_agents", input.Agent); err != nil {
+				return re.JSON(400, map[string]string{"error": "unknown agent " + input.Agent})
+			}
+		}
+		if input.Days <= 0 {
+			input.Days = defaultSuggestionDays
+		}
+
+		policy, history, err := suggestionInputs(app, input.Days)
+		if err != nil {
+			return re.JSON(500, map[string]string{"error": err.Error()})
+		}
+		suggestion := permission.AssessRule(policy, history, permission.Rule{
+			AgentID: input.Agent,
+			Tool:    input.Tool,
+			Pattern: input.Pattern,
+			Action:  permission.ActionAllow,
+		})
+		if !suggestion.Safe && !input.Force {
+			return re.JSON(409, map[string]any{
+				"error":      "the rule would have allowed requests that were denied; set force to add it anyway",
+				"suggestion": suggestion,
+			})
+		}
+
+		record, err := saveSuggestedRule(app, input.Agent, input.Tool, input.Pattern)
+		if err != nil {
+			log.Printf("❌ [Authority] Failed to save suggested rule %s: %s: %v", input.Tool, input.Pattern, err)
+			return re.JSON(500, map[string]string{"error": "Failed to save rule"})
+		}
+		log.Printf("💡 [Authority] Accepted suggested rule %s: %s (covers %d past approvals)", input.Tool, input.Pattern, suggestion.Covered)
+
+		return re.JSON(200, map[string]any{
+			"id":         record.Id,
+			"suggestion": suggestion,
+		})
+	}).Bind(apis.RequireAuth())
+}
+
+// suggestionInputs loads the current policy and the decided permissions of
+// the last days days. Expired drafts are left out, since nobody denied them.
+func suggestionInputs(app core.App, days int) (permission.Policy, []permission.HistoryEntry, error) {
+	policy, err := currentPolicy(app)
+	if err != nil {
+		return policy, nil, err
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	records, err := app.FindRecordsByFilter(
+		"permissions",
+		"(status = 'authorized' || status = 'denied') && created >= {:since}",
+		"-created", maxSuggestionHistory, 0,
+		map[string]any{"since": since.Format("2006-01-02 15:04:05.000Z")},
+	)
+	if err != nil {
+		return policy, nil, err
+	}
+
+	history := make([]permission.HistoryEntry, 0, len(records))
+	for _, record := range records {
+		if strings.HasPrefix(record.GetString("decision_reason"), "expired:") {
+			continue
+		}
+		input := hooks.PermissionInput(record)
+		input.AgentID = hooks.PermissionAgentID(app, record)
+		input.CronJobID = hooks.ChatCronJobID(app, input.ChatID)
+		input.Now = record.GetDateTime("created").Time()
+		history = append(history, permission.HistoryEntry{
+			ID:      record.Id,
+			Input:   input,
+			Request: describeRequest(input),
+			Status:  record.GetString("status"),
+			Human:   hooks.HumanApproved(record),
+		})
+	}
+	return policy, history, nil
+}
+
+// saveSuggestedRule adds an active allow rule, reusing the row with the same
+// scope, tool and pattern if there is one.
+func saveSuggestedRule(app core.App, agentID, tool, pattern string) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		"tool_permissions",
+		"agent = {:agent} && chat = '' && tool = {:tool} && pattern = {:pattern}",
+		map[string]any{"agent": agentID, "tool": tool, "pattern": pattern},
+	)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("tool_permissions")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("agent", agentID)
+		record.Set("tool", tool)
+		record.Set("pattern", pattern)
+	}
+	record.Set("action", permission.ActionAllow)
+	record.Set("active", true)
+	return record, app.Save(record)
+}
+
+// queryInt parses a positive integer query parameter, falling back to def.
+func queryInt(raw string, def int) int {
+	if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/api"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+	_ "github.com/qtpi-automaton/pocketcoder/backend/pb_migrations"
+)
+
+// newPermissionApiApp returns a test app with the PocketCoder schema, the
+// permission hooks and the permission endpoints.
+func newPermissionApiApp(t testing.TB) *tests.TestApp {
+	t.Helper()
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+	hooks.RegisterPermissionHooks(app)
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		api.RegisterPermissionApi(app, e)
+		return e.Next()
+	})
+	return app
+}
+
+// newUser creates a user with role and returns its auth token.
+func newUser(t testing.TB, app core.App, role string) string {
+	t.Helper()
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := core.NewRecord(users)
+	user.SetEmail(fmt.Sprintf("%s-%s@pocketcoder.test", role, core.GenerateDefaultRandomId()))
+	user.SetPassword("pocketcoder_test")
+	user.Set("role", role)
+	if err := app.Save(user); err != nil {
+		t.Fatal(err)
+	}
+	token, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestPermissionSimulateIsAdminOnly(t *testing.T) {
+	app := newPermissionApiApp(t)
+	user := newUser(t, app, "user")
+	admin := newUser(t, app, "admin")
+
+	for _, body := range []string{
+		`{"permission":"bash","patterns":["ls"],"metadata":{"command":"ls"}}`,
+		`{"replay":500,"rules":[{"tool":"bash","pattern":"*","action":"allow"}]}`,
+	} {
+		scenarios := []tests.ApiScenario{
+			{
+				Name:            "user " + body,
+				Headers:         map[string]string{"Authorization": user},
+				ExpectedStatus:  http.StatusForbidden,
+				ExpectedContent: []string{`"data":{}`},
+			},
+			{
+				Name:            "admin " + body,
+				Headers:         map[string]string{"Authorization": admin},
+				ExpectedStatus:  http.StatusOK,
+				ExpectedContent: []string{`"simulated":true`},
+			},
+		}
+		for _, scenario := range scenarios {
+			scenario.Method = http.MethodPost
+			scenario.URL = "/api/pocketcoder/permission/simulate"
+			scenario.Body = strings.NewReader(body)
+			scenario.TestAppFactory = func(testing.TB) *tests.TestApp { return app }
+			scenario.DisableTestAppCleanup = true
+			scenario.Test(t)
+		}
+	}
+}
+
+func TestPermissionLedgerVerifyIsAdminOnly(t *testing.T) {
+	app := newPermissionApiApp(t)
+	user := newUser(t, app, "user")
+	admin := newUser(t, app, "admin")
+
+	permissions, err := app.FindCollectionByNameOrId("permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, command := range []string{"make deploy", "make destroy"} {
+		record := core.NewRecord(permissions)
+		record.Set("ai_engine_permission_id", fmt.Sprintf("per_ledger_%d", i))
+		record.Set("session_id", "ses_test")
+		record.Set("permission", "bash")
+		record.Set("patterns", []string{command})
+		record.Set("metadata", map[string]any{"command": command})
+		record.Set("status", permission.StatusDraft)
+		if err := app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+		record.Set("status", permission.StatusDenied)
+		if err := app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scenarios := []tests.ApiScenario{
+		{
+			Name:            "user",
+			Headers:         map[string]string{"Authorization": user},
+			ExpectedStatus:  http.StatusForbidden,
+			ExpectedContent: []string{`"data":{}`},
+		},
+		{
+			Name:            "admin",
+			Headers:         map[string]string{"Authorization": admin},
+			ExpectedStatus:  http.StatusOK,
+			ExpectedContent: []string{`"valid":true`, `"entries":2`},
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Method = http.MethodGet
+		scenario.URL = "/api/pocketcoder/permission/ledger/verify"
+		scenario.TestAppFactory = func(testing.TB) *tests.TestApp { return app }
+		scenario.DisableTestAppCleanup = true
+		scenario.Test(t)
+	}
+}
+
+func TestPermissionRequestWithoutIdempotencyKey(t *testing.T) {
+	app := newPermissionApiApp(t)
+	agent := newUser(t, app, "agent")
+
+	body := `{"permission":"bash","patterns":["make deploy"],"session_id":"ses_test","metadata":{"command":"make deploy"}}`
+	for _, name := range []string{"first", "second"} {
+		scenario := tests.ApiScenario{
+			Name:                  name,
+			Method:                http.MethodPost,
+			URL:                   "/api/pocketcoder/permission",
+			Body:                  strings.NewReader(body),
+			Headers:               map[string]string{"Authorization": agent},
+			ExpectedStatus:        http.StatusOK,
+			ExpectedContent:       []string{`"existing":false`, `"status":"draft"`},
+			TestAppFactory:        func(testing.TB) *tests.TestApp { return app },
+			DisableTestAppCleanup: true,
+		}
+		scenario.Test(t)
+	}
+
+	count, err := app.CountRecords("permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d permissions recorded, want one per request without a key", count)
+	}
+}
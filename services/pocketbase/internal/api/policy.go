@@ -0,0 +1,68 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Policy API. Status, sync and export of the policy-as-code file.
+package api
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+)
+
+// RegisterPolicyApi registers the policy file endpoints. All are admin-only.
+func RegisterPolicyApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
+	// 📜 Last sync outcome: adds, updates, removes, conflicts
+	// Example: GET /api/pocketcoder/policy
+	e.Router.GET("/api/pocketcoder/policy", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can manage the policy file.", nil)
+		}
+		return re.JSON(200, hooks.GetPolicyStatus(app))
+	}).Bind(apis.RequireAuth())
+
+	// 🔄 Reconcile tool_permissions with the file now, even if unchanged
+	// Example: POST /api/pocketcoder/policy/sync
+	e.Router.POST("/api/pocketcoder/policy/sync", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can manage the policy file.", nil)
+		}
+		status, err := hooks.SyncPolicyFile(app, true)
+		if err != nil {
+			return re.JSON(422, map[string]any{"error": err.Error(), "status": status})
+		}
+		return re.JSON(200, status)
+	}).Bind(apis.RequireAuth())
+
+	// 💾 Write the current global and agent rules to the policy file
+	// Example: POST /api/pocketcoder/policy/export
+	e.Router.POST("/api/pocketcoder/policy/export", func(re *core.RequestEvent) error {
+		if re.Auth == nil || re.Auth.GetString("role") != "admin" {
+			return re.ForbiddenError("Only admins can manage the policy file.", nil)
+		}
+		path, data, err := hooks.ExportPolicyFile(app)
+		if err != nil {
+			return re.JSON(500, map[string]string{"error": "Failed to export policy: " + err.Error()})
+		}
+		return re.JSON(200, map[string]any{
+			"path":   path,
+			"policy": string(data),
+		})
+	}).Bind(apis.RequireAuth())
+}
@@ -0,0 +1,92 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Glob Cache. Keeps compiled patterns so each is parsed only once.
+package glob
+
+import (
+	"sync"
+)
+
+// Cache holds compiled patterns by key, such as a rule ID. An entry is
+// recompiled when the pattern stored under its key changes, and dropped by
+// Invalidate. Compile errors are cached too.
+type Cache struct {
+	mu      sync.RWMutex
+	limit   int
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	pattern string
+	seps    string
+	glob    *Glob
+	err     error
+}
+
+// NewCache returns an empty cache. When limit is positive the cache is
+// cleared whenever it would grow past limit entries.
+func NewCache(limit int) *Cache {
+	return &Cache{limit: limit, entries: map[string]cacheEntry{}}
+}
+
+// Get returns pattern compiled with separators, compiling it if key holds
+// nothing or a different pattern.
+func (c *Cache) Get(key, pattern string, separators ...rune) (*Glob, error) {
+	seps := string(separators)
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && entry.pattern == pattern && entry.seps == seps {
+		return entry.glob, entry.err
+	}
+
+	g, err := Compile(pattern, separators...)
+	c.mu.Lock()
+	if _, exists := c.entries[key]; !exists && c.limit > 0 && len(c.entries) >= c.limit {
+		c.entries = map[string]cacheEntry{}
+	}
+	c.entries[key] = cacheEntry{pattern: pattern, seps: seps, glob: g, err: err}
+	c.mu.Unlock()
+	return g, err
+}
+
+// Match reports whether s matches the pattern cached under key. A pattern
+// that does not compile matches nothing.
+func (c *Cache) Match(key, pattern, s string, separators ...rune) bool {
+	g, err := c.Get(key, pattern, separators...)
+	return err == nil && g.Match(s)
+}
+
+// Invalidate drops the given keys.
+func (c *Cache) Invalidate(keys ...string) {
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+}
+
+// shared caches patterns that have no better key than their own text.
+var shared = NewCache(4096)
+
+// Match reports whether s matches pattern, compiling it at most once while
+// it stays cached. A pattern that does not compile matches nothing.
+func Match(pattern, s string, separators ...rune) bool {
+	return shared.Match(string(separators)+"\x00"+pattern, pattern, s, separators...)
+}
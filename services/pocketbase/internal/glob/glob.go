@@ -0,0 +1,360 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Glob Engine. Compiles rule patterns once into linear-time matchers.
+package glob
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrBadPattern is wrapped by every compile error.
+var ErrBadPattern = errors.New("syntax error in pattern")
+
+// Glob is a compiled pattern. The syntax is:
+//
+//	pattern  matches
+//	*        any run of characters except separators
+//	**       any run of characters, separators included; as a whole
+//	         segment ("a/**/b", "a/**") it also matches no segment at all
+//	?        any single character except a separator
+//	[abc]    one character from the class; ranges ([a-z]) and negation
+//	         ([!abc] or [^abc]) are supported, separators never match
+//	{a,b}    either alternative; alternatives may nest and be empty
+//	\c       the character c, literally
+//	!p       (leading only) anything p does not match
+//
+// Without separators `*` and `**` are equivalent and match anything,
+// including newlines. A `]` or `}` outside a class or group is literal.
+type Glob struct {
+	pattern string
+	negate  bool
+
+	// Fast paths for the common shapes; re is used otherwise.
+	kind    matchKind
+	literal string
+	re      *regexp.Regexp
+}
+
+type matchKind int
+
+const (
+	matchRegexp matchKind = iota
+	matchAll
+	matchLiteral
+	matchPrefix
+)
+
+// Compile parses pattern. Separators are the characters `*`, `?` and
+// classes do not cross, typically '/' for paths; commands and tool names are
+// compiled without any.
+func Compile(pattern string, separators ...rune) (*Glob, error) {
+	g := &Glob{pattern: pattern}
+	body := pattern
+	if strings.HasPrefix(body, "!") {
+		g.negate, body = true, body[1:]
+	}
+
+	c := compiler{src: []rune(body), seps: separators}
+	expr, err := c.expr(false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s in %q", ErrBadPattern, err, pattern)
+	}
+
+	const meta = `\*?[{`
+	switch trimmed := strings.TrimRight(body, "*"); {
+	case !strings.ContainsAny(body, meta):
+		g.kind, g.literal = matchLiteral, body
+	case len(separators) == 0 && !strings.ContainsAny(trimmed, meta) && trimmed == "":
+		g.kind = matchAll
+	case len(separators) == 0 && !strings.ContainsAny(trimmed, meta):
+		g.kind, g.literal = matchPrefix, trimmed
+	default:
+		if g.re, err = regexp.Compile("(?s)^" + expr + "$"); err != nil {
+			return nil, fmt.Errorf("%w: %s in %q", ErrBadPattern, err, pattern)
+		}
+	}
+	return g, nil
+}
+
+// Match reports whether s matches the pattern.
+func (g *Glob) Match(s string) bool {
+	var ok bool
+	switch g.kind {
+	case matchAll:
+		ok = true
+	case matchLiteral:
+		ok = s == g.literal
+	case matchPrefix:
+		ok = strings.HasPrefix(s, g.literal)
+	default:
+		ok = g.re.MatchString(s)
+	}
+	return ok != g.negate
+}
+
+// String returns the source pattern.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Validate reports whether pattern compiles.
+func Validate(pattern string, separators ...rune) error {
+	_, err := Compile(pattern, separators...)
+	return err
+}
+
+// IsWildcard reports whether pattern only uses `*` and `?`, so that a plain
+// wildcard matcher (such as OpenCode's) gives it the same meaning as a Glob
+// compiled without separators.
+func IsWildcard(pattern string) bool {
+	return !strings.HasPrefix(pattern, "!") && !strings.ContainsAny(pattern, `[]{}\`)
+}
+
+// compiler translates a pattern into an RE2 expression, which keeps matching
+// linear in the input whatever the pattern.
+type compiler struct {
+	src   []rune
+	pos   int
+	seps  []rune
+	depth int // {...} nesting
+}
+
+// expr translates the pattern from the current position to its end, or to
+// the closing `}` of the group being translated.
+func (c *compiler) expr(inGroup bool) (string, error) {
+	var b strings.Builder
+	for c.pos < len(c.src) {
+		r := c.src[c.pos]
+		switch {
+		case inGroup && r == '}':
+			return b.String(), nil
+		case inGroup && r == ',':
+			c.pos++
+			b.WriteString("|")
+		case r == '\\':
+			if c.pos+1 == len(c.src) {
+				return "", errors.New("trailing backslash")
+			}
+			b.WriteString(regexp.QuoteMeta(string(c.src[c.pos+1])))
+			c.pos += 2
+		case c.isSep(r) && c.trailingDoubleStar(c.pos+1):
+			// "a/**" also matches "a" itself.
+			b.WriteString("(?:" + regexp.QuoteMeta(string(r)) + ".*)?")
+			c.pos = c.starRunEnd(c.pos + 1)
+		case r == '*':
+			b.WriteString(c.star())
+		case r == '?':
+			c.pos++
+			b.WriteString(c.anyChar())
+		case r == '[':
+			expr, err := c.class()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(expr)
+		case r == '{':
+			c.pos++
+			c.depth++
+			inner, err := c.expr(true)
+			c.depth--
+			if err != nil {
+				return "", err
+			}
+			c.pos++ // }
+			b.WriteString("(?:" + inner + ")")
+		default:
+			c.pos++
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if inGroup {
+		return "", errors.New("unclosed {")
+	}
+	return b.String(), nil
+}
+
+// star translates the run of `*` at the current position.
+func (c *compiler) star() string {
+	start := c.pos
+	c.pos = c.starRunEnd(c.pos)
+	if len(c.seps) == 0 {
+		return ".*"
+	}
+	if c.pos-start == 1 {
+		return c.notSep() + "*"
+	}
+	// "**/" at the start of a segment matches "", "a/", "a/b/", ...
+	if (start == 0 || c.isSep(c.src[start-1])) && c.pos < len(c.src) && c.isSep(c.src[c.pos]) {
+		sep := regexp.QuoteMeta(string(c.src[c.pos]))
+		c.pos++
+		return "(?:.*" + sep + ")?"
+	}
+	return ".*"
+}
+
+func (c *compiler) starRunEnd(i int) int {
+	for i < len(c.src) && c.src[i] == '*' {
+		i++
+	}
+	return i
+}
+
+// trailingDoubleStar reports whether a `**` run starting at i ends the
+// pattern (or the current alternative).
+func (c *compiler) trailingDoubleStar(i int) bool {
+	if len(c.seps) == 0 {
+		return false
+	}
+	end := c.starRunEnd(i)
+	if end-i < 2 {
+		return false
+	}
+	return end == len(c.src) || (c.depth > 0 && (c.src[end] == ',' || c.src[end] == '}'))
+}
+
+// class translates the [...] class at the current position. Separators are
+// removed from it, so a class never matches one.
+func (c *compiler) class() (string, error) {
+	c.pos++ // [
+	negate := false
+	if c.pos < len(c.src) && (c.src[c.pos] == '!' || c.src[c.pos] == '^') {
+		negate = true
+		c.pos++
+	}
+
+	var ranges [][2]rune
+	for {
+		if c.pos == len(c.src) {
+			return "", errors.New("unclosed [")
+		}
+		if c.src[c.pos] == ']' && len(ranges) > 0 {
+			c.pos++
+			break
+		}
+		lo, err := c.classChar()
+		if err != nil {
+			return "", err
+		}
+		hi := lo
+		if c.pos+1 < len(c.src) && c.src[c.pos] == '-' && c.src[c.pos+1] != ']' {
+			c.pos++
+			if hi, err = c.classChar(); err != nil {
+				return "", err
+			}
+			if hi < lo {
+				return "", fmt.Errorf("bad range %c-%c", lo, hi)
+			}
+		}
+		ranges = append(ranges, [2]rune{lo, hi})
+	}
+
+	var b strings.Builder
+	b.WriteString("[")
+	if negate {
+		b.WriteString("^")
+		for _, s := range c.seps {
+			b.WriteString(quoteClass(s))
+		}
+	} else {
+		ranges = c.withoutSeps(ranges)
+		if len(ranges) == 0 {
+			return `[^\x00-\x{10FFFF}]`, nil
+		}
+	}
+	for _, r := range ranges {
+		b.WriteString(quoteClass(r[0]))
+		if r[1] != r[0] {
+			b.WriteString("-" + quoteClass(r[1]))
+		}
+	}
+	b.WriteString("]")
+	return b.String(), nil
+}
+
+func (c *compiler) classChar() (rune, error) {
+	r := c.src[c.pos]
+	if r == '\\' {
+		if c.pos+1 == len(c.src) {
+			return 0, errors.New("trailing backslash")
+		}
+		c.pos++
+		r = c.src[c.pos]
+	}
+	c.pos++
+	return r, nil
+}
+
+// withoutSeps splits ranges around the separators.
+func (c *compiler) withoutSeps(ranges [][2]rune) [][2]rune {
+	for _, s := range c.seps {
+		var out [][2]rune
+		for _, r := range ranges {
+			if s < r[0] || s > r[1] {
+				out = append(out, r)
+				continue
+			}
+			if r[0] < s {
+				out = append(out, [2]rune{r[0], s - 1})
+			}
+			if s < r[1] {
+				out = append(out, [2]rune{s + 1, r[1]})
+			}
+		}
+		ranges = out
+	}
+	return ranges
+}
+
+func (c *compiler) isSep(r rune) bool {
+	for _, s := range c.seps {
+		if r == s {
+			return true
+		}
+	}
+	return false
+}
+
+// anyChar matches one character other than a separator.
+func (c *compiler) anyChar() string {
+	if len(c.seps) == 0 {
+		return "."
+	}
+	return c.notSep()
+}
+
+func (c *compiler) notSep() string {
+	var b strings.Builder
+	b.WriteString("[^")
+	for _, s := range c.seps {
+		b.WriteString(quoteClass(s))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func quoteClass(r rune) string {
+	switch r {
+	case '\\', ']', '[', '^', '-':
+		return `\` + string(r)
+	}
+	return regexp.QuoteMeta(string(r))
+}
@@ -0,0 +1,216 @@
+package glob_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/glob"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		seps    string
+		s       string
+		want    bool
+	}{
+		// Commands and tool names: no separators.
+		{"*", "", "", true},
+		{"*", "", "anything at all", true},
+		{"git *", "", "git push origin/main", true},
+		{"git *", "", "git", false},
+		{"git *", "", "gitk", false},
+		{"rm -rf *", "", "rm -rf /\nreboot", true},
+		{"mcp_*", "", "mcp_github", true},
+		{"ba?h", "", "bash", true},
+		{"ba?h", "", "bah", false},
+		{"edit", "", "edit", true},
+		{"edit", "", "edits", false},
+		{"npm {install,ci}*", "", "npm ci --silent", true},
+		{"npm {install,ci}*", "", "npm publish", false},
+		{"{git,hg} {status,log{, *}}", "", "hg log -n 3", true},
+		{"{git,hg} {status,log{, *}}", "", "git log", true},
+		{"{git,hg} {status,log{, *}}", "", "git logs", false},
+		{"file[0-9].txt", "", "file7.txt", true},
+		{"file[!0-9].txt", "", "file7.txt", false},
+		{"file[^0-9].txt", "", "fileA.txt", true},
+		{"[]]", "", "]", true},
+		{"[a-]", "", "-", true},
+		{`\*`, "", "*", true},
+		{`\*`, "", "x", false},
+		{`echo \{a,b\}`, "", "echo {a,b}", true},
+		{"a}b]c,d", "", "a}b]c,d", true},
+		{"!rm *", "", "rm -rf /", false},
+		{"!rm *", "", "ls -la", true},
+		{`\!x`, "", "!x", true},
+		{"日本*", "", "日本語", true},
+
+		// Paths: '/' separates segments.
+		{"/workspace/src/**", "/", "/workspace/src", true},
+		{"/workspace/src/**", "/", "/workspace/src/a/b/c.go", true},
+		{"/workspace/src/**", "/", "/workspace/srcs", false},
+		{"/workspace/src/*", "/", "/workspace/src/a.go", true},
+		{"/workspace/src/*", "/", "/workspace/src/a/b.go", false},
+		{"/workspace/**/*.go", "/", "/workspace/a/b/c.go", true},
+		{"/workspace/**/*.go", "/", "/workspace/c.go", true},
+		{"/workspace/**/*.go", "/", "/workspace/c.ts", false},
+		{"**/.git/**", "/", ".git/config", true},
+		{"**/.git/**", "/", "a/b/.git", true},
+		{"/workspace/a**", "/", "/workspace/abc/def", true},
+		{"/workspace/?", "/", "/workspace//", false},
+		{"/workspace/[!a]", "/", "/workspace//", false},
+		{"/workspace[/]x", "/", "/workspace/x", false},
+		{"/workspace/{src,test}/**", "/", "/workspace/test/x", true},
+		{"/workspace/{src/**,docs}", "/", "/workspace/src", true},
+		{"/workspace/{src/**,docs}", "/", "/workspace/docsx", false},
+		{"!/workspace/**", "/", "/etc/passwd", true},
+		{"!/workspace/**", "/", "/workspace/a", false},
+	}
+	for _, tt := range tests {
+		g, err := glob.Compile(tt.pattern, []rune(tt.seps)...)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := g.Match(tt.s); got != tt.want {
+			t.Errorf("%q (seps %q) Match(%q) = %v, want %v", tt.pattern, tt.seps, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{"[abc", "{a,b", "a{b{c}", `trailing\`, "[z-a]", "[]"} {
+		if _, err := glob.Compile(pattern); !errors.Is(err, glob.ErrBadPattern) {
+			t.Errorf("Compile(%q) error = %v, want ErrBadPattern", pattern, err)
+		}
+	}
+}
+
+func TestIsWildcard(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"git *": true, "/workspace/**": true, "ba?h": true,
+		"!git *": false, "npm {i,ci}": false, "file[0-9]": false, `a\*`: false,
+	} {
+		if got := glob.IsWildcard(pattern); got != want {
+			t.Errorf("IsWildcard(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := glob.NewCache(2)
+	if !c.Match("rule1", "git *", "git status") {
+		t.Fatal("expected match")
+	}
+	// A changed pattern under the same key is recompiled.
+	if c.Match("rule1", "npm *", "git status") {
+		t.Error("stale pattern served from cache")
+	}
+	if _, err := c.Get("bad", "[oops"); err == nil {
+		t.Error("expected compile error")
+	}
+	c.Invalidate("rule1")
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d after invalidate, want 1", c.Len())
+	}
+	c.Get("rule2", "*")
+	c.Get("rule3", "*")
+	if c.Len() > 2 {
+		t.Errorf("Len() = %d, want at most the limit", c.Len())
+	}
+}
+
+// FuzzMatch checks that compiling never panics, that negation is an exact
+// complement, and that an escaped literal always matches itself.
+func FuzzMatch(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"git *", "git push"}, {"/workspace/**/*.go", "/workspace/a/b.go"},
+		{"{a,{b,c}}[!x-z]?", "cq1"}, {"!**", ""}, {`[\]-]`, "]"}, {"a/**", "a"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, pattern, s string) {
+		for _, seps := range [][]rune{nil, {'/'}} {
+			g, err := glob.Compile(pattern, seps...)
+			if err != nil {
+				continue
+			}
+			neg, err := glob.Compile("!"+pattern, seps...)
+			if err != nil {
+				if strings.HasPrefix(pattern, "!") {
+					continue
+				}
+				t.Fatalf("%q compiles but its negation does not: %v", pattern, err)
+			}
+			if !strings.HasPrefix(pattern, "!") && g.Match(s) == neg.Match(s) {
+				t.Fatalf("%q and its negation agree on %q", pattern, s)
+			}
+		}
+
+		if !utf8.ValidString(s) {
+			return
+		}
+		escaped := escape(s)
+		if g, err := glob.Compile(escaped, '/'); err != nil || !g.Match(s) {
+			t.Fatalf("escaped literal %q does not match %q (err %v)", escaped, s, err)
+		}
+	})
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\*?[]{},!`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var benchPatterns = func() []string {
+	patterns := []string{"git *", "npm {install,ci} *", "rm -rf *", "docker compose *", "[a-z]*-test *"}
+	for i := 0; i < 95; i++ {
+		patterns = append(patterns, fmt.Sprintf("tool%d --flag-%d *", i, i))
+	}
+	return patterns
+}()
+
+const benchInput = "npm ci --prefer-offline --no-audit"
+
+// BenchmarkCachedMatch is the evaluator's steady state: every rule compiled once.
+func BenchmarkCachedMatch(b *testing.B) {
+	c := glob.NewCache(0)
+	keys := make([]string, len(benchPatterns))
+	for i := range keys {
+		keys[i] = fmt.Sprint("rule", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, p := range benchPatterns {
+			c.Match(keys[j], p, benchInput)
+		}
+	}
+}
+
+// BenchmarkRegexpPerMatch is the previous approach: a regexp built per rule per request.
+func BenchmarkRegexpPerMatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, p := range benchPatterns {
+			escaped := strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+			regexp.MustCompile("(?s)^" + escaped + "$").MatchString(benchInput)
+		}
+	}
+}
+
+func BenchmarkCompile(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := glob.Compile("/workspace/{src,test}/**/[a-z]*.{go,ts}", '/'); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
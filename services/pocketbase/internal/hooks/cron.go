@@ -23,10 +23,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/schedule"
 )
 
 const cronJobPrefix = "pc_cron_"
@@ -46,6 +48,47 @@ func RegisterCronHooks(app core.App) {
 		return e.Next()
 	})
 
+	// Reject unparseable expressions and unknown timezones up front and store
+	// the normalized form, so a bad schedule never reaches the scheduler. A
+	// job without a timezone takes its owner's, and skips overlapping runs
+	// unless told otherwise.
+	validateSchedule := func(e *core.RecordRequestEvent) error {
+		sched, err := schedule.Parse(e.Record.GetString("cron_expression"))
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		e.Record.Set("cron_expression", sched.String())
+
+		tz := e.Record.GetString("timezone")
+		if tz == "" {
+			tz = UserTimezone(app, e.Record.GetString("user"))
+		}
+		if _, err := time.LoadLocation(tz); err != nil {
+			return e.BadRequestError(fmt.Sprintf("invalid timezone %q (use an IANA name such as Europe/Berlin)", tz), nil)
+		}
+		e.Record.Set("timezone", tz)
+
+		if e.Record.GetString("overlap_policy") == "" {
+			e.Record.Set("overlap_policy", DefaultOverlapPolicy(e.Record.GetString("session_mode")))
+		}
+		return e.Next()
+	}
+	app.OnRecordCreateRequest("cron_jobs").BindFunc(validateSchedule)
+	app.OnRecordUpdateRequest("cron_jobs").BindFunc(validateSchedule)
+
+	validateUserTimezone := func(e *core.RecordRequestEvent) error {
+		if tz := e.Record.GetString("timezone"); tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				return e.BadRequestError(fmt.Sprintf("invalid timezone %q (use an IANA name such as Europe/Berlin)", tz), nil)
+			}
+		}
+		return e.Next()
+	}
+	app.OnRecordCreateRequest("users").BindFunc(validateUserTimezone)
+	app.OnRecordUpdateRequest("users").BindFunc(validateUserTimezone)
+
+	registerCronRunHooks(app)
+
 	// On create/update: re-sync the affected job
 	app.OnRecordAfterCreateSuccess("cron_jobs").BindFunc(func(e *core.RecordEvent) error {
 		syncCronJob(app, e.Record)
@@ -100,78 +143,303 @@ func syncCronJob(app core.App, record *core.Record) {
 		return
 	}
 
-	cronExpr := record.GetString("cron_expression")
-	if cronExpr == "" {
-		log.Printf("⚠️ [Cron] Job '%s' has empty cron expression, skipping", jobName)
+	sched, err := schedule.Parse(record.GetString("cron_expression"))
+	if err != nil {
+		log.Printf("⚠️ [Cron] Job '%s' skipped: %v", jobName, err)
 		return
 	}
+	loc := CronJobLocation(record)
 
+	// The scheduler only reads expressions in UTC, so the job checks every
+	// minute whether its own expression is due in its own timezone. The same
+	// tick starts a queued run once the job is free again.
 	recordID := record.Id
-	if err := app.Cron().Add(jobID, cronExpr, func() {
-		executeCronJob(app, recordID)
+	if err := app.Cron().Add(jobID, "* * * * *", func() {
+		resumeQueuedCronRun(app, recordID)
+		if sched.Due(time.Now().In(loc)) {
+			executeCronJob(app, recordID, TriggerSchedule)
+		}
 	}); err != nil {
 		log.Printf("❌ [Cron] Failed to register job '%s': %v", jobName, err)
 		return
 	}
 
-	log.Printf("⏰ [Cron] Registered job '%s' with schedule '%s'", jobName, cronExpr)
+	log.Printf("⏰ [Cron] Registered job '%s' with schedule '%s' (%s)", jobName, sched.String(), loc)
+}
+
+// UserTimezone returns the user's preferred timezone, or UTC if they have
+// none or it is invalid.
+func UserTimezone(app core.App, userID string) string {
+	if userID == "" {
+		return "UTC"
+	}
+	user, err := app.FindRecordById("users", userID)
+	if err != nil {
+		return "UTC"
+	}
+	tz := user.GetString("timezone")
+	if _, err := time.LoadLocation(tz); err != nil || tz == "" {
+		return "UTC"
+	}
+	return tz
 }
 
+// CronJobLocation returns the timezone a cron job's expression is read in.
+func CronJobLocation(record *core.Record) *time.Location {
+	loc, err := time.LoadLocation(record.GetString("timezone"))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Overlap policies: what a job does when it fires while its previous run, or
+// the chat it posts into, is still busy.
+const (
+	OverlapSkip    = "skip"
+	OverlapQueue   = "queue"
+	OverlapNewChat = "new_chat"
+)
+
+// DefaultOverlapPolicy is the overlap policy of a job that sets none: a job
+// in "new" mode starts a fresh chat as it always has, any other job skips.
+func DefaultOverlapPolicy(sessionMode string) string {
+	if sessionMode == "new" {
+		return OverlapNewChat
+	}
+	return OverlapSkip
+}
+
+// Bounds of how long a run may wait for its reply before it no longer holds
+// up the job. Within them, a run waits until the job's next fire.
+const (
+	cronRunMinTimeout = 15 * time.Minute
+	cronRunMaxTimeout = 12 * time.Hour
+)
+
+// cronRunTimeout returns how long a run of job triggered at triggered may
+// wait for its reply: until the job's next fire after it, within
+// cronRunMinTimeout and cronRunMaxTimeout.
+func cronRunTimeout(jobRecord *core.Record, triggered time.Time) time.Duration {
+	timeout := cronRunMaxTimeout
+	if sched, err := schedule.Parse(jobRecord.GetString("cron_expression")); err == nil {
+		if next, ok := sched.Next(triggered.In(CronJobLocation(jobRecord))); ok {
+			timeout = next.Sub(triggered)
+		}
+	}
+	return min(max(timeout, cronRunMinTimeout), cronRunMaxTimeout)
+}
+
+// cronDispatchMu serializes starting runs, so a scheduled fire and a queued
+// run released by a finished reply cannot both post into the same chat.
+var cronDispatchMu sync.Mutex
+
+// Run triggers: the job's schedule, or a manual run-now request.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
 // executeCronJob is the handler called when a cron job fires.
 // It creates a message in an existing chat or creates a new chat + message,
-// depending on the job's session_mode.
-func executeCronJob(app core.App, jobRecordID string) {
+// depending on the job's session_mode. If the job is still busy, its
+// overlap_policy decides whether the run is skipped, queued, or moved to a
+// new chat. It returns the recorded run, if any.
+func executeCronJob(app core.App, jobRecordID string, trigger string) *core.Record {
+	cronDispatchMu.Lock()
+	defer cronDispatchMu.Unlock()
+
 	// Re-fetch the record to get the latest state
 	jobRecord, err := app.FindRecordById("cron_jobs", jobRecordID)
 	if err != nil {
 		log.Printf("❌ [Cron] Failed to fetch job record %s: %v", jobRecordID, err)
-		return
+		return nil
 	}
 
-	if !jobRecord.GetBool("enabled") {
+	// A manual run is how a paused job gets tested, so only the schedule
+	// respects enabled.
+	if trigger == TriggerSchedule && !jobRecord.GetBool("enabled") {
 		log.Printf("⏰ [Cron] Job '%s' is disabled, skipping execution", jobRecord.GetString("name"))
+		return nil
+	}
+
+	jobName := jobRecord.GetString("name")
+	log.Printf("⏰ [Cron] Executing job '%s' (mode: %s, trigger: %s)", jobName, jobRecord.GetString("session_mode"), trigger)
+
+	newChat := false
+	if busy, why := cronJobBusy(app, jobRecord); busy {
+		switch overlapPolicy(jobRecord) {
+		case OverlapQueue:
+			if queued := queuedCronRun(app, jobRecord.Id); queued != nil {
+				log.Printf("⏭️ [Cron] Job '%s' skipped, a run is already queued: %s", jobName, why)
+				return startCronRun(app, jobRecord, trigger, CronRunSkipped, "a run is already queued: "+why)
+			}
+			log.Printf("⏳ [Cron] Job '%s' queued: %s", jobName, why)
+			return startCronRun(app, jobRecord, trigger, CronRunQueued, why)
+		case OverlapNewChat:
+			newChat = true
+			log.Printf("⏰ [Cron] Job '%s' starting in a new chat: %s", jobName, why)
+		default:
+			log.Printf("⏭️ [Cron] Job '%s' skipped: %s", jobName, why)
+			return startCronRun(app, jobRecord, trigger, CronRunSkipped, why)
+		}
+	}
+
+	run := startCronRun(app, jobRecord, trigger, CronRunRunning, "")
+	dispatchCronRun(app, jobRecord, run, newChat)
+	return run
+}
+
+// RunCronJobNow runs a job immediately, paused or not, as a manual trigger.
+// The job's overlap_policy still applies. It returns the recorded run.
+func RunCronJobNow(app core.App, jobRecordID string) (*core.Record, error) {
+	run := executeCronJob(app, jobRecordID, TriggerManual)
+	if run == nil {
+		return nil, fmt.Errorf("failed to record a run of job %s", jobRecordID)
+	}
+	return run, nil
+}
+
+// resumeQueuedCronRun starts the queued run of a job once the job is no longer
+// busy. A run queued behind a job that has since been disabled is skipped.
+func resumeQueuedCronRun(app core.App, jobRecordID string) {
+	cronDispatchMu.Lock()
+	defer cronDispatchMu.Unlock()
+
+	run := queuedCronRun(app, jobRecordID)
+	if run == nil {
+		return
+	}
+	jobRecord, err := app.FindRecordById("cron_jobs", jobRecordID)
+	if err != nil {
+		return
+	}
+	if !jobRecord.GetBool("enabled") {
+		finishCronRun(app, run, CronRunSkipped, "the job was disabled while the run was queued")
+		return
+	}
+	if busy, _ := cronJobBusy(app, jobRecord); busy {
 		return
 	}
 
+	run.Set("status", CronRunRunning)
+	run.Set("error", "")
+	if err := app.Save(run); err != nil {
+		log.Printf("⚠️ [Cron] Failed to start queued run %s: %v", run.Id, err)
+		return
+	}
+	log.Printf("⏰ [Cron] Starting queued run of job '%s'", jobRecord.GetString("name"))
+	dispatchCronRun(app, jobRecord, run, false)
+}
+
+// dispatchCronRun posts the job's prompt for run, into the linked chat or,
+// for session_mode=new or when newChat is set, into a new chat.
+func dispatchCronRun(app core.App, jobRecord *core.Record, run *core.Record, newChat bool) {
 	jobName := jobRecord.GetString("name")
 	prompt := jobRecord.GetString("prompt")
 	sessionMode := jobRecord.GetString("session_mode")
 	userID := jobRecord.GetString("user")
 
-	log.Printf("⏰ [Cron] Executing job '%s' (mode: %s)", jobName, sessionMode)
-
 	var chatID string
 	var execErr error
 
-	switch sessionMode {
-	case "existing":
+	switch {
+	case sessionMode != "existing" && sessionMode != "new":
+		execErr = fmt.Errorf("unknown session_mode: %s", sessionMode)
+	case sessionMode == "new" || newChat:
+		chatID, execErr = createCronChat(app, jobRecord, userID)
+	default:
 		chatID = jobRecord.GetString("chat")
 		if chatID == "" {
 			execErr = fmt.Errorf("session_mode is 'existing' but no chat is linked")
 		}
-	case "new":
-		chatID, execErr = createCronChat(app, jobRecord, userID)
-	default:
-		execErr = fmt.Errorf("unknown session_mode: %s", sessionMode)
 	}
 
 	if execErr != nil {
 		updateCronJobStatus(app, jobRecord, "error", execErr.Error())
+		finishCronRun(app, run, CronRunFailed, execErr.Error())
 		log.Printf("❌ [Cron] Job '%s' failed: %v", jobName, execErr)
 		return
 	}
 
+	// Link the run to its chat first, so it can be traced even if the
+	// prompt cannot be created.
+	if run != nil {
+		run.Set("chat", chatID)
+		if err := app.Save(run); err != nil {
+			log.Printf("⚠️ [Cron] Failed to link run %s to chat %s: %v", run.Id, chatID, err)
+		}
+	}
+
 	// Create the message in the target chat
-	if err := createCronMessage(app, chatID, prompt); err != nil {
+	messageID, err := createCronMessage(app, chatID, prompt)
+	if err != nil {
 		updateCronJobStatus(app, jobRecord, "error", err.Error())
+		finishCronRun(app, run, CronRunFailed, err.Error())
 		log.Printf("❌ [Cron] Job '%s' failed to create message: %v", jobName, err)
 		return
 	}
+	if run != nil {
+		run.Set("message", messageID)
+		if err := app.Save(run); err != nil {
+			log.Printf("⚠️ [Cron] Failed to link run %s to message %s: %v", run.Id, messageID, err)
+		}
+	}
 
 	updateCronJobStatus(app, jobRecord, "ok", "")
 	log.Printf("✅ [Cron] Job '%s' executed successfully (chat: %s)", jobName, chatID)
 }
 
+// cronJobBusy reports whether a job is still busy, and why: its previous run
+// has not been answered yet, or the chat it posts into is mid-reply. Runs
+// left without a reply past their cronRunTimeout are failed instead, and a
+// reply that has not been updated for cronRunMinTimeout no longer counts.
+func cronJobBusy(app core.App, jobRecord *core.Record) (bool, string) {
+	runs, err := app.FindRecordsByFilter(
+		"cron_runs",
+		"job = {:job} && status = 'running'",
+		"triggered_at", 0, 0,
+		map[string]any{"job": jobRecord.Id},
+	)
+	if err == nil {
+		for _, run := range runs {
+			triggered := run.GetDateTime("triggered_at").Time()
+			if timeout := cronRunTimeout(jobRecord, triggered); time.Since(triggered) > timeout {
+				finishCronRun(app, run, CronRunFailed, fmt.Sprintf("no reply within %s", timeout))
+				continue
+			}
+			return true, "the previous run is still in progress"
+		}
+	}
+
+	chatID := jobRecord.GetString("chat")
+	if jobRecord.GetString("session_mode") != "existing" || chatID == "" {
+		return false, ""
+	}
+	if chat, err := app.FindRecordById("chats", chatID); err == nil && chat.GetString("turn") == "assistant" {
+		return true, "the linked chat is busy"
+	}
+	if _, err := app.FindFirstRecordByFilter(
+		"messages",
+		"chat = {:chat} && role = 'assistant' && engine_message_status = 'processing' && updated >= {:since}",
+		map[string]any{"chat": chatID, "since": time.Now().UTC().Add(-cronRunMinTimeout).Format("2006-01-02 15:04:05.000Z")},
+	); err == nil {
+		return true, "the linked chat is busy"
+	}
+	return false, ""
+}
+
+// overlapPolicy returns the job's overlap policy, or DefaultOverlapPolicy
+// when it has none.
+func overlapPolicy(jobRecord *core.Record) string {
+	switch p := jobRecord.GetString("overlap_policy"); p {
+	case OverlapSkip, OverlapQueue, OverlapNewChat:
+		return p
+	default:
+		return DefaultOverlapPolicy(jobRecord.GetString("session_mode"))
+	}
+}
+
 // createCronChat creates a new chat for a cron job execution.
 func createCronChat(app core.App, jobRecord *core.Record, userID string) (string, error) {
 	chatsCollection, err := app.FindCollectionByNameOrId("chats")
@@ -183,6 +451,7 @@ func createCronChat(app core.App, jobRecord *core.Record, userID string) (string
 	chatRecord.Set("title", fmt.Sprintf("%s — %s", jobRecord.GetString("name"), time.Now().Format("Jan 2 15:04")))
 	chatRecord.Set("user", userID)
 	chatRecord.Set("turn", "user")
+	chatRecord.Set("cron_job", jobRecord.Id)
 
 	agentID := jobRecord.GetString("agent")
 	if agentID != "" {
@@ -196,11 +465,11 @@ func createCronChat(app core.App, jobRecord *core.Record, userID string) (string
 	return chatRecord.Id, nil
 }
 
-// createCronMessage creates a user message in the target chat.
-func createCronMessage(app core.App, chatID string, prompt string) error {
+// createCronMessage creates a user message in the target chat and returns its ID.
+func createCronMessage(app core.App, chatID string, prompt string) (string, error) {
 	messagesCollection, err := app.FindCollectionByNameOrId("messages")
 	if err != nil {
-		return fmt.Errorf("failed to find messages collection: %w", err)
+		return "", fmt.Errorf("failed to find messages collection: %w", err)
 	}
 
 	parts := []map[string]string{
@@ -211,7 +480,7 @@ func createCronMessage(app core.App, chatID string, prompt string) error {
 	}
 	partsJSON, err := json.Marshal(parts)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message parts: %w", err)
+		return "", fmt.Errorf("failed to marshal message parts: %w", err)
 	}
 
 	msgRecord := core.NewRecord(messagesCollection)
@@ -221,10 +490,10 @@ func createCronMessage(app core.App, chatID string, prompt string) error {
 	msgRecord.Set("parts", string(partsJSON))
 
 	if err := app.Save(msgRecord); err != nil {
-		return fmt.Errorf("failed to create message: %w", err)
+		return "", fmt.Errorf("failed to create message: %w", err)
 	}
 
-	return nil
+	return msgRecord.Id, nil
 }
 
 // updateCronJobStatus updates the last_executed, last_status, and last_error fields.
@@ -0,0 +1,255 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Cron Runs. Records every cron job execution and settles it when the assistant's reply finishes.
+package hooks
+
+import (
+	"log"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Cron run statuses. A run stays running until the assistant's reply to its
+// prompt reaches a terminal engine_message_status, or the chat's turn returns
+// to the user without one. A queued run waits for the job to be free; a
+// skipped one never started.
+const (
+	CronRunQueued    = "queued"
+	CronRunRunning   = "running"
+	CronRunCompleted = "completed"
+	CronRunFailed    = "failed"
+	CronRunAborted   = "aborted"
+	CronRunSkipped   = "skipped"
+)
+
+// registerCronRunHooks settles running cron runs from the messages they
+// produce: the assistant's reply finishing, or the prompt failing delivery.
+// A run whose chat goes idle without a finished reply fails.
+func registerCronRunHooks(app core.App) {
+	settle := func(e *core.RecordEvent) error {
+		settleCronRunFromMessage(app, e.Record)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("messages").BindFunc(settle)
+	app.OnRecordAfterUpdateSuccess("messages").BindFunc(settle)
+
+	app.OnRecordAfterUpdateSuccess("chats").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Original().GetString("turn") == "assistant" && e.Record.GetString("turn") == "user" {
+			settleCronRunsOfIdleChat(app, e.Record.Id)
+		}
+		return e.Next()
+	})
+}
+
+// startCronRun records an execution of job in status. note explains a queued
+// or skipped run; a skipped run is finished as soon as it is recorded.
+func startCronRun(app core.App, job *core.Record, trigger, status, note string) *core.Record {
+	collection, err := app.FindCollectionByNameOrId("cron_runs")
+	if err != nil {
+		log.Printf("⚠️ [Cron] Failed to find cron_runs collection: %v", err)
+		return nil
+	}
+
+	run := core.NewRecord(collection)
+	run.Set("job", job.Id)
+	run.Set("user", job.GetString("user"))
+	run.Set("trigger", trigger)
+	now := types.NowDateTime()
+	run.Set("triggered_at", now)
+	run.Set("status", status)
+	run.Set("error", note)
+	if status == CronRunSkipped {
+		run.Set("finished_at", now)
+		run.Set("duration_ms", 0)
+	}
+	if err := app.Save(run); err != nil {
+		log.Printf("⚠️ [Cron] Failed to record run of '%s': %v", job.GetString("name"), err)
+		return nil
+	}
+	return run
+}
+
+// finishCronRun settles a running or queued run with status. Runs that are
+// already settled are left alone.
+func finishCronRun(app core.App, run *core.Record, status, errMsg string) {
+	if run == nil {
+		return
+	}
+	if current := run.GetString("status"); current != CronRunRunning && current != CronRunQueued {
+		return
+	}
+
+	now := time.Now().UTC()
+	run.Set("status", status)
+	run.Set("error", errMsg)
+	run.Set("finished_at", now)
+	run.Set("duration_ms", now.Sub(run.GetDateTime("triggered_at").Time()).Milliseconds())
+	if err := app.Save(run); err != nil {
+		log.Printf("⚠️ [Cron] Failed to settle run %s: %v", run.Id, err)
+		return
+	}
+	log.Printf("⏰ [Cron] Run %s finished: %s", run.Id, status)
+}
+
+// settleCronRunFromMessage settles the running run a message belongs to. An
+// assistant message in a terminal state settles the oldest running run of its
+// chat whose prompt was posted before the message was created, and lets
+// queued runs start; a cron prompt that could not be delivered fails its own
+// run.
+func settleCronRunFromMessage(app core.App, msg *core.Record) {
+	switch msg.GetString("role") {
+	case "assistant":
+		status, errMsg, ok := replyOutcome(msg)
+		if !ok {
+			return
+		}
+
+		runs, err := app.FindRecordsByFilter(
+			"cron_runs",
+			"chat = {:chat} && status = 'running' && message != '' && message.created <= {:created}",
+			"triggered_at", 1, 0,
+			map[string]any{"chat": msg.GetString("chat"), "created": msg.GetString("created")},
+		)
+		if err == nil && len(runs) > 0 {
+			runs[0].Set("reply", msg.Id)
+			finishCronRun(app, runs[0], status, errMsg)
+		}
+		resumeQueuedCronRuns(app)
+
+	case "user":
+		if msg.GetString("user_message_status") != "failed" {
+			return
+		}
+		run, err := app.FindFirstRecordByFilter(
+			"cron_runs",
+			"message = {:message} && status = 'running'",
+			map[string]any{"message": msg.Id},
+		)
+		if err != nil {
+			return
+		}
+		finishCronRun(app, run, CronRunFailed, "the prompt could not be delivered to the agent")
+	}
+}
+
+// settleCronRunsOfIdleChat settles the running runs of a chat whose turn just
+// returned to the user. The agent is done with every prompt it was handed by
+// then, so a run whose prompt was delivered is settled from its finished
+// reply, or failed if there is none.
+func settleCronRunsOfIdleChat(app core.App, chatID string) {
+	runs, err := app.FindRecordsByFilter(
+		"cron_runs",
+		"chat = {:chat} && status = 'running' && message != '' && (message.user_message_status = 'sending' || message.user_message_status = 'delivered')",
+		"triggered_at", 0, 0,
+		map[string]any{"chat": chatID},
+	)
+	if err != nil {
+		return
+	}
+	for _, run := range runs {
+		prompt, err := app.FindRecordById("messages", run.GetString("message"))
+		if err != nil {
+			continue
+		}
+		replies, err := app.FindRecordsByFilter(
+			"messages",
+			"chat = {:chat} && role = 'assistant' && created >= {:created}",
+			"-created", 1, 0,
+			map[string]any{"chat": chatID, "created": prompt.GetString("created")},
+		)
+		if err == nil && len(replies) > 0 {
+			if status, errMsg, ok := replyOutcome(replies[0]); ok {
+				run.Set("reply", replies[0].Id)
+				finishCronRun(app, run, status, errMsg)
+				continue
+			}
+		}
+		finishCronRun(app, run, CronRunFailed, "the agent went idle without finishing a reply")
+	}
+	resumeQueuedCronRuns(app)
+}
+
+// replyOutcome maps an assistant message onto the run status it settles, if
+// its engine_message_status is terminal.
+func replyOutcome(msg *core.Record) (status, errMsg string, ok bool) {
+	switch msg.GetString("engine_message_status") {
+	case "completed":
+		return CronRunCompleted, "", true
+	case "failed":
+		return CronRunFailed, assistantError(msg), true
+	case "aborted":
+		return CronRunAborted, "the assistant's reply was aborted", true
+	default:
+		return "", "", false
+	}
+}
+
+// assistantError extracts a readable error from a failed assistant message.
+func assistantError(msg *core.Record) string {
+	var payload struct {
+		Message string `json:"message"`
+		Data    struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	}
+	if err := msg.UnmarshalJSONField("error_payload", &payload); err == nil {
+		if payload.Message != "" {
+			return payload.Message
+		}
+		if payload.Data.Message != "" {
+			return payload.Data.Message
+		}
+	}
+	if domain := msg.GetString("error_domain"); domain != "" {
+		return "the assistant's reply failed (" + domain + " error)"
+	}
+	return "the assistant's reply failed"
+}
+
+// queuedCronRun returns the oldest queued run of a job, or nil.
+func queuedCronRun(app core.App, jobID string) *core.Record {
+	runs, err := app.FindRecordsByFilter(
+		"cron_runs",
+		"job = {:job} && status = 'queued'",
+		"triggered_at", 1, 0,
+		map[string]any{"job": jobID},
+	)
+	if err != nil || len(runs) == 0 {
+		return nil
+	}
+	return runs[0]
+}
+
+// resumeQueuedCronRuns starts every queued run whose job is free again.
+func resumeQueuedCronRuns(app core.App) {
+	runs, err := app.FindRecordsByFilter("cron_runs", "status = 'queued'", "triggered_at", 0, 0)
+	if err != nil {
+		return
+	}
+	seen := map[string]bool{}
+	for _, run := range runs {
+		jobID := run.GetString("job")
+		if !seen[jobID] {
+			seen[jobID] = true
+			resumeQueuedCronRun(app, jobID)
+		}
+	}
+}
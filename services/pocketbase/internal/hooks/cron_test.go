@@ -0,0 +1,203 @@
+package hooks_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+)
+
+// newCronApp returns a test app with the PocketCoder schema, the timestamp
+// hooks and the cron hooks registered.
+func newCronApp(t testing.TB) *tests.TestApp {
+	t.Helper()
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+	hooks.RegisterGlobalTimestamps(app)
+	hooks.RegisterCronHooks(app)
+	return app
+}
+
+// newCronJob creates an enabled job that posts into a chat of its own,
+// in session_mode existing, and returns the job and the chat.
+func newCronJob(t testing.TB, app core.App, expr, overlap string) (*core.Record, *core.Record) {
+	t.Helper()
+	user, _ := newUser(t, app, "user")
+	chats, err := app.FindCollectionByNameOrId("chats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := core.NewRecord(chats)
+	chat.Set("title", "nightly")
+	chat.Set("user", user.Id)
+	chat.Set("turn", "user")
+	if err := app.Save(chat); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := app.FindCollectionByNameOrId("cron_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := core.NewRecord(jobs)
+	job.Set("name", "nightly")
+	job.Set("cron_expression", expr)
+	job.Set("prompt", "run the tests")
+	job.Set("session_mode", "existing")
+	job.Set("chat", chat.Id)
+	job.Set("user", user.Id)
+	job.Set("enabled", true)
+	job.Set("timezone", "UTC")
+	job.Set("overlap_policy", overlap)
+	if err := app.Save(job); err != nil {
+		t.Fatal(err)
+	}
+	return job, chat
+}
+
+// runNow runs job manually and returns the recorded run.
+func runNow(t testing.TB, app core.App, job *core.Record) *core.Record {
+	t.Helper()
+	run, err := hooks.RunCronJobNow(app, job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// reload returns the stored state of a record.
+func reload(t testing.TB, app core.App, record *core.Record) *core.Record {
+	t.Helper()
+	saved, err := app.FindRecordById(record.Collection().Name, record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saved
+}
+
+// takeTurn plays the relay: it delivers the run's prompt, hands the chat to
+// the assistant and, after reply runs, returns the turn to the user.
+func takeTurn(t testing.TB, app core.App, chat, run *core.Record, reply func()) {
+	t.Helper()
+	prompt, err := app.FindRecordById("messages", reload(t, app, run).GetString("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt.Set("user_message_status", "delivered")
+	if err := app.Save(prompt); err != nil {
+		t.Fatal(err)
+	}
+	chat = reload(t, app, chat)
+	chat.Set("turn", "assistant")
+	if err := app.Save(chat); err != nil {
+		t.Fatal(err)
+	}
+	if reply != nil {
+		reply()
+	}
+	chat = reload(t, app, chat)
+	chat.Set("turn", "user")
+	if err := app.Save(chat); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertRun(t testing.TB, app core.App, run *core.Record, status, errPrefix string) {
+	t.Helper()
+	saved := reload(t, app, run)
+	if got := saved.GetString("status"); got != status {
+		t.Errorf("run status = %q, want %q (%s)", got, status, saved.GetString("error"))
+	}
+	if got := saved.GetString("error"); !strings.HasPrefix(got, errPrefix) {
+		t.Errorf("run error = %q, want prefix %q", got, errPrefix)
+	}
+}
+
+func TestCronOverlapWhileRunning(t *testing.T) {
+	app := newCronApp(t)
+	job, chat := newCronJob(t, app, "* * * * *", hooks.OverlapSkip)
+
+	first := runNow(t, app, job)
+	assertRun(t, app, first, hooks.CronRunRunning, "")
+
+	second := runNow(t, app, job)
+	assertRun(t, app, second, hooks.CronRunSkipped, "the previous run is still in progress")
+
+	// The agent goes idle without ever writing a reply.
+	takeTurn(t, app, chat, first, nil)
+	assertRun(t, app, first, hooks.CronRunFailed, "the agent went idle")
+
+	third := runNow(t, app, job)
+	assertRun(t, app, third, hooks.CronRunRunning, "")
+}
+
+func TestCronQueuedRunStartsWhenTheChatGoesIdle(t *testing.T) {
+	app := newCronApp(t)
+	job, chat := newCronJob(t, app, "* * * * *", hooks.OverlapQueue)
+
+	first := runNow(t, app, job)
+	queued := runNow(t, app, job)
+	assertRun(t, app, queued, hooks.CronRunQueued, "the previous run is still in progress")
+
+	// The reply was left processing a while ago when the agent goes idle.
+	takeTurn(t, app, chat, first, func() {
+		messages, err := app.FindCollectionByNameOrId("messages")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := core.NewRecord(messages)
+		reply.Set("chat", chat.Id)
+		reply.Set("role", "assistant")
+		reply.Set("engine_message_status", "processing")
+		if err := app.Save(reply); err != nil {
+			t.Fatal(err)
+		}
+		stale := time.Now().UTC().Add(-20 * time.Minute).Format("2006-01-02 15:04:05.000Z")
+		if _, err := app.DB().NewQuery("UPDATE messages SET updated = {:updated} WHERE id = {:id}").
+			Bind(dbx.Params{"updated": stale, "id": reply.Id}).Execute(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assertRun(t, app, first, hooks.CronRunFailed, "the agent went idle")
+	assertRun(t, app, queued, hooks.CronRunRunning, "")
+}
+
+func TestCronRunTimesOutWithTheJob(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		age  time.Duration
+		want string
+	}{
+		{"within the minimum", "* * * * *", 10 * time.Minute, hooks.CronRunRunning},
+		{"past the minimum", "* * * * *", 20 * time.Minute, hooks.CronRunFailed},
+		{"within the maximum", "0 0 29 2 *", 11 * time.Hour, hooks.CronRunRunning},
+		{"past the maximum", "0 0 29 2 *", 13 * time.Hour, hooks.CronRunFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newCronApp(t)
+			job, _ := newCronJob(t, app, tt.expr, hooks.OverlapSkip)
+
+			run := runNow(t, app, job)
+			run = reload(t, app, run)
+			run.Set("triggered_at", time.Now().UTC().Add(-tt.age))
+			if err := app.Save(run); err != nil {
+				t.Fatal(err)
+			}
+
+			runNow(t, app, job)
+			assertRun(t, app, run, tt.want, map[string]string{
+				hooks.CronRunRunning: "",
+				hooks.CronRunFailed:  "no reply within",
+			}[tt.want])
+		})
+	}
+}
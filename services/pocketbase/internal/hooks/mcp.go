@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
 const (
@@ -47,6 +48,38 @@ const (
 func RegisterMcpHooks(app core.App, openCodeURL string) {
 	log.Println("🔌 [MCP] Registering MCP server hooks...")
 
+	// Record the tool list as approved, so tool_permissions can gate the
+	// server's tools one by one as mcp:<server>/<tool>.
+	app.OnRecordUpdate("mcp_servers").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("status") != "approved" || e.Record.Original().GetString("status") == "approved" {
+			return e.Next()
+		}
+		tools := permission.McpToolsFromRecord(e.Record)
+		e.Record.Set("tools", tools)
+		if len(tools) == 0 {
+			log.Printf("⚠️ [MCP] Server '%s' approved without a tool list, its tools only match plain rules", e.Record.GetString("name"))
+		} else {
+			log.Printf("🔌 [MCP] Recorded %d tools for server '%s'", len(tools), e.Record.GetString("name"))
+		}
+		return e.Next()
+	})
+
+	// Rules gate an approved server's tools by that list, so once approved
+	// only admins may change it.
+	app.OnRecordUpdateRequest("mcp_servers").BindFunc(func(e *core.RecordRequestEvent) error {
+		original := e.Record.Original()
+		if original.GetString("status") != "approved" || e.HasSuperuserAuth() ||
+			(e.Auth != nil && e.Auth.GetString("role") == "admin") {
+			return e.Next()
+		}
+		before, _ := json.Marshal(permission.McpToolsFromRecord(original))
+		after, _ := json.Marshal(permission.McpToolsFromRecord(e.Record))
+		if string(before) != string(after) {
+			return e.ForbiddenError("Only admins can change the tools of an approved MCP server.", nil)
+		}
+		return e.Next()
+	})
+
 	app.OnRecordAfterUpdateSuccess("mcp_servers").BindFunc(func(e *core.RecordEvent) error {
 		record := e.Record
 		newStatus := record.GetString("status")
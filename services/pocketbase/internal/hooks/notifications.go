@@ -30,6 +30,7 @@ import (
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
 // PushProvider defines the interface for different notification services.
@@ -37,11 +38,21 @@ type PushProvider interface {
 	Send(token, title, body string) error
 }
 
+// Push priorities, using ntfy's names. The FCM relay maps them onto FCM's
+// normal/high delivery priority.
+const (
+	PushPriorityLow     = "low"
+	PushPriorityDefault = "default"
+	PushPriorityHigh    = "high"
+	PushPriorityUrgent  = "urgent"
+)
+
 // NtfyDirectProvider sends notifications directly to a UnifiedPush (ntfy) endpoint.
 // This preserves the "Zero-Trust" sovereign architecture.
 type NtfyDirectProvider struct {
-	ChatID string
-	Type   string
+	ChatID   string
+	Type     string
+	Priority string
 }
 
 func (p *NtfyDirectProvider) Send(endpoint, title, body string) error {
@@ -59,7 +70,7 @@ func (p *NtfyDirectProvider) Send(endpoint, title, body string) error {
 	// ntfy specific headers
 	req.Header.Set("Title", title)
 	req.Header.Set("Click", clickURL)
-	req.Header.Set("Priority", "high")
+	req.Header.Set("Priority", p.Priority)
 	if p.Type != "" {
 		req.Header.Set("Tags", p.Type)
 	}
@@ -85,6 +96,7 @@ type FcmRelayProvider struct {
 	UserID   string
 	ChatID   string
 	Type     string
+	Priority string
 }
 
 func (p *FcmRelayProvider) Send(token, title, body string) error {
@@ -94,13 +106,14 @@ func (p *FcmRelayProvider) Send(token, title, body string) error {
 	}
 
 	payload := map[string]string{
-		"token":   token,
-		"user_id": p.UserID,
-		"service": "fcm",
-		"title":   title,
-		"message": body,
-		"type":    p.Type,
-		"chat":    p.ChatID,
+		"token":    token,
+		"user_id":  p.UserID,
+		"service":  "fcm",
+		"title":    title,
+		"message":  body,
+		"type":     p.Type,
+		"chat":     p.ChatID,
+		"priority": p.Priority,
 	}
 
 	bodyBytes, err := json.Marshal(payload)
@@ -155,12 +168,8 @@ func RegisterNotificationHooks(app *pocketbase.PocketBase) {
 			return e.Next()
 		}
 
-		go SendPushNotification(e.App, userID,
-			"SIGNATURE REQUIRED",
-			"Action: "+e.Record.GetString("permission"),
-			"permission",
-			chatID,
-		)
+		title, message, priority := permissionNotification(e.Record)
+		go SendPushNotificationWithPriority(e.App, userID, title, message, "permission", chatID, priority)
 
 		return e.Next()
 	})
@@ -193,9 +202,41 @@ func RegisterPushApi(app *pocketbase.PocketBase, e *core.ServeEvent) {
 	}).Bind(apis.RequireAuth())
 }
 
-// SendPushNotification is the unified dispatch function.
-// Flow: rules check -> presence check -> device dispatch
+// permissionNotification words a draft's push notification, and picks its
+// priority, from the request's risk level.
+func permissionNotification(record *core.Record) (title, message, priority string) {
+	message = "Action: " + record.GetString("permission")
+
+	var risk permission.Risk
+	_ = record.UnmarshalJSONField("risk_reasons", &risk.Reasons)
+	if summary := risk.Summary(); summary != "" {
+		message += " — " + summary
+	}
+
+	switch record.GetString("risk_level") {
+	case permission.RiskCritical:
+		return "CRITICAL RISK: SIGNATURE REQUIRED", message, PushPriorityUrgent
+	case permission.RiskHigh:
+		return "HIGH RISK: SIGNATURE REQUIRED", message, PushPriorityHigh
+	case permission.RiskLow:
+		return "SIGNATURE REQUIRED", message, PushPriorityDefault
+	default:
+		return "SIGNATURE REQUIRED", message, PushPriorityHigh
+	}
+}
+
+// SendPushNotification is the unified dispatch function, at high priority.
 func SendPushNotification(app core.App, userID, title, message, notifType, chatID string) {
+	SendPushNotificationWithPriority(app, userID, title, message, notifType, chatID, PushPriorityHigh)
+}
+
+// SendPushNotificationWithPriority sends a push with an explicit priority.
+// Flow: rules check -> presence check -> device dispatch
+func SendPushNotificationWithPriority(app core.App, userID, title, message, notifType, chatID, priority string) {
+	// Push bodies leave the server; never let a credential ride along
+	redactor := SecretRedactor(app)
+	title, message = redactor.String(title), redactor.String(message)
+
 	// 1. Notification Rules: check if this type is enabled for the user
 	if !isNotificationTypeEnabled(app, userID, notifType) {
 		log.Printf("🔕 [Push] User %s has disabled '%s' notifications. Skipping.", userID, notifType)
@@ -209,7 +250,7 @@ func SendPushNotification(app core.App, userID, title, message, notifType, chatI
 	}
 
 	// 3. Dispatch to all active devices
-	dispatchToDevices(app, userID, title, message, notifType, chatID)
+	dispatchToDevices(app, userID, title, message, notifType, chatID, priority)
 }
 
 // isNotificationTypeEnabled checks the user's notification_rules record.
@@ -275,7 +316,7 @@ func IsUserOnline(app core.App, userID string) bool {
 }
 
 // dispatchToDevices sends notifications to every active device registered to the user.
-func dispatchToDevices(app core.App, userID, title, message, notifType, chatID string) {
+func dispatchToDevices(app core.App, userID, title, message, notifType, chatID, priority string) {
 	devices, err := app.FindRecordsByFilter(
 		"devices",
 		"user = {:userID} && is_active = true",
@@ -295,8 +336,8 @@ func dispatchToDevices(app core.App, userID, title, message, notifType, chatID s
 	providerMode := os.Getenv("PN_PROVIDER")
 	relayURL := os.Getenv("PN_URL")
 
-	ntfyDirect := &NtfyDirectProvider{ChatID: chatID, Type: notifType}
-	fcmRelay := &FcmRelayProvider{RelayURL: relayURL, UserID: userID, ChatID: chatID, Type: notifType}
+	ntfyDirect := &NtfyDirectProvider{ChatID: chatID, Type: notifType, Priority: priority}
+	fcmRelay := &FcmRelayProvider{RelayURL: relayURL, UserID: userID, ChatID: chatID, Type: notifType, Priority: priority}
 
 	for _, device := range devices {
 		serviceType := device.GetString("push_service")
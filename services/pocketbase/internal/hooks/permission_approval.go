@@ -0,0 +1,249 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Approve and Remember. Saves rules from scoped approvals and applies chat-remembered rules.
+package hooks
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/glob"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+// registerPermissionApproval wires the approval_scope of a permission into
+// tool_permissions. Approving with a scope wider than "once" saves the
+// generated rules in the same transaction as the approval. Since OpenCode
+// only knows about the rendered global and agent rules, drafts the interface
+// relay creates are also evaluated here so that chat-remembered rules can
+// approve them without asking again, and denies are settled at once.
+func registerPermissionApproval(app core.App) {
+	app.OnRecordUpdateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		scope := e.Record.GetString("approval_scope")
+		if scope == "" || scope == permission.ApprovalOnce ||
+			e.Record.Original().GetString("status") != permission.StatusDraft ||
+			e.Record.GetString("status") != permission.StatusAuthorized {
+			return e.Next()
+		}
+
+		if (scope == permission.ApprovalAgent || scope == permission.ApprovalAlways) &&
+			(e.Auth == nil || e.Auth.GetString("role") != "admin") {
+			return e.ForbiddenError("Only admins can remember an approval for an agent or for everyone.", nil)
+		}
+
+		plan, err := PlanPermissionApproval(e.App, e.Record, scope, e.Record.GetString("approval_pattern"))
+		if err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+			return applyRulePlan(txApp, plan, e.Record.Id)
+		})
+	})
+
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("status") != permission.StatusDraft || hasPermissionTrace(e.Record) {
+			return e.Next()
+		}
+		chatID := e.Record.GetString("chat")
+
+		input := PermissionInput(e.Record)
+		input.AgentID = chatAgentID(e.App, chatID)
+		input.CronJobID = ChatCronJobID(e.App, chatID)
+		decision := permission.Evaluate(e.App, input)
+		e.Record.Set("trace", decision.Trace)
+		return e.Next()
+	})
+
+	// Flip to authorized after the draft exists, so the relay sees an update
+	// and replies to OpenCode like it does for a human approval. Conditional,
+	// bash and MCP allow rules are rendered as ask for OpenCode, so their
+	// allows land here too. Every deny is settled here as well, whatever the
+	// tool, so a human can never approve it.
+	app.OnRecordAfterCreateSuccess("permissions").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("status") != permission.StatusDraft {
+			return e.Next()
+		}
+		status := permission.StatusAuthorized
+		reason, ok := backendApproval(e.Record)
+		if !ok {
+			if reason, ok = backendDenial(e.Record); !ok {
+				return e.Next()
+			}
+			status = permission.StatusDenied
+		}
+
+		e.Record.Set("status", status)
+		e.Record.Set("decision_reason", reason)
+		if err := e.App.Save(e.Record); err != nil {
+			log.Printf("❌ [Permission Firewall] Failed to auto-settle %s as %s: %v", e.Record.Id, status, err)
+		} else {
+			log.Printf("🧠 [Permission Firewall] %s %s", e.Record.Id, reason)
+		}
+		return e.Next()
+	})
+}
+
+// PlanPermissionApproval returns the rules approving record with scope would
+// save. pattern overrides the generated pattern when set.
+func PlanPermissionApproval(app core.App, record *core.Record, scope, pattern string) (permission.RulePlan, error) {
+	input := PermissionInput(record)
+	agentID := PermissionAgentID(app, record)
+	existing, err := permission.LoadRules(app, agentID, "")
+	if err != nil {
+		return permission.RulePlan{}, fmt.Errorf("failed to load tool_permissions: %w", err)
+	}
+
+	return permission.PlanApproval(existing, permission.Approval{
+		Scope:      scope,
+		Permission: input.Permission,
+		Patterns:   input.Patterns,
+		Metadata:   input.Metadata,
+		AgentID:    agentID,
+		ChatID:     input.ChatID,
+		Pattern:    pattern,
+	})
+}
+
+// applyRulePlan saves a plan's rules. Existing rows with the same scope, tool
+// and pattern are reused: seeded copies leave them untouched, generated
+// rules switch them to an active allow.
+func applyRulePlan(app core.App, plan permission.RulePlan, sourceID string) error {
+	collection, err := app.FindCollectionByNameOrId("tool_permissions")
+	if err != nil {
+		return err
+	}
+
+	save := func(rule permission.Rule, overwrite bool) error {
+		record, err := app.FindFirstRecordByFilter(
+			"tool_permissions",
+			"agent = {:agent} && chat = {:chat} && tool = {:tool} && pattern = {:pattern}",
+			map[string]any{"agent": rule.AgentID, "chat": rule.ChatID, "tool": rule.Tool, "pattern": rule.Pattern},
+		)
+		if err == nil && !overwrite {
+			return nil
+		}
+		if err != nil {
+			record = core.NewRecord(collection)
+			record.Set("agent", rule.AgentID)
+			record.Set("chat", rule.ChatID)
+			record.Set("tool", rule.Tool)
+			record.Set("pattern", rule.Pattern)
+			record.Set("source_permission", sourceID)
+			if rule.DraftTTL > 0 {
+				record.Set("draft_ttl", rule.DraftTTL)
+			}
+		}
+		record.Set("action", rule.Action)
+		record.Set("active", true)
+		return app.Save(record)
+	}
+
+	for _, rule := range plan.Seeded {
+		if err := save(rule, false); err != nil {
+			return fmt.Errorf("failed to seed agent rule %s: %w", rule.Pattern, err)
+		}
+	}
+	for _, rule := range plan.Rules {
+		if err := save(rule, true); err != nil {
+			return fmt.Errorf("failed to save rule %s: %w", rule.Pattern, err)
+		}
+		log.Printf("🧠 [Permission Firewall] Remembered %s approval: %s: %s", plan.Scope, rule.Tool, rule.Pattern)
+	}
+	return nil
+}
+
+// backendApproval reports whether a record's trace allows it through a rule
+// only the backend enforces (a chat-remembered or conditional rule, a bash
+// rule, an MCP rule, or one whose globs OpenCode cannot read), returning the
+// decision reason.
+func backendApproval(record *core.Record) (string, bool) {
+	var trace permission.Trace
+	if err := record.UnmarshalJSONField("trace", &trace); err != nil || trace.Action != permission.ActionAllow {
+		return "", false
+	}
+	for _, st := range trace.Subjects {
+		if st.Scope == permission.ScopeChat {
+			return fmt.Sprintf("remembered for this chat: `%s: %s`", st.Tool, st.Pattern), true
+		}
+	}
+	if trace.Conditional {
+		return fmt.Sprintf("allowed by conditional rule `%s: %s`", trace.MatchedTool, trace.MatchedPattern), true
+	}
+	if trace.Permission == "bash" {
+		return fmt.Sprintf("allowed by rule `%s: %s`, checked per command by the backend", trace.MatchedTool, trace.MatchedPattern), true
+	}
+	for _, st := range trace.Subjects {
+		if permission.IsMcpRule(st.Tool) || !glob.IsWildcard(st.Tool) || !glob.IsWildcard(st.Pattern) {
+			return fmt.Sprintf("allowed by rule `%s: %s`, which OpenCode cannot match", st.Tool, st.Pattern), true
+		}
+	}
+	return "", false
+}
+
+// backendDenial reports whether a record's trace denies it, returning the
+// decision reason. OpenCode asks whenever it cannot see the deny (an MCP
+// rule, a protected path, a bash command it matched as a whole), and a deny
+// must never be left for a human to approve.
+func backendDenial(record *core.Record) (string, bool) {
+	var trace permission.Trace
+	if err := record.UnmarshalJSONField("trace", &trace); err != nil || trace.Action != permission.ActionDeny {
+		return "", false
+	}
+	for _, st := range trace.Subjects {
+		if st.Action == permission.ActionDeny && st.Protected != "" {
+			return fmt.Sprintf("denied: protected path `%s`", st.Protected), true
+		}
+	}
+	return fmt.Sprintf("denied by rule `%s: %s`", trace.MatchedTool, trace.MatchedPattern), true
+}
+
+// HumanApproved reports whether an authorized record was approved by a
+// person: the evaluator asked (or never saw it), and the backend did not
+// approve it on a rule's behalf.
+func HumanApproved(record *core.Record) bool {
+	if record.GetString("status") != permission.StatusAuthorized {
+		return false
+	}
+	var trace permission.Trace
+	if err := record.UnmarshalJSONField("trace", &trace); err == nil && trace.Action == permission.ActionAllow {
+		return false
+	}
+	return true
+}
+
+func hasPermissionTrace(record *core.Record) bool {
+	var trace permission.Trace
+	return record.UnmarshalJSONField("trace", &trace) == nil && trace.Permission != ""
+}
+
+// PermissionAgentID returns the agent a permission was evaluated for, falling
+// back to the agent linked on its chat.
+func PermissionAgentID(app core.App, record *core.Record) string {
+	var trace permission.Trace
+	if err := record.UnmarshalJSONField("trace", &trace); err == nil && trace.AgentID != "" {
+		return trace.AgentID
+	}
+	return chatAgentID(app, record.GetString("chat"))
+}
@@ -0,0 +1,147 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Draft Expiry. Auto-denies permission requests nobody answered in time.
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+const permissionExpiryJob = "pc_permission_expiry"
+
+// registerPermissionExpiry stamps drafts that have a TTL with an expiry,
+// refuses late approvals and runs a sweeper that denies expired drafts once
+// a minute.
+// Denying the record is enough to unblock OpenCode: the interface relay
+// replies "reject" for every permission that turns denied.
+func registerPermissionExpiry(app core.App) {
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("status") == permission.StatusDraft && e.Record.GetDateTime("expires_at").IsZero() {
+			ttl := permission.DraftTTL(e.App, e.Record.GetString("permission"), chatAgentID(e.App, e.Record.GetString("chat")))
+			if ttl > 0 {
+				e.Record.Set("expires_at", time.Now().UTC().Add(ttl))
+			}
+		}
+		return e.Next()
+	})
+
+	app.OnRecordUpdateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Record.Original().GetString("status") == permission.StatusDraft &&
+			e.Record.GetString("status") == permission.StatusAuthorized &&
+			isPermissionExpired(e.Record, time.Now()) {
+			return e.BadRequestError("This permission request has expired and can no longer be approved.", nil)
+		}
+		return e.Next()
+	})
+
+	app.Cron().MustAdd(permissionExpiryJob, "* * * * *", func() {
+		expireDraftPermissions(app)
+	})
+}
+
+// expireDraftPermissions denies every draft whose expires_at has passed.
+func expireDraftPermissions(app core.App) {
+	now := time.Now().UTC()
+	records, err := app.FindRecordsByFilter(
+		"permissions",
+		"status = 'draft' && expires_at != '' && expires_at <= {:now}",
+		"expires_at",
+		0, 0,
+		map[string]any{"now": types.NowDateTime().String()},
+	)
+	if err != nil {
+		log.Printf("⚠️ [Permission Firewall] Failed to query expired drafts: %v", err)
+		return
+	}
+
+	for _, record := range records {
+		expiredAt := record.GetDateTime("expires_at").Time()
+		record.Set("status", permission.StatusDenied)
+		record.Set("decision_reason", fmt.Sprintf(
+			"expired: no decision within the approval window (expired %s)",
+			expiredAt.Format(time.RFC3339),
+		))
+		if err := app.Save(record); err != nil {
+			log.Printf("❌ [Permission Firewall] Failed to expire %s: %v", record.Id, err)
+			continue
+		}
+
+		log.Printf("⌛ [Permission Firewall] Expired draft %s (%s), %s overdue",
+			record.Id, record.GetString("permission"), now.Sub(expiredAt).Round(time.Second))
+		notifyPermissionExpired(app, record)
+	}
+}
+
+// notifyPermissionExpired tells the chat owner their request lapsed.
+func notifyPermissionExpired(app core.App, record *core.Record) {
+	chatID := record.GetString("chat")
+	if chatID == "" {
+		return
+	}
+	chat, err := app.FindRecordById("chats", chatID)
+	if err != nil || chat.GetString("user") == "" {
+		return
+	}
+
+	go SendPushNotification(app, chat.GetString("user"),
+		"REQUEST EXPIRED",
+		"Denied after timeout: "+record.GetString("permission"),
+		"permission_expired",
+		chatID,
+	)
+}
+
+func isPermissionExpired(record *core.Record, now time.Time) bool {
+	expiresAt := record.GetDateTime("expires_at")
+	return !expiresAt.IsZero() && !expiresAt.Time().After(now)
+}
+
+// chatAgentID returns the ai_agents ID linked on a chat, if any.
+func chatAgentID(app core.App, chatID string) string {
+	if chatID == "" {
+		return ""
+	}
+	chat, err := app.FindRecordById("chats", chatID)
+	if err != nil {
+		return ""
+	}
+	return chat.GetString("agent")
+}
+
+// ChatCronJobID returns the cron job whose runs use chatID: the job that
+// created the chat, or the job posting into it with session_mode "existing".
+func ChatCronJobID(app core.App, chatID string) string {
+	if chatID == "" {
+		return ""
+	}
+	if chat, err := app.FindRecordById("chats", chatID); err == nil && chat.GetString("cron_job") != "" {
+		return chat.GetString("cron_job")
+	}
+	job, err := app.FindFirstRecordByFilter("cron_jobs", "session_mode = 'existing' && chat = {:chat}", map[string]any{"chat": chatID})
+	if err != nil {
+		return ""
+	}
+	return job.Id
+}
@@ -0,0 +1,93 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Idempotent Permissions. One permission record per OpenCode request, however often it is retried.
+package hooks
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ErrIdempotencyConflict is returned when a retried request reuses the
+// idempotency key of a permission with different content.
+var ErrIdempotencyConflict = errors.New("opencode_id or call_id was already used for a different request")
+
+// registerPermissionIdempotency keys every permission by the OpenCode request
+// it gates. The unique index on idempotency_key turns a retried or re-synced
+// create into a constraint error instead of a second record (and push).
+func registerPermissionIdempotency(app core.App) {
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		e.Record.Set("idempotency_key", PermissionIdempotencyKey(
+			e.Record.GetString("ai_engine_permission_id"),
+			e.Record.GetString("session_id"),
+			e.Record.GetString("call_id"),
+		))
+		return e.Next()
+	})
+
+	app.OnRecordUpdate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		e.Record.Set("idempotency_key", e.Record.Original().GetString("idempotency_key"))
+		return e.Next()
+	})
+}
+
+// PermissionIdempotencyKey derives the idempotency key of a permission
+// request: the OpenCode permission ID, or else the tool call ID within its
+// session. It is empty when the request has neither.
+func PermissionIdempotencyKey(opencodeID, sessionID, callID string) string {
+	switch {
+	case opencodeID != "":
+		return "opencode:" + opencodeID
+	case callID != "":
+		return "call:" + sessionID + "/" + callID
+	default:
+		return ""
+	}
+}
+
+// FindPermissionRetry returns the permission already created for the same
+// idempotency key as the unsaved record, or nil if there is none. It fails
+// with ErrIdempotencyConflict when that permission gates a different request.
+func FindPermissionRetry(app core.App, record *core.Record) (*core.Record, error) {
+	key := PermissionIdempotencyKey(
+		record.GetString("ai_engine_permission_id"),
+		record.GetString("session_id"),
+		record.GetString("call_id"),
+	)
+	if key == "" {
+		return nil, nil
+	}
+
+	existing, err := app.FindFirstRecordByData("permissions", "idempotency_key", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The stored hash covers the redacted content
+	candidate := record.Clone()
+	redactPermission(app, candidate)
+	if existing.GetString("request_hash") != permissionRequestHash(candidate) {
+		return existing, ErrIdempotencyConflict
+	}
+	return existing, nil
+}
@@ -0,0 +1,206 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Ledger Hooks. Chains decided permissions, keeps them immutable and forbids deletes.
+package hooks
+
+import (
+	"errors"
+	"log"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+// registerPermissionLedger turns the permissions collection into an
+// append-only, hash-chained ledger. A record joins the chain when it is
+// decided: it gets the next seq, the previous entry's hash and a hash over
+// its own canonical content. From then on that content cannot change, and no
+// permission can be deleted.
+//
+// The chain tip is read and written in one transaction, which holds
+// PocketBase's single write connection, so concurrent decisions cannot fork
+// the chain.
+func registerPermissionLedger(app core.App) {
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		if !isPermissionDecided(e.Record) {
+			clearLedgerFields(e.Record)
+			return e.Next()
+		}
+		return chainPermission(e)
+	})
+
+	app.OnRecordUpdate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		original := e.Record.Original()
+		if original.GetInt("seq") > 0 {
+			if ledgerChanged(original, e.Record) {
+				return errors.New("decided permissions are immutable")
+			}
+			return e.Next()
+		}
+		if !isPermissionDecided(e.Record) {
+			clearLedgerFields(e.Record)
+			return e.Next()
+		}
+		return chainPermission(e)
+	})
+
+	app.OnRecordDelete("permissions").BindFunc(func(e *core.RecordEvent) error {
+		return errors.New("the permissions ledger is append-only; records cannot be deleted")
+	})
+
+	// Same checks at the API level, with messages the client can show.
+	app.OnRecordUpdateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		if original := e.Record.Original(); original.GetInt("seq") > 0 && ledgerChanged(original, e.Record) {
+			return e.BadRequestError("Decided permissions are immutable.", nil)
+		}
+		return e.Next()
+	})
+	app.OnRecordDeleteRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		return e.BadRequestError("The permissions ledger is append-only; records cannot be deleted.", nil)
+	})
+
+	// Adopt decided records from before the chain existed.
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		if n, err := backfillLedger(app); err != nil {
+			log.Printf("⚠️ [Ledger] Backfill failed: %v", err)
+		} else if n > 0 {
+			log.Printf("⛓️ [Ledger] Chained %d existing decided permission(s)", n)
+		}
+		return e.Next()
+	})
+}
+
+// chainPermission appends the record being saved to the chain.
+func chainPermission(e *core.RecordEvent) error {
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+		seq, prev, err := ledgerTip(txApp)
+		if err != nil {
+			return err
+		}
+		setLedgerFields(e.Record, seq+1, prev)
+		return e.Next()
+	})
+}
+
+// ledgerTip returns the seq and entry_hash of the last chained permission.
+func ledgerTip(app core.App) (int, string, error) {
+	tip, err := app.FindRecordsByFilter("permissions", "seq > 0", "-seq", 1, 0)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(tip) == 0 {
+		return 0, "", nil
+	}
+	return tip[0].GetInt("seq"), tip[0].GetString("entry_hash"), nil
+}
+
+func setLedgerFields(record *core.Record, seq int, prev string) {
+	record.Set("seq", seq)
+	record.Set("prev_hash", prev)
+	record.Set("entry_hash", permission.ChainHash(prev, PermissionLedgerEntry(record)))
+}
+
+func clearLedgerFields(record *core.Record) {
+	record.Set("seq", 0)
+	record.Set("prev_hash", "")
+	record.Set("entry_hash", "")
+}
+
+// ledgerChanged reports whether an update touches a chained record's hashed
+// content or chain fields.
+func ledgerChanged(original, record *core.Record) bool {
+	for _, field := range []string{"seq", "prev_hash", "entry_hash"} {
+		if original.GetString(field) != record.GetString(field) {
+			return true
+		}
+	}
+	return permission.ChainHash("", PermissionLedgerEntry(original)) != permission.ChainHash("", PermissionLedgerEntry(record))
+}
+
+// PermissionLedgerEntry returns the canonical content of a permission record.
+// The chat relation is left out: request_hash already binds the chat, and
+// deleting a chat clears the relation.
+func PermissionLedgerEntry(record *core.Record) permission.LedgerEntry {
+	var patterns, metadata, trace any
+	_ = record.UnmarshalJSONField("patterns", &patterns)
+	_ = record.UnmarshalJSONField("metadata", &metadata)
+	_ = record.UnmarshalJSONField("trace", &trace)
+
+	return permission.LedgerEntry{
+		Seq:             record.GetInt("seq"),
+		ID:              record.Id,
+		Created:         record.GetDateTime("created").String(),
+		OpencodeID:      record.GetString("ai_engine_permission_id"),
+		SessionID:       record.GetString("session_id"),
+		Permission:      record.GetString("permission"),
+		Patterns:        patterns,
+		Metadata:        metadata,
+		Message:         record.GetString("message"),
+		MessageID:       record.GetString("message_id"),
+		CallID:          record.GetString("call_id"),
+		Challenge:       record.GetString("challenge"),
+		RequestHash:     record.GetString("request_hash"),
+		Status:          record.GetString("status"),
+		ApprovedBy:      record.GetString("approved_by"),
+		ApprovedAt:      record.GetDateTime("approved_at").String(),
+		Signature:       record.GetString("signature"),
+		SignedDevice:    record.GetString("signed_device"),
+		DecisionReason:  record.GetString("decision_reason"),
+		ApprovalScope:   record.GetString("approval_scope"),
+		ApprovalPattern: record.GetString("approval_pattern"),
+		Quorum:          record.GetInt("quorum"),
+		Approvals:       record.GetInt("approvals"),
+		RiskScore:       record.GetInt("risk_score"),
+		Trace:           trace,
+	}
+}
+
+// backfillLedger chains decided permissions that have no seq yet, oldest
+// first. It writes the chain fields directly so that no hooks or realtime
+// events fire for old decisions.
+func backfillLedger(app core.App) (int, error) {
+	chained := 0
+	err := app.RunInTransaction(func(txApp core.App) error {
+		records, err := txApp.FindRecordsByFilter("permissions", "seq = 0 && status != 'draft'", "created", 0, 0)
+		if err != nil || len(records) == 0 {
+			return err
+		}
+		seq, prev, err := ledgerTip(txApp)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			seq++
+			setLedgerFields(record, seq, prev)
+			prev = record.GetString("entry_hash")
+			if _, err := txApp.NonconcurrentDB().Update("permissions", dbx.Params{
+				"seq":        seq,
+				"prev_hash":  record.GetString("prev_hash"),
+				"entry_hash": prev,
+			}, dbx.HashExp{"id": record.Id}).Execute(); err != nil {
+				return err
+			}
+			chained++
+		}
+		return nil
+	})
+	return chained, err
+}
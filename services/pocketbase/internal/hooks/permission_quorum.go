@@ -0,0 +1,148 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Approver Quorum Hooks. Records approvals and holds drafts until enough distinct users approve.
+package hooks
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+// registerPermissionQuorum makes drafts matched by a rule with a quorum wait
+// for that many distinct approvers. Each approval is recorded in
+// permission_approvals and the draft only flips to authorized, with
+// approved_by/approved_at set, once the quorum is reached. A single deny
+// still denies.
+func registerPermissionQuorum(app core.App) {
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		var trace permission.Trace
+		if err := e.Record.UnmarshalJSONField("trace", &trace); err == nil && trace.Quorum > 1 {
+			e.Record.Set("quorum", trace.Quorum)
+			e.Record.Set("quorum_group", trace.QuorumGroup)
+		}
+		e.Record.Set("approvals", 0)
+		return e.Next()
+	})
+
+	app.OnRecordUpdateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		// The quorum comes from the stored draft, never from the request.
+		quorum := e.Record.Original().GetInt("quorum")
+		if quorum <= 1 ||
+			e.Record.Original().GetString("status") != permission.StatusDraft ||
+			e.Record.GetString("status") != permission.StatusAuthorized {
+			return e.Next()
+		}
+		if e.HasSuperuserAuth() {
+			log.Printf("⚠️ [Permission Firewall] %s approved by a superuser without its %d-approver quorum", e.Record.Id, quorum)
+			return e.Next()
+		}
+		if e.Auth == nil {
+			return e.UnauthorizedError("Approving a permission requires an authenticated user.", nil)
+		}
+		if scope := e.Record.GetString("approval_scope"); scope != "" && scope != permission.ApprovalOnce {
+			return e.BadRequestError(fmt.Sprintf("A permission that needs %d approvers can only be approved once.", quorum), nil)
+		}
+
+		group := e.Record.Original().GetString("quorum_group")
+		var groups []string
+		_ = e.Auth.UnmarshalJSONField("groups", &groups)
+		if !permission.CanApproveQuorum(e.Auth.GetString("role"), groups, group) {
+			return e.ForbiddenError(fmt.Sprintf("Only members of %q can approve this permission.", group), nil)
+		}
+
+		if _, err := e.App.FindFirstRecordByFilter(
+			"permission_approvals",
+			"permission = {:permission} && user = {:user}",
+			map[string]any{"permission": e.Record.Id, "user": e.Auth.Id},
+		); err == nil {
+			return e.BadRequestError("You have already approved this permission.", nil)
+		}
+
+		collection, err := e.App.FindCollectionByNameOrId("permission_approvals")
+		if err != nil {
+			return err
+		}
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			approval := core.NewRecord(collection)
+			approval.Set("permission", e.Record.Id)
+			approval.Set("user", e.Auth.Id)
+			approval.Set("device", e.Record.GetString("signed_device"))
+			approval.Set("signature", e.Record.GetString("signature"))
+			if err := txApp.Save(approval); err != nil {
+				return fmt.Errorf("failed to record approval: %w", err)
+			}
+
+			count, err := txApp.CountRecords("permission_approvals", dbx.HashExp{"permission": e.Record.Id})
+			if err != nil {
+				return err
+			}
+			e.Record.Set("approvals", count)
+
+			if int(count) < quorum {
+				// Not there yet: keep the draft open for the other approvers.
+				e.Record.Set("status", permission.StatusDraft)
+				e.Record.Set("approved_by", "")
+				e.Record.Set("approved_at", "")
+				e.Record.Set("signature", "")
+				e.Record.Set("signed_device", "")
+				log.Printf("🗳️ [Permission Firewall] %s approved by %s (%d of %d)", e.Record.Id, e.Auth.Id, count, quorum)
+				return e.Next()
+			}
+
+			e.Record.Set("approved_by", e.Auth.Id)
+			e.Record.Set("approved_at", time.Now().UTC())
+			e.Record.Set("decision_reason", fmt.Sprintf("quorum reached: %d of %d approvers", count, quorum))
+			log.Printf("🗳️ [Permission Firewall] %s reached its quorum of %d", e.Record.Id, quorum)
+			return e.Next()
+		})
+	})
+
+	// Approver groups decide who may approve, so only admins assign them.
+	guardGroups := func(e *core.RecordRequestEvent) error {
+		before := "[]"
+		if original := e.Record.Original(); original != nil && !original.IsNew() {
+			before = jsonList(original, "groups")
+		}
+		if jsonList(e.Record, "groups") == before || e.HasSuperuserAuth() ||
+			(e.Auth != nil && e.Auth.GetString("role") == "admin") {
+			return e.Next()
+		}
+		return e.ForbiddenError("Only admins can change approver groups.", nil)
+	}
+	app.OnRecordCreateRequest("users").BindFunc(guardGroups)
+	app.OnRecordUpdateRequest("users").BindFunc(guardGroups)
+}
+
+// jsonList returns a JSON list field in a canonical form for comparison.
+func jsonList(record *core.Record, field string) string {
+	var list []string
+	_ = record.UnmarshalJSONField(field, &list)
+	if len(list) == 0 {
+		return "[]"
+	}
+	return fmt.Sprintf("%q", list)
+}
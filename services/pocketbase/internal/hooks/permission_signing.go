@@ -0,0 +1,136 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Signed Approvals. Verifies device Ed25519 signatures before a permission is authorized.
+package hooks
+
+import (
+	"log"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+// registerPermissionSigning binds the permission challenge to the request
+// content and verifies device signatures over both when a draft is
+// authorized. With PERMISSION_SIGNATURES=required a signature is mandatory,
+// so a stolen session token alone cannot approve anything.
+func registerPermissionSigning(app core.App) {
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		e.Record.Set("request_hash", permissionRequestHash(e.Record))
+		e.Record.Set("signature", "")
+		e.Record.Set("signed_device", "")
+		return e.Next()
+	})
+
+	app.OnRecordUpdateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		original := e.Record.Original()
+		if e.Record.GetString("challenge") != original.GetString("challenge") ||
+			e.Record.GetString("request_hash") != original.GetString("request_hash") ||
+			permissionRequestHash(e.Record) != permissionRequestHash(original) {
+			return e.BadRequestError("The request content and challenge of a permission cannot be changed.", nil)
+		}
+
+		if original.GetString("status") != permission.StatusDraft ||
+			e.Record.GetString("status") != permission.StatusAuthorized {
+			return e.Next()
+		}
+		mode := permission.SignatureMode()
+		if mode == permission.SignaturesOff || e.HasSuperuserAuth() {
+			return e.Next()
+		}
+		if e.Auth == nil {
+			return e.UnauthorizedError("Approving a permission requires an authenticated user.", nil)
+		}
+
+		deviceID := e.Record.GetString("signed_device")
+		if deviceID == "" && e.Record.GetString("signature") == "" && mode == permission.SignaturesOptional {
+			// Clients that cannot sign yet may still approve; a signature
+			// they do send is verified below.
+			return e.Next()
+		}
+		if deviceID == "" || e.Record.GetString("signature") == "" {
+			return e.ForbiddenError("Approving a permission requires a device signature.", nil)
+		}
+		device, err := e.App.FindRecordById("devices", deviceID)
+		if err != nil || device.GetString("user") != e.Auth.Id || !device.GetBool("is_active") {
+			return e.ForbiddenError("The signing device is not an active device of this user.", nil)
+		}
+		if device.GetString("public_key") == "" {
+			return e.ForbiddenError("The signing device has no registered public key.", nil)
+		}
+
+		requestHash := permissionRequestHash(e.Record)
+		if stored := e.Record.GetString("request_hash"); stored != "" && stored != requestHash {
+			return e.ForbiddenError("The permission no longer matches its request hash.", nil)
+		}
+		if err := permission.VerifyApproval(device.GetString("public_key"), e.Record.GetString("signature"), e.Record.GetString("challenge"), requestHash); err != nil {
+			log.Printf("🚫 [Permission Firewall] Rejected approval of %s by %s: %v", e.Record.Id, e.Auth.Id, err)
+			return e.ForbiddenError("Invalid approval signature: "+err.Error(), nil)
+		}
+
+		e.Record.Set("request_hash", requestHash)
+		e.Record.Set("approved_by", e.Auth.Id)
+		e.Record.Set("approved_at", time.Now().UTC())
+		log.Printf("🔏 [Permission Firewall] %s approved with a signature from device %s", e.Record.Id, deviceID)
+		return e.Next()
+	})
+
+	// Device keys must be valid Ed25519 keys and cannot be swapped once set.
+	app.OnRecordCreateRequest("devices").BindFunc(func(e *core.RecordRequestEvent) error {
+		if key := e.Record.GetString("public_key"); key != "" {
+			if _, err := permission.ParsePublicKey(key); err != nil {
+				return e.BadRequestError("Invalid public_key: "+err.Error(), nil)
+			}
+		}
+		return e.Next()
+	})
+	app.OnRecordUpdateRequest("devices").BindFunc(func(e *core.RecordRequestEvent) error {
+		key := e.Record.GetString("public_key")
+		if old := e.Record.Original().GetString("public_key"); old != "" && key != old {
+			return e.BadRequestError("A device's public_key cannot be changed; register a new device instead.", nil)
+		}
+		if key != "" {
+			if _, err := permission.ParsePublicKey(key); err != nil {
+				return e.BadRequestError("Invalid public_key: "+err.Error(), nil)
+			}
+		}
+		return e.Next()
+	})
+}
+
+// permissionRequestHash hashes the content of a permission record that an
+// approval signature covers.
+func permissionRequestHash(record *core.Record) string {
+	var patterns []string
+	var metadata map[string]any
+	_ = record.UnmarshalJSONField("patterns", &patterns)
+	_ = record.UnmarshalJSONField("metadata", &metadata)
+
+	return permission.RequestHash(permission.SignedRequest{
+		OpencodeID: record.GetString("ai_engine_permission_id"),
+		SessionID:  record.GetString("session_id"),
+		ChatID:     record.GetString("chat"),
+		Permission: record.GetString("permission"),
+		Patterns:   patterns,
+		Metadata:   metadata,
+		MessageID:  record.GetString("message_id"),
+		CallID:     record.GetString("call_id"),
+	})
+}
@@ -21,18 +21,22 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package hooks
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/google/uuid"
-	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
 // RegisterPermissionHooks registers hooks for the permissions collection.
-func RegisterPermissionHooks(app *pocketbase.PocketBase) {
+func RegisterPermissionHooks(app core.App) {
 	// 1. CREATION: Generate Challenge, Default to Draft
 	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
-		permission := e.Record.GetString("permission")
+		tool := e.Record.GetString("permission")
 
 		// Generate Authority Challenge (for cryptographic verification if needed later)
 		e.Record.Set("challenge", uuid.NewString())
@@ -42,8 +46,164 @@ func RegisterPermissionHooks(app *pocketbase.PocketBase) {
 			e.Record.Set("status", "draft")
 		}
 
-		log.Printf("🛡️ [Permission Firewall] Gating: %s. Status: %s", permission, e.Record.GetString("status"))
+		// Score the request so notifications can be prioritized
+		risk := permission.AssessRisk(PermissionInput(e.Record))
+		e.Record.Set("risk_score", risk.Score)
+		e.Record.Set("risk_level", risk.Level)
+		e.Record.Set("risk_reasons", risk.Reasons)
 
+		log.Printf("🛡️ [Permission Firewall] Gating: %s. Status: %s. Risk: %s (%d)", tool, e.Record.GetString("status"), risk.Level, risk.Score)
+
+		return e.Next()
+	})
+	// 2. DECISION: Wake up callers blocked on this permission
+	app.OnRecordAfterUpdateSuccess("permissions").BindFunc(func(e *core.RecordEvent) error {
+		if isPermissionDecided(e.Record) {
+			notifyPermissionWaiters(e.Record)
+		}
+		return e.Next()
+	})
+	// 3. LOCK: Fields the backend manages are never taken from clients
+	registerPermissionFieldLock(app)
+	// 4. EXPIRY: Drafts nobody answers are denied after their TTL
+	registerPermissionExpiry(app)
+	// 5. SIGNATURES: Authorizing a draft requires a device-signed challenge
+	registerPermissionSigning(app)
+	// 6. REMEMBER: Scoped approvals save rules; chat rules approve new drafts
+	registerPermissionApproval(app)
+	// 7. QUORUM: Some rules need several distinct approvers
+	registerPermissionQuorum(app)
+	// 8. REDACTION: Secrets are masked before anything is stored
+	registerPermissionRedaction(app)
+	// 9. LEDGER: Decided permissions are hash-chained, immutable and never deleted
+	registerPermissionLedger(app)
+	// 10. IDEMPOTENCY: Retries of the same OpenCode request never create a second record
+	registerPermissionIdempotency(app)
+}
+
+// serverManagedPermissionFields are set by the backend alone. The evaluator
+// writes trace and quorum_group/quorum, the quorum hook counts approvals and
+// the expiry hook stamps expires_at.
+var serverManagedPermissionFields = []string{"trace", "quorum", "quorum_group", "approvals", "expires_at"}
+
+// registerPermissionFieldLock keeps clients from forging the fields the
+// backend decides with: a forged trace could auto-approve a request, a
+// lowered quorum needs fewer approvers and a later expires_at outlives the
+// approval window. New permissions from clients always start as drafts.
+// Superusers are exempt.
+func registerPermissionFieldLock(app core.App) {
+	app.OnRecordCreateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.HasSuperuserAuth() {
+			return e.Next()
+		}
+		for _, field := range serverManagedPermissionFields {
+			e.Record.Set(field, nil)
+		}
+		e.Record.Set("status", permission.StatusDraft)
 		return e.Next()
 	})
+
+	app.OnRecordUpdateRequest("permissions").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.HasSuperuserAuth() {
+			return e.Next()
+		}
+		original := e.Record.Original()
+		for _, field := range serverManagedPermissionFields {
+			if canonicalField(e.Record, field) != canonicalField(original, field) {
+				return e.BadRequestError(fmt.Sprintf("The %s of a permission is managed by the backend and cannot be changed.", field), nil)
+			}
+		}
+		return e.Next()
+	})
+}
+
+// canonicalField returns a field value as canonical JSON, so equal values
+// compare equal however a client formatted them.
+func canonicalField(record *core.Record, field string) string {
+	raw, _ := json.Marshal(record.Get(field))
+	var value any
+	if json.Unmarshal(raw, &value) == nil {
+		raw, _ = json.Marshal(value)
+	}
+	return string(raw)
+}
+
+// permissionWaiters maps permission record IDs to the channels of callers
+// waiting for that record to be decided.
+var permissionWaiters = struct {
+	sync.Mutex
+	m map[string]map[chan *core.Record]struct{}
+}{m: map[string]map[chan *core.Record]struct{}{}}
+
+// WaitForPermissionDecision blocks until the permission record moves to
+// authorized or denied, or until ctx is done. It returns the latest known
+// record and whether a decision was reached.
+func WaitForPermissionDecision(ctx context.Context, app core.App, id string) (*core.Record, bool, error) {
+	ch := make(chan *core.Record, 1)
+
+	// Subscribe before reading the current state so a decision landing in
+	// between is never missed.
+	permissionWaiters.Lock()
+	if permissionWaiters.m[id] == nil {
+		permissionWaiters.m[id] = map[chan *core.Record]struct{}{}
+	}
+	permissionWaiters.m[id][ch] = struct{}{}
+	permissionWaiters.Unlock()
+
+	defer func() {
+		permissionWaiters.Lock()
+		delete(permissionWaiters.m[id], ch)
+		if len(permissionWaiters.m[id]) == 0 {
+			delete(permissionWaiters.m, id)
+		}
+		permissionWaiters.Unlock()
+	}()
+
+	record, err := app.FindRecordById("permissions", id)
+	if err != nil {
+		return nil, false, err
+	}
+	if isPermissionDecided(record) {
+		return record, true, nil
+	}
+
+	select {
+	case decided := <-ch:
+		return decided, true, nil
+	case <-ctx.Done():
+		return record, false, nil
+	}
+}
+
+func notifyPermissionWaiters(record *core.Record) {
+	permissionWaiters.Lock()
+	defer permissionWaiters.Unlock()
+
+	for ch := range permissionWaiters.m[record.Id] {
+		select {
+		case ch <- record.Fresh():
+		default:
+		}
+	}
+}
+
+func isPermissionDecided(record *core.Record) bool {
+	status := record.GetString("status")
+	return status == "authorized" || status == "denied"
+}
+
+// PermissionInput rebuilds the evaluation input stored on a permission record.
+func PermissionInput(record *core.Record) permission.EvaluationInput {
+	var patterns []string
+	var metadata map[string]any
+	_ = record.UnmarshalJSONField("patterns", &patterns)
+	_ = record.UnmarshalJSONField("metadata", &metadata)
+
+	return permission.EvaluationInput{
+		Permission: record.GetString("permission"),
+		Patterns:   patterns,
+		Metadata:   metadata,
+		ChatID:     record.GetString("chat"),
+		SessionID:  record.GetString("session_id"),
+	}
 }
@@ -0,0 +1,490 @@
+package hooks_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/hooks"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+	_ "github.com/qtpi-automaton/pocketcoder/backend/pb_migrations"
+)
+
+// newPermissionApp returns a test app with the PocketCoder schema, the
+// timestamp hooks and the permission hooks registered.
+func newPermissionApp(t testing.TB) *tests.TestApp {
+	t.Helper()
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+	hooks.RegisterGlobalTimestamps(app)
+	hooks.RegisterPermissionHooks(app)
+	return app
+}
+
+// newUser creates a user with role and returns it with an auth token.
+func newUser(t testing.TB, app core.App, role string) (*core.Record, string) {
+	t.Helper()
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := core.NewRecord(users)
+	user.SetEmail(fmt.Sprintf("%s-%s@pocketcoder.test", role, core.GenerateDefaultRandomId()))
+	user.SetPassword("pocketcoder_test")
+	user.Set("role", role)
+	if err := app.Save(user); err != nil {
+		t.Fatal(err)
+	}
+	token, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, token
+}
+
+// newDevice registers an active device with an Ed25519 key for user.
+func newDevice(t testing.TB, app core.App, user *core.Record) (*core.Record, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices, err := app.FindCollectionByNameOrId("devices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := core.NewRecord(devices)
+	device.Set("user", user.Id)
+	device.Set("name", "test phone")
+	device.Set("push_token", "token")
+	device.Set("push_service", "fcm")
+	device.Set("is_active", true)
+	device.Set("public_key", base64.StdEncoding.EncodeToString(pub))
+	if err := app.Save(device); err != nil {
+		t.Fatal(err)
+	}
+	return device, priv
+}
+
+// newDraft creates a draft for `make deploy` as OpenCode would.
+func newDraft(t testing.TB, app core.App, opencodeID string) *core.Record {
+	t.Helper()
+	return newRequest(t, app, opencodeID, "bash", []string{"make deploy"}, map[string]any{"command": "make deploy"})
+}
+
+// newRequest creates a draft permission for tool as OpenCode would.
+func newRequest(t testing.TB, app core.App, opencodeID, tool string, patterns []string, metadata map[string]any) *core.Record {
+	t.Helper()
+	permissions, err := app.FindCollectionByNameOrId("permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := core.NewRecord(permissions)
+	record.Set("ai_engine_permission_id", opencodeID)
+	record.Set("session_id", "ses_test")
+	record.Set("permission", tool)
+	record.Set("patterns", patterns)
+	record.Set("metadata", metadata)
+	record.Set("status", permission.StatusDraft)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// newRule saves a global tool_permissions rule.
+func newRule(t testing.TB, app core.App, tool, pattern, action string, quorum int) *core.Record {
+	t.Helper()
+	rules, err := app.FindCollectionByNameOrId("tool_permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := core.NewRecord(rules)
+	rule.Set("tool", tool)
+	rule.Set("pattern", pattern)
+	rule.Set("action", action)
+	rule.Set("active", true)
+	rule.Set("quorum", quorum)
+	if err := app.Save(rule); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+// sign signs the approval message of a saved permission record.
+func sign(key ed25519.PrivateKey, record *core.Record) string {
+	msg := permission.ApprovalMessage(record.GetString("challenge"), record.GetString("request_hash"))
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, msg))
+}
+
+// approve is a scenario that PATCHes a permission to authorized.
+func approve(app *tests.TestApp, record *core.Record, token, body string) tests.ApiScenario {
+	return tests.ApiScenario{
+		Method:                http.MethodPatch,
+		URL:                   "/api/collections/permissions/records/" + record.Id,
+		Body:                  strings.NewReader(body),
+		Headers:               map[string]string{"Authorization": token},
+		TestAppFactory:        func(testing.TB) *tests.TestApp { return app },
+		DisableTestAppCleanup: true,
+	}
+}
+
+func TestPermissionSignatures(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		body   func(device *core.Record, key, other ed25519.PrivateKey, record *core.Record) string
+		status int
+		want   string
+	}{
+		{
+			name: "required rejects unsigned",
+			mode: permission.SignaturesRequired,
+			body: func(_ *core.Record, _, _ ed25519.PrivateKey, _ *core.Record) string {
+				return `{"status":"authorized"}`
+			},
+			status: http.StatusForbidden,
+			want:   permission.StatusDraft,
+		},
+		{
+			name: "required rejects a foreign key",
+			mode: permission.SignaturesRequired,
+			body: func(device *core.Record, _, other ed25519.PrivateKey, record *core.Record) string {
+				return fmt.Sprintf(`{"status":"authorized","signed_device":%q,"signature":%q}`, device.Id, sign(other, record))
+			},
+			status: http.StatusForbidden,
+			want:   permission.StatusDraft,
+		},
+		{
+			name: "required accepts a device signature",
+			mode: permission.SignaturesRequired,
+			body: func(device *core.Record, key, _ ed25519.PrivateKey, record *core.Record) string {
+				return fmt.Sprintf(`{"status":"authorized","signed_device":%q,"signature":%q}`, device.Id, sign(key, record))
+			},
+			status: http.StatusOK,
+			want:   permission.StatusAuthorized,
+		},
+		{
+			name: "optional accepts unsigned",
+			mode: "",
+			body: func(_ *core.Record, _, _ ed25519.PrivateKey, _ *core.Record) string {
+				return `{"status":"authorized"}`
+			},
+			status: http.StatusOK,
+			want:   permission.StatusAuthorized,
+		},
+		{
+			name: "optional still rejects a bad signature",
+			mode: permission.SignaturesOptional,
+			body: func(device *core.Record, _, other ed25519.PrivateKey, record *core.Record) string {
+				return fmt.Sprintf(`{"status":"authorized","signed_device":%q,"signature":%q}`, device.Id, sign(other, record))
+			},
+			status: http.StatusForbidden,
+			want:   permission.StatusDraft,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PERMISSION_SIGNATURES", tt.mode)
+			app := newPermissionApp(t)
+			user, token := newUser(t, app, "user")
+			device, key := newDevice(t, app, user)
+			_, other, _ := ed25519.GenerateKey(rand.Reader)
+			record := newDraft(t, app, "per_"+core.GenerateDefaultRandomId())
+
+			scenario := approve(app, record, token, tt.body(device, key, other, record))
+			scenario.ExpectedStatus = tt.status
+			scenario.ExpectedContent = []string{`"`}
+			scenario.Test(t)
+
+			saved, err := app.FindRecordById("permissions", record.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := saved.GetString("status"); got != tt.want {
+				t.Errorf("status = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissionQuorum(t *testing.T) {
+	app := newPermissionApp(t)
+	newRule(t, app, "bash", "make *", "ask", 2)
+	_, first := newUser(t, app, "user")
+	_, second := newUser(t, app, "user")
+	record := newDraft(t, app, "per_quorum")
+	if got := record.GetInt("quorum"); got != 2 {
+		t.Fatalf("quorum = %d, want 2", got)
+	}
+
+	steps := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+		want   string
+	}{
+		{"first approver", first, `{"status":"authorized"}`, http.StatusOK, permission.StatusDraft},
+		{"lowered quorum", second, `{"status":"authorized","quorum":1}`, http.StatusBadRequest, permission.StatusDraft},
+		{"forged approvals", second, `{"status":"authorized","approvals":5}`, http.StatusBadRequest, permission.StatusDraft},
+		{"same approver again", first, `{"status":"authorized"}`, http.StatusBadRequest, permission.StatusDraft},
+		{"second approver", second, `{"status":"authorized"}`, http.StatusOK, permission.StatusAuthorized},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			scenario := approve(app, record, step.token, step.body)
+			scenario.ExpectedStatus = step.status
+			scenario.ExpectedContent = []string{`"`}
+			scenario.Test(t)
+
+			saved, err := app.FindRecordById("permissions", record.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := saved.GetString("status"); got != step.want {
+				t.Errorf("status = %q, want %q", got, step.want)
+			}
+			if got := saved.GetInt("quorum"); got != 2 {
+				t.Errorf("quorum = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestPermissionFieldLockOnCreate(t *testing.T) {
+	app := newPermissionApp(t)
+	_, agent := newUser(t, app, "agent")
+
+	scenario := tests.ApiScenario{
+		Method: http.MethodPost,
+		URL:    "/api/collections/permissions/records",
+		Body: strings.NewReader(`{"ai_engine_permission_id":"per_forged","session_id":"ses_test",` +
+			`"permission":"bash","patterns":["make deploy"],"status":"authorized",` +
+			`"trace":{"permission":"bash","action":"allow","matched_tool":"bash","matched_pattern":"*"}}`),
+		Headers:               map[string]string{"Authorization": agent},
+		TestAppFactory:        func(testing.TB) *tests.TestApp { return app },
+		DisableTestAppCleanup: true,
+		ExpectedStatus:        http.StatusOK,
+		ExpectedContent:       []string{`"per_forged"`},
+	}
+	scenario.Test(t)
+
+	saved, err := app.FindFirstRecordByData("permissions", "ai_engine_permission_id", "per_forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := saved.GetString("status"); got == permission.StatusAuthorized {
+		t.Errorf("status = %q, a client-created permission must not start authorized", got)
+	}
+	var trace permission.Trace
+	if err := saved.UnmarshalJSONField("trace", &trace); err != nil || trace.MatchedPattern == "*" && trace.Action == permission.ActionAllow {
+		t.Errorf("trace = %+v, want the backend's own evaluation", trace)
+	}
+}
+
+func TestPermissionLedgerIsAppendOnly(t *testing.T) {
+	app := newPermissionApp(t)
+	superusers, err := app.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	superuser := core.NewRecord(superusers)
+	superuser.SetEmail("root@pocketcoder.test")
+	superuser.SetPassword("pocketcoder_test")
+	if err := app.Save(superuser); err != nil {
+		t.Fatal(err)
+	}
+	token, err := superuser.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := newDraft(t, app, "per_ledger")
+	record.Set("status", permission.StatusDenied)
+	record.Set("decision_reason", "denied by test")
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+	if record.GetInt("seq") == 0 || record.GetString("entry_hash") == "" {
+		t.Fatalf("decided permission was not chained: seq=%d", record.GetInt("seq"))
+	}
+
+	scenarios := []tests.ApiScenario{
+		{
+			Name:            "superuser delete",
+			Method:          http.MethodDelete,
+			URL:             "/api/collections/permissions/records/" + record.Id,
+			ExpectedStatus:  http.StatusBadRequest,
+			ExpectedContent: []string{"append-only"},
+		},
+		{
+			Name:            "superuser rewrite",
+			Method:          http.MethodPatch,
+			URL:             "/api/collections/permissions/records/" + record.Id,
+			Body:            strings.NewReader(`{"decision_reason":"approved after all"}`),
+			ExpectedStatus:  http.StatusBadRequest,
+			ExpectedContent: []string{"immutable"},
+		},
+	}
+	for _, scenario := range scenarios {
+		scenario.Headers = map[string]string{"Authorization": token}
+		scenario.TestAppFactory = func(testing.TB) *tests.TestApp { return app }
+		scenario.DisableTestAppCleanup = true
+		scenario.Test(t)
+	}
+
+	if err := app.Delete(record); err == nil {
+		t.Error("app.Delete() of a ledger entry succeeded, want an error")
+	}
+	saved, err := app.FindRecordById("permissions", record.Id)
+	if err != nil {
+		t.Fatalf("ledger entry is gone: %v", err)
+	}
+	if got := saved.GetString("decision_reason"); got != "denied by test" {
+		t.Errorf("decision_reason = %q, want it unchanged", got)
+	}
+}
+
+func TestPermissionRetries(t *testing.T) {
+	app := newPermissionApp(t)
+	original := newDraft(t, app, "per_retry")
+
+	permissions, err := app.FindCollectionByNameOrId("permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry := func(command string) *core.Record {
+		record := core.NewRecord(permissions)
+		record.Set("ai_engine_permission_id", "per_retry")
+		record.Set("session_id", "ses_test")
+		record.Set("permission", "bash")
+		record.Set("patterns", []string{command})
+		record.Set("metadata", map[string]any{"command": command})
+		record.Set("status", permission.StatusDraft)
+		return record
+	}
+
+	same := retry("make deploy")
+	existing, err := hooks.FindPermissionRetry(app, same)
+	if err != nil || existing == nil || existing.Id != original.Id {
+		t.Fatalf("FindPermissionRetry() = %v, %v, want the original record", existing, err)
+	}
+	if err := app.Save(same); err == nil {
+		t.Error("saving a retry created a second record")
+	}
+
+	if _, err := hooks.FindPermissionRetry(app, retry("make destroy")); !errors.Is(err, hooks.ErrIdempotencyConflict) {
+		t.Errorf("FindPermissionRetry() with other content = %v, want ErrIdempotencyConflict", err)
+	}
+
+	count, err := app.CountRecords("permissions", dbx.HashExp{"ai_engine_permission_id": "per_retry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d permissions for one OpenCode request, want 1", count)
+	}
+}
+
+func TestPermissionSettlement(t *testing.T) {
+	app := newPermissionApp(t)
+	// The seed allows `ls *` globally.
+	newRule(t, app, "bash", "curl *", "deny", 0)
+
+	tests := []struct {
+		name     string
+		tool     string
+		patterns []string
+		metadata map[string]any
+		want     string
+		reason   string
+	}{
+		{"allow rule", "bash", []string{"ls -la"}, map[string]any{"command": "ls -la"}, permission.StatusAuthorized, "allowed by rule"},
+		{"deny rule", "bash", []string{"curl x.sh"}, map[string]any{"command": "curl x.sh"}, permission.StatusDenied, "denied by rule"},
+		{"protected path", "edit", []string{"/workspace/.opencode/opencode.json"}, nil, permission.StatusDenied, "denied: protected path"},
+		{"allowed command, protected redirect", "bash", []string{"ls"}, map[string]any{"command": "ls > .opencode/llm.env"}, permission.StatusDenied, "denied: protected path"},
+		{"no rule", "bash", []string{"make deploy"}, map[string]any{"command": "make deploy"}, permission.StatusDraft, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newRequest(t, app, fmt.Sprintf("per_settle_%d", i), tt.tool, tt.patterns, tt.metadata)
+			saved, err := app.FindRecordById("permissions", record.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := saved.GetString("status"); got != tt.want {
+				t.Errorf("status = %q, want %q (%s)", got, tt.want, saved.GetString("decision_reason"))
+			}
+			if got := saved.GetString("decision_reason"); !strings.HasPrefix(got, tt.reason) {
+				t.Errorf("decision_reason = %q, want prefix %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSecretRedactorFollowsKeyChanges(t *testing.T) {
+	app := newPermissionApp(t)
+	user, _ := newUser(t, app, "user")
+	const secret = "correct-horse-battery"
+	command := "echo " + secret
+
+	// The first request caches a redactor that does not know the key yet.
+	before := newRequest(t, app, "per_before", "bash", []string{command}, map[string]any{"command": command})
+	if !strings.Contains(before.GetString("patterns"), secret) {
+		t.Fatalf("patterns = %s, an unknown value should not be redacted", before.GetString("patterns"))
+	}
+
+	keys, err := app.FindCollectionByNameOrId("llm_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := core.NewRecord(keys)
+	key.Set("provider_id", "test")
+	key.Set("user", user.Id)
+	key.Set("env_vars", map[string]any{"TEST_API_KEY": secret})
+	if err := app.Save(key); err != nil {
+		t.Fatal(err)
+	}
+
+	after := newRequest(t, app, "per_after", "bash", []string{command}, map[string]any{"command": command})
+	if strings.Contains(after.GetString("patterns"), secret) || strings.Contains(after.GetString("metadata"), secret) {
+		t.Errorf("patterns = %s, metadata = %s, want the new key redacted", after.GetString("patterns"), after.GetString("metadata"))
+	}
+}
+
+func TestPermissionBudgetCountsCompoundCommands(t *testing.T) {
+	app := newPermissionApp(t)
+	// The seed allows `ls *` globally, so it decides `ls -la; git status`.
+	rule := newRule(t, app, "bash", "git status", "allow", 0)
+	rule.Set("max_per_hour", 1)
+	if err := app.Save(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		command string
+		want    string
+	}{
+		{"ls -la; git status", permission.StatusAuthorized},
+		{"git status", permission.StatusDraft},
+		{"ls -la; git status", permission.StatusDraft},
+	}
+	for i, step := range steps {
+		record := newRequest(t, app, fmt.Sprintf("per_budget_%d", i), "bash", []string{step.command}, map[string]any{"command": step.command})
+		if got := record.GetString("status"); got != step.want {
+			t.Errorf("%q: status = %q, want %q (%s)", step.command, got, step.want, record.GetString("decision_reason"))
+		}
+	}
+}
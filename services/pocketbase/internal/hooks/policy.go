@@ -0,0 +1,370 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Policy Sync. Reconciles tool_permissions with policy.yaml and exports it back.
+package hooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+const policySyncJob = "pc_policy_sync"
+
+// PolicyStatus is the outcome of the latest policy file sync.
+type PolicyStatus struct {
+	Path     string                   `json:"path"`
+	Found    bool                     `json:"found"`
+	SyncedAt string                   `json:"synced_at,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+	Report   *permission.PolicyReport `json:"report,omitempty"`
+}
+
+var (
+	policyMu     sync.Mutex
+	policyHash   string
+	policyStatus PolicyStatus
+
+	// suppressToolPermsRender batches a reconcile's writes into one
+	// opencode.json render and OpenCode restart.
+	suppressToolPermsRender atomic.Bool
+)
+
+// PolicyFilePath returns the policy file location: env POLICY_FILE, or
+// policy.yaml in the PocketBase data dir.
+func PolicyFilePath(app core.App) string {
+	if p := os.Getenv("POLICY_FILE"); p != "" {
+		return p
+	}
+	return filepath.Join(app.DataDir(), "policy.yaml")
+}
+
+// checkPolicyFilePath refuses a policy file inside the agent's workspace:
+// the agent could widen its own permissions by editing it.
+func checkPolicyFilePath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(permission.WorkspaceRoot, abs)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("policy file %s is inside the agent workspace %s; move it where the agent cannot write", path, permission.WorkspaceRoot)
+	}
+	return nil
+}
+
+// registerPolicySync syncs the policy file on startup and whenever its
+// content changes. A missing file leaves tool_permissions untouched.
+func registerPolicySync(app core.App) {
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		if _, err := SyncPolicyFile(app, false); err != nil {
+			log.Printf("⚠️ [Policy] Initial sync failed: %v", err)
+		}
+		return e.Next()
+	})
+
+	app.Cron().MustAdd(policySyncJob, "* * * * *", func() {
+		if _, err := SyncPolicyFile(app, false); err != nil {
+			log.Printf("⚠️ [Policy] Sync failed: %v", err)
+		}
+	})
+}
+
+// GetPolicyStatus returns the outcome of the latest sync.
+func GetPolicyStatus(app core.App) PolicyStatus {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	status := policyStatus
+	status.Path = PolicyFilePath(app)
+	return status
+}
+
+// SyncPolicyFile reconciles tool_permissions with the policy file. Unless
+// force is set, an unchanged file is skipped.
+func SyncPolicyFile(app core.App, force bool) (PolicyStatus, error) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	path := PolicyFilePath(app)
+	if err := checkPolicyFilePath(path); err != nil {
+		return policyStatus, recordPolicyError(path, err)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		policyHash = ""
+		policyStatus = PolicyStatus{Path: path}
+		return policyStatus, nil
+	}
+	if err != nil {
+		return policyStatus, recordPolicyError(path, err)
+	}
+
+	hash := contentHash(data)
+	if !force && hash == policyHash {
+		return policyStatus, nil
+	}
+	policyHash = hash
+
+	file, err := permission.ParsePolicyFile(data)
+	if err != nil {
+		return policyStatus, recordPolicyError(path, err)
+	}
+
+	report, err := reconcilePolicy(app, file)
+	if err != nil {
+		return policyStatus, recordPolicyError(path, err)
+	}
+
+	policyStatus = PolicyStatus{
+		Path:     path,
+		Found:    true,
+		SyncedAt: time.Now().UTC().Format(time.RFC3339),
+		Report:   &report,
+	}
+	log.Printf("📜 [Policy] Synced %s: %d added, %d updated, %d removed, %d conflicts, %d unmanaged",
+		path, len(report.Added), len(report.Updated), len(report.Removed), len(report.Conflicts), len(report.Unmanaged))
+	for _, c := range report.Conflicts {
+		log.Printf("⚠️ [Policy] Conflict: %s %s: %s (%s)", c.Tool, c.Pattern, c.Action, c.Reason)
+	}
+	return policyStatus, nil
+}
+
+func recordPolicyError(path string, err error) error {
+	policyStatus = PolicyStatus{
+		Path:     path,
+		Found:    true,
+		SyncedAt: time.Now().UTC().Format(time.RFC3339),
+		Error:    err.Error(),
+	}
+	return err
+}
+
+// reconcilePolicy applies a policy file in one transaction, then renders
+// opencode.json and restarts OpenCode once if anything changed.
+func reconcilePolicy(app core.App, file permission.PolicyFile) (permission.PolicyReport, error) {
+	refs, err := loadPolicyRefs(app)
+	if err != nil {
+		return permission.PolicyReport{}, err
+	}
+	existing, err := loadPolicyRecords(app, refs)
+	if err != nil {
+		return permission.PolicyReport{}, err
+	}
+	plan := permission.PlanPolicy(file, existing, refs.agents.known, refs.cronJobs.known)
+	if !plan.Report.Changed() {
+		return plan.Report, nil
+	}
+
+	collection, err := app.FindCollectionByNameOrId("tool_permissions")
+	if err != nil {
+		return plan.Report, err
+	}
+
+	suppressToolPermsRender.Store(true)
+	err = app.RunInTransaction(func(txApp core.App) error {
+		for _, rule := range plan.Create {
+			record := core.NewRecord(collection)
+			record.Set("agent", refs.agents.ids[rule.Agent])
+			record.Set("tool", rule.Tool)
+			record.Set("pattern", rule.Pattern)
+			setPolicyRule(record, rule, refs)
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("failed to add %s %s: %w", rule.Tool, rule.Pattern, err)
+			}
+		}
+		for _, rec := range plan.Update {
+			record, err := txApp.FindRecordById("tool_permissions", rec.ID)
+			if err != nil {
+				return err
+			}
+			setPolicyRule(record, rec.PolicyRule, refs)
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("failed to update %s %s: %w", rec.Tool, rec.Pattern, err)
+			}
+		}
+		for _, rec := range plan.Delete {
+			record, err := txApp.FindRecordById("tool_permissions", rec.ID)
+			if err != nil {
+				return err
+			}
+			if err := txApp.Delete(record); err != nil {
+				return fmt.Errorf("failed to remove %s %s: %w", rec.Tool, rec.Pattern, err)
+			}
+		}
+		return nil
+	})
+	suppressToolPermsRender.Store(false)
+	if err != nil {
+		return plan.Report, err
+	}
+
+	if err := renderOpenCodeConfig(app); err != nil {
+		log.Printf("[ToolPerms] Failed to render opencode.json: %v", err)
+	} else if err := restartOpenCode(); err != nil {
+		log.Printf("[ToolPerms] Failed to restart OpenCode: %v", err)
+	}
+	return plan.Report, nil
+}
+
+func setPolicyRule(record *core.Record, rule permission.PolicyRule, refs policyRefs) {
+	record.Set("action", rule.Action)
+	record.Set("draft_ttl", rule.DraftTTL)
+	record.Set("active", rule.IsActive())
+	record.Set("quorum", rule.Quorum)
+	record.Set("quorum_group", rule.QuorumGroup)
+	record.Set("weekdays", rule.Weekdays)
+	record.Set("hours", rule.Hours)
+	record.Set("timezone", rule.Timezone)
+	record.Set("cron_job", refs.cronJobs.ids[rule.CronJob])
+	record.Set("max_per_hour", rule.MaxPerHour)
+}
+
+// ExportPolicyFile writes the current global and agent rules to the policy
+// file and returns its content. Chat-scoped rules are never exported.
+func ExportPolicyFile(app core.App) (string, []byte, error) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	refs, err := loadPolicyRefs(app)
+	if err != nil {
+		return "", nil, err
+	}
+	records, err := loadPolicyRecords(app, refs)
+	if err != nil {
+		return "", nil, err
+	}
+	rules := make([]permission.PolicyRule, 0, len(records))
+	for _, rec := range records {
+		rules = append(rules, rec.PolicyRule)
+	}
+	data, err := permission.MarshalPolicyFile(rules)
+	if err != nil {
+		return "", nil, err
+	}
+
+	path := PolicyFilePath(app)
+	if err := checkPolicyFilePath(path); err != nil {
+		return path, nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return path, nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return path, nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return path, nil, err
+	}
+
+	// The file now mirrors the database; the next poll has nothing to do.
+	policyHash = contentHash(data)
+	log.Printf("📜 [Policy] Exported %d rules to %s", len(rules), path)
+	return path, data, nil
+}
+
+// nameIndex maps record IDs to names and names to the first matching ID.
+type nameIndex struct {
+	names map[string]string
+	ids   map[string]string
+}
+
+func (n nameIndex) known(name string) bool {
+	_, ok := n.ids[name]
+	return ok
+}
+
+// policyRefs resolves the agent and cron job names used in the policy file.
+type policyRefs struct {
+	agents   nameIndex
+	cronJobs nameIndex
+}
+
+func loadPolicyRefs(app core.App) (policyRefs, error) {
+	var refs policyRefs
+	for _, target := range []struct {
+		collection string
+		index      *nameIndex
+	}{{"ai_agents", &refs.agents}, {"cron_jobs", &refs.cronJobs}} {
+		records, err := app.FindAllRecords(target.collection)
+		if err != nil {
+			return refs, err
+		}
+		*target.index = nameIndex{names: map[string]string{}, ids: map[string]string{"": ""}}
+		for _, rec := range records {
+			name := rec.GetString("name")
+			target.index.names[rec.Id] = name
+			if _, ok := target.index.ids[name]; !ok {
+				target.index.ids[name] = rec.Id
+			}
+		}
+	}
+	return refs, nil
+}
+
+// loadPolicyRecords returns the global and agent rules as policy records.
+func loadPolicyRecords(app core.App, refs policyRefs) ([]permission.PolicyRecord, error) {
+	records, err := app.FindRecordsByFilter("tool_permissions", "chat = ''", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]permission.PolicyRecord, 0, len(records))
+	for _, rec := range records {
+		rule := permission.PolicyRule{
+			Agent:       refs.agents.names[rec.GetString("agent")],
+			Tool:        rec.GetString("tool"),
+			Pattern:     rec.GetString("pattern"),
+			Action:      rec.GetString("action"),
+			DraftTTL:    rec.GetInt("draft_ttl"),
+			Quorum:      rec.GetInt("quorum"),
+			QuorumGroup: rec.GetString("quorum_group"),
+		}
+		conditions := permission.ConditionsFromRecord(rec)
+		rule.Weekdays = conditions.Weekdays
+		rule.Hours = conditions.Hours
+		rule.Timezone = conditions.Timezone
+		rule.CronJob = refs.cronJobs.names[conditions.CronJobID]
+		rule.MaxPerHour = conditions.MaxPerHour
+		if !rec.GetBool("active") {
+			inactive := false
+			rule.Active = &inactive
+		}
+		out = append(out, permission.PolicyRecord{
+			ID:         rec.Id,
+			PolicyRule: rule,
+			Remembered: rec.GetString("source_permission") != "",
+		})
+	}
+	return out, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,179 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Secret Redaction. Masks credentials in permission records and push notifications.
+package hooks
+
+import (
+	"log"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/redact"
+)
+
+// registerPermissionRedaction masks secrets in new permission records before
+// they are stored. It runs after the request was scored and evaluated, so
+// both saw the real command, and before the ledger hashes the record.
+func registerPermissionRedaction(app core.App) {
+	app.OnRecordCreate("permissions").BindFunc(func(e *core.RecordEvent) error {
+		secrets := redactPermission(e.App, e.Record)
+		if len(secrets) == 0 {
+			e.Record.Set("redacted", []string{})
+			return e.Next()
+		}
+
+		kinds := make([]string, 0, len(secrets))
+		seen := map[string]bool{}
+		for _, s := range secrets {
+			if !seen[s.Kind] {
+				seen[s.Kind] = true
+				kinds = append(kinds, s.Kind)
+			}
+		}
+		e.Record.Set("redacted", kinds)
+		// Approvers sign what they can see
+		e.Record.Set("request_hash", permissionRequestHash(e.Record))
+
+		log.Printf("🙈 [Permission Firewall] Redacted secrets (%v) from %s request", kinds, e.Record.GetString("permission"))
+		return e.Next()
+	})
+
+	// The known secrets change only with llm_keys and mcp_servers.
+	invalidateSecretRedactor()
+	for _, collection := range []string{"llm_keys", "mcp_servers"} {
+		app.OnRecordAfterCreateSuccess(collection).BindFunc(invalidateSecrets)
+		app.OnRecordAfterUpdateSuccess(collection).BindFunc(invalidateSecrets)
+		app.OnRecordAfterDeleteSuccess(collection).BindFunc(invalidateSecrets)
+	}
+}
+
+// secretRedactor caches the redactor SecretRedactor builds. gen counts
+// invalidations, so a load that raced one is not cached.
+var secretRedactor struct {
+	sync.Mutex
+	r   *redact.Redactor
+	gen int
+}
+
+func invalidateSecrets(e *core.RecordEvent) error {
+	invalidateSecretRedactor()
+	return e.Next()
+}
+
+func invalidateSecretRedactor() {
+	secretRedactor.Lock()
+	defer secretRedactor.Unlock()
+	secretRedactor.r = nil
+	secretRedactor.gen++
+}
+
+// SecretRedactor returns a redactor that knows the configured LLM keys and
+// MCP server secrets on top of the built-in key formats. It is cached until
+// an llm_keys or mcp_servers record changes.
+func SecretRedactor(app core.App) *redact.Redactor {
+	secretRedactor.Lock()
+	r, gen := secretRedactor.r, secretRedactor.gen
+	secretRedactor.Unlock()
+	if r != nil {
+		return r
+	}
+
+	r, complete := loadSecretRedactor(app)
+	secretRedactor.Lock()
+	if complete && secretRedactor.gen == gen {
+		secretRedactor.r = r
+	}
+	secretRedactor.Unlock()
+	return r
+}
+
+// loadSecretRedactor builds the redactor from the database. It reports
+// whether every secret could be loaded.
+func loadSecretRedactor(app core.App) (*redact.Redactor, bool) {
+	var known []string
+	complete := true
+	collect := func(collection, field string) {
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			log.Printf("⚠️ [Redaction] Failed to load %s: %v", collection, err)
+			complete = false
+			return
+		}
+		for _, record := range records {
+			var values any
+			if err := record.UnmarshalJSONField(field, &values); err == nil {
+				known = appendStrings(known, values)
+			}
+		}
+	}
+	collect("llm_keys", "env_vars")
+	collect("mcp_servers", "config")
+	return redact.New(known...), complete
+}
+
+// redactPermission masks the secrets in a permission's request content, and
+// wherever that content was copied into its trace and risk reasons. It
+// returns the secrets it removed.
+func redactPermission(app core.App, record *core.Record) []redact.Secret {
+	r := SecretRedactor(app)
+
+	var secrets []redact.Secret
+	for _, field := range []string{"metadata", "patterns"} {
+		var value any
+		if err := record.UnmarshalJSONField(field, &value); err != nil || value == nil {
+			continue
+		}
+		masked, found := r.Value(value)
+		if len(found) > 0 {
+			record.Set(field, masked)
+			secrets = append(secrets, found...)
+		}
+	}
+	if masked, found := r.Value(record.GetString("message")); len(found) > 0 {
+		record.Set("message", masked)
+		secrets = append(secrets, found...)
+	}
+
+	if len(secrets) > 0 {
+		for _, field := range []string{"trace", "risk_reasons"} {
+			var value any
+			if err := record.UnmarshalJSONField(field, &value); err == nil && value != nil {
+				record.Set(field, redact.Replace(value, secrets))
+			}
+		}
+	}
+	return secrets
+}
+
+// appendStrings appends every string in a decoded JSON value.
+func appendStrings(dst []string, value any) []string {
+	switch v := value.(type) {
+	case string:
+		return append(dst, v)
+	case []any:
+		for _, e := range v {
+			dst = appendStrings(dst, e)
+		}
+	case map[string]any:
+		for _, e := range v {
+			dst = appendStrings(dst, e)
+		}
+	}
+	return dst
+}
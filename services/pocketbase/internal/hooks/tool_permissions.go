@@ -20,13 +20,17 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package hooks
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/glob"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
 const (
@@ -40,11 +44,21 @@ type permEntry struct {
 }
 
 // RegisterToolPermissionHooks registers hooks that re-render the OpenCode config
-// whenever tool_permissions or ai_agents change.
+// whenever tool_permissions or ai_agents change, and syncs policy.yaml into
+// tool_permissions.
 func RegisterToolPermissionHooks(app core.App) {
 	log.Println("[ToolPerms] Registering tool permission hooks...")
 
 	handleToolPermsChange := func(e *core.RecordEvent) error {
+		permission.ForgetRule(e.Record.Id)
+		// Chat-scoped rules are enforced by the backend only; OpenCode never sees them.
+		if e.Record.GetString("chat") != "" {
+			return e.Next()
+		}
+		// A policy file sync renders once after all of its writes.
+		if suppressToolPermsRender.Load() {
+			return e.Next()
+		}
 		log.Println("[ToolPerms] Tool permissions changed, re-rendering opencode.json...")
 		if err := renderOpenCodeConfig(app); err != nil {
 			log.Printf("[ToolPerms] Failed to render opencode.json: %v", err)
@@ -56,10 +70,38 @@ func RegisterToolPermissionHooks(app core.App) {
 		return e.Next()
 	}
 
+	validateRule := func(e *core.RecordRequestEvent) error {
+		if err := permission.ValidatePatterns(e.Record.GetString("tool"), e.Record.GetString("pattern")); err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		if err := permission.ConditionsFromRecord(e.Record).Validate(); err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+		return e.Next()
+	}
+	app.OnRecordCreateRequest("tool_permissions").BindFunc(validateRule)
+	app.OnRecordUpdateRequest("tool_permissions").BindFunc(validateRule)
+
 	app.OnRecordAfterCreateSuccess("tool_permissions").BindFunc(handleToolPermsChange)
 	app.OnRecordAfterUpdateSuccess("tool_permissions").BindFunc(handleToolPermsChange)
 	app.OnRecordAfterDeleteSuccess("tool_permissions").BindFunc(handleToolPermsChange)
 
+	// Protected paths are rendered alongside the rules
+	handleProtectedPathsChange := func(e *core.RecordEvent) error {
+		log.Println("[ToolPerms] Protected paths changed, re-rendering opencode.json...")
+		if err := renderOpenCodeConfig(app); err != nil {
+			log.Printf("[ToolPerms] Failed to render opencode.json: %v", err)
+			return e.Next()
+		}
+		if err := restartOpenCode(); err != nil {
+			log.Printf("[ToolPerms] Failed to restart OpenCode: %v", err)
+		}
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("protected_paths").BindFunc(handleProtectedPathsChange)
+	app.OnRecordAfterUpdateSuccess("protected_paths").BindFunc(handleProtectedPathsChange)
+	app.OnRecordAfterDeleteSuccess("protected_paths").BindFunc(handleProtectedPathsChange)
+
 	// Also re-render when agent model or prompt changes
 	app.OnRecordAfterUpdateSuccess("ai_agents").BindFunc(func(e *core.RecordEvent) error {
 		log.Println("[ToolPerms] Agent updated, re-rendering opencode.json...")
@@ -83,6 +125,9 @@ func RegisterToolPermissionHooks(app core.App) {
 		}
 		return e.Next()
 	})
+
+	// Keep tool_permissions in sync with the policy-as-code file
+	registerPolicySync(app)
 }
 
 // renderOpenCodeConfig reads the existing opencode.json, patches the permission
@@ -99,10 +144,10 @@ func renderOpenCodeConfig(app core.App) error {
 		return fmt.Errorf("failed to parse opencode.json: %w", err)
 	}
 
-	// Query all active tool_permissions
+	// Query all active tool_permissions (chat-scoped rules stay in the backend)
 	allPerms, err := app.FindRecordsByFilter(
 		"tool_permissions",
-		"active = true",
+		"active = true && chat = ''",
 		"",
 		0, 0,
 	)
@@ -120,6 +165,22 @@ func renderOpenCodeConfig(app core.App) error {
 			pattern: rec.GetString("pattern"),
 			action:  rec.GetString("action"),
 		}
+		// OpenCode cannot check rule conditions or read full globs, so it asks the backend
+		if entry.action != permission.ActionAsk && (!permission.ConditionsFromRecord(rec).IsZero() ||
+			!glob.IsWildcard(entry.tool) || !glob.IsWildcard(entry.pattern)) {
+			entry.action = permission.ActionAsk
+		}
+		// OpenCode matches a bash allow glob against the whole command line,
+		// so `ls *` would let `ls; rm -rf /` through. The backend parses the
+		// command and allows each part on its own.
+		if entry.action == permission.ActionAllow && entry.tool == "bash" {
+			entry.action = permission.ActionAsk
+		}
+		// OpenCode only sees the gateway's tool names; the backend maps them
+		// back onto mcp:<server>/<tool> rules
+		if permission.IsMcpRule(entry.tool) {
+			entry = permEntry{tool: permission.McpGatewayPrefix + "*", pattern: "*", action: permission.ActionAsk}
+		}
 		agentId := rec.GetString("agent")
 		if agentId == "" {
 			globalPerms = append(globalPerms, entry)
@@ -128,9 +189,19 @@ func renderOpenCodeConfig(app core.App) error {
 		}
 	}
 
+	// Protected paths are rendered too, so OpenCode cannot allow a write to
+	// them on its own
+	protected, err := permission.LoadProtectedPaths(app)
+	if err != nil {
+		log.Printf("[ToolPerms] Failed to load protected_paths, rendering the built-in list only: %v", err)
+	}
+	var protectedPerms []permEntry
+	for _, rule := range permission.OpenCodeProtectedRules(append(append([]permission.ProtectedPath{}, permission.BuiltinProtectedPaths...), protected...)) {
+		protectedPerms = append(protectedPerms, permEntry{tool: rule.Tool, pattern: rule.Pattern, action: rule.Action})
+	}
+
 	// Build global permission block
-	globalPermBlock := buildPermissionBlock(globalPerms)
-	config["permission"] = globalPermBlock
+	config["permission"] = buildPermissionBlock(globalPerms, protectedPerms)
 
 	// Query all agents for model/prompt rendering
 	agents, err := app.FindRecordsByFilter(
@@ -183,10 +254,13 @@ func renderOpenCodeConfig(app core.App) error {
 		agentConfig["description"] = agent.GetString("description")
 		agentConfig["mode"] = agent.GetString("mode")
 
-		// Build per-agent permission block
-		if perms, exists := agentPermsMap[agent.Id]; exists {
-			agentConfig["permission"] = buildPermissionBlock(perms)
+		// Build per-agent permission block. Agent entries are applied after
+		// the global ones, so the protected paths are repeated here.
+		perms, exists := agentPermsMap[agent.Id]
+		if !exists {
+			perms = permEntriesFromConfig(agentConfig["permission"])
 		}
+		agentConfig["permission"] = buildPermissionBlock(perms, protectedPerms)
 
 		agentBlock[agentName] = agentConfig
 	}
@@ -210,27 +284,135 @@ func renderOpenCodeConfig(app core.App) error {
 // buildPermissionBlock converts a list of permission entries into the OpenCode
 // permission format. Tools with only pattern="*" get flat format ("tool": "action").
 // Tools with multiple patterns get nested format ("tool": {"pattern": "action", ...}).
-func buildPermissionBlock(perms []permEntry) map[string]interface{} {
-	// Group by tool
+//
+// OpenCode applies the last entry that matches, so the protected entries are
+// written after the rules: the tools they cover move to the end of the block
+// and their patterns to the end of the tool.
+func buildPermissionBlock(perms, protected []permEntry) *permissionBlock {
+	// Group by tool; when two entries share a tool and pattern the more
+	// restrictive action is kept.
 	toolPatterns := make(map[string][]permEntry)
 	for _, p := range perms {
-		toolPatterns[p.tool] = append(toolPatterns[p.tool], p)
+		duplicate := false
+		for i, e := range toolPatterns[p.tool] {
+			if e.pattern == p.pattern {
+				duplicate = true
+				if permission.Severity(p.action) > permission.Severity(e.action) {
+					toolPatterns[p.tool][i] = p
+				}
+			}
+		}
+		if !duplicate {
+			toolPatterns[p.tool] = append(toolPatterns[p.tool], p)
+		}
+	}
+
+	protectedTools := make(map[string]bool)
+	for _, p := range protected {
+		protectedTools[p.tool] = true
+	}
+	tools := make([]string, 0, len(toolPatterns))
+	for tool := range toolPatterns {
+		tools = append(tools, tool)
 	}
+	sort.SliceStable(tools, func(i, j int) bool {
+		if protectedTools[tools[i]] != protectedTools[tools[j]] {
+			return protectedTools[tools[j]]
+		}
+		return tools[i] < tools[j]
+	})
 
-	result := make(map[string]interface{})
-	for tool, entries := range toolPatterns {
-		if len(entries) == 1 && entries[0].pattern == "*" {
+	result := &permissionBlock{}
+	for _, tool := range tools {
+		entries := toolPatterns[tool]
+		if len(entries) == 1 && entries[0].pattern == "*" && !protectedTools[tool] {
 			// Flat format: "tool": "action"
-			result[tool] = entries[0].action
-		} else {
-			// Nested format: "tool": {"pattern": "action", ...}
-			nested := make(map[string]interface{})
-			for _, e := range entries {
-				nested[e.pattern] = e.action
-			}
-			result[tool] = nested
+			result.set(tool, entries[0].action)
+			continue
+		}
+		// Nested format: "tool": {"pattern": "action", ...}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].pattern < entries[j].pattern })
+		nested := &permissionBlock{}
+		for _, e := range entries {
+			nested.set(e.pattern, e.action)
 		}
+		result.set(tool, nested)
+	}
+
+	for _, p := range protected {
+		nested, ok := result.values[p.tool].(*permissionBlock)
+		if !ok {
+			nested = &permissionBlock{}
+			result.set(p.tool, nested)
+		}
+		nested.set(p.pattern, p.action)
 	}
 
 	return result
 }
+
+// permEntriesFromConfig reads a permission block already in opencode.json.
+func permEntriesFromConfig(block any) []permEntry {
+	m, _ := block.(map[string]interface{})
+	var perms []permEntry
+	for tool, v := range m {
+		switch v := v.(type) {
+		case string:
+			perms = append(perms, permEntry{tool: tool, pattern: "*", action: v})
+		case map[string]interface{}:
+			for pattern, action := range v {
+				if action, ok := action.(string); ok {
+					perms = append(perms, permEntry{tool: tool, pattern: pattern, action: action})
+				}
+			}
+		}
+	}
+	return perms
+}
+
+// permissionBlock is a JSON object that keeps its keys in insertion order,
+// since OpenCode evaluates permission entries in the order they are written.
+type permissionBlock struct {
+	keys   []string
+	values map[string]any
+}
+
+// set stores value under key, moving the key to the end.
+func (b *permissionBlock) set(key string, value any) {
+	if b.values == nil {
+		b.values = make(map[string]any)
+	}
+	if _, exists := b.values[key]; exists {
+		for i, k := range b.keys {
+			if k == key {
+				b.keys = append(b.keys[:i], b.keys[i+1:]...)
+				break
+			}
+		}
+	}
+	b.keys = append(b.keys, key)
+	b.values[key] = value
+}
+
+func (b *permissionBlock) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range b.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(b.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
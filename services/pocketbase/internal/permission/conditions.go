@@ -0,0 +1,210 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Rule Conditions. Time windows, cron job scope and hourly budgets for tool_permissions.
+package permission
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// weekdayNames are the accepted weekday values, indexed by time.Weekday.
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Conditions restrict when a rule's allow or deny applies. A request that
+// matches the rule but falls outside its conditions resolves to ask.
+type Conditions struct {
+	// Weekdays the rule applies on ("mon".."sun"). Empty means every day.
+	Weekdays []string `json:"weekdays,omitempty"`
+	// Hours is a daily window "HH:MM-HH:MM"; the end is exclusive and a
+	// window ending before it starts runs past midnight.
+	Hours string `json:"hours,omitempty"`
+	// Timezone is the IANA zone Weekdays and Hours are read in. Empty means
+	// the server's local time.
+	Timezone string `json:"timezone,omitempty"`
+	// CronJobID limits the rule to requests made by that cron job's runs.
+	CronJobID string `json:"cron_job,omitempty"`
+	// MaxPerHour caps how many requests the rule auto-approves per session
+	// in a rolling hour.
+	MaxPerHour int `json:"max_per_hour,omitempty"`
+}
+
+// ConditionsFromRecord reads the condition fields of a tool_permissions record.
+func ConditionsFromRecord(rec *core.Record) Conditions {
+	var c Conditions
+	_ = rec.UnmarshalJSONField("weekdays", &c.Weekdays)
+	c.Hours = rec.GetString("hours")
+	c.Timezone = rec.GetString("timezone")
+	c.CronJobID = rec.GetString("cron_job")
+	c.MaxPerHour = rec.GetInt("max_per_hour")
+	return c
+}
+
+// LoadRuleUsage counts, for every rule with an hourly budget, the requests it
+// auto-approved in sessionID during the last hour. A request counts for every
+// rule that allowed one of its subjects, not only the decisive one, so that
+// chaining commands does not get around the budget. Counting failures use up
+// the budget, so an unavailable count never approves more than allowed.
+func LoadRuleUsage(app core.App, rules []Rule, sessionID string) map[string]int {
+	usage := map[string]int{}
+	budgeted := map[string]bool{}
+	for _, r := range rules {
+		if r.MaxPerHour > 0 {
+			budgeted[r.ID] = true
+		}
+	}
+	if len(budgeted) == 0 {
+		return usage
+	}
+
+	since := time.Now().UTC().Add(-time.Hour).Format("2006-01-02 15:04:05.000Z")
+	records, err := app.FindRecordsByFilter(
+		"permissions",
+		"session_id = {:session} && created >= {:since} && trace.action = 'allow'",
+		"", 0, 0,
+		map[string]any{"session": sessionID, "since": since},
+	)
+	if err != nil {
+		log.Printf("⚠️ [Authority] Failed to count approvals of budgeted rules: %v", err)
+		for _, r := range rules {
+			if r.MaxPerHour > 0 {
+				usage[r.ID] = r.MaxPerHour
+			}
+		}
+		return usage
+	}
+
+	for _, record := range records {
+		var trace Trace
+		if err := record.UnmarshalJSONField("trace", &trace); err != nil {
+			continue
+		}
+		counted := map[string]bool{}
+		for _, st := range trace.Subjects {
+			if st.Action == ActionAllow && budgeted[st.RuleID] && !counted[st.RuleID] {
+				counted[st.RuleID] = true
+				usage[st.RuleID]++
+			}
+		}
+	}
+	return usage
+}
+
+// IsZero reports whether the rule is unconditional.
+func (c Conditions) IsZero() bool {
+	return len(c.Weekdays) == 0 && c.Hours == "" && c.CronJobID == "" && c.MaxPerHour == 0
+}
+
+// Validate checks the condition values.
+func (c Conditions) Validate() error {
+	for _, d := range c.Weekdays {
+		if !slices.Contains(weekdayNames, strings.ToLower(d)) {
+			return fmt.Errorf("invalid weekday %q (use mon, tue, ... sun)", d)
+		}
+	}
+	if c.Hours != "" {
+		if _, _, err := parseHours(c.Hours); err != nil {
+			return err
+		}
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", c.Timezone)
+		}
+	}
+	if c.MaxPerHour < 0 {
+		return fmt.Errorf("max_per_hour must not be negative")
+	}
+	return nil
+}
+
+// check reports whether the conditions hold for input, given how many
+// requests the rule already auto-approved in the session this hour. The
+// returned note explains the first condition that failed.
+func (c Conditions) check(input EvaluationInput, used int) (bool, string) {
+	if c.CronJobID != "" && input.CronJobID != c.CronJobID {
+		return false, "rule only applies to cron job " + c.CronJobID
+	}
+
+	if len(c.Weekdays) > 0 || c.Hours != "" {
+		loc := time.Local
+		if c.Timezone != "" {
+			l, err := time.LoadLocation(c.Timezone)
+			if err != nil {
+				return false, fmt.Sprintf("invalid timezone %q", c.Timezone)
+			}
+			loc = l
+		}
+		now := input.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		now = now.In(loc)
+
+		if len(c.Weekdays) > 0 && !slices.ContainsFunc(c.Weekdays, func(d string) bool {
+			return strings.EqualFold(d, weekdayNames[now.Weekday()])
+		}) {
+			return false, fmt.Sprintf("outside allowed days (%s)", strings.Join(c.Weekdays, ", "))
+		}
+		if c.Hours != "" {
+			from, to, err := parseHours(c.Hours)
+			if err != nil {
+				return false, err.Error()
+			}
+			minute := now.Hour()*60 + now.Minute()
+			inside := minute >= from && minute < to
+			if to <= from {
+				inside = minute >= from || minute < to
+			}
+			if !inside {
+				return false, "outside allowed hours " + c.Hours
+			}
+		}
+	}
+
+	if c.MaxPerHour > 0 && used >= c.MaxPerHour {
+		return false, fmt.Sprintf("hourly budget of %d auto-approvals per session used up", c.MaxPerHour)
+	}
+	return true, ""
+}
+
+// parseHours parses "HH:MM-HH:MM" into minutes since midnight.
+func parseHours(s string) (int, int, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid hours %q (use HH:MM-HH:MM)", s)
+	}
+	var minutes [2]int
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid hours %q (use HH:MM-HH:MM)", s)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	if minutes[0] == minutes[1] {
+		return 0, 0, fmt.Errorf("invalid hours %q: empty window", s)
+	}
+	return minutes[0], minutes[1], nil
+}
@@ -16,14 +16,30 @@ You should have received a copy of the GNU Affero General Public License
 along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
-// @pocketcoder-core: Permission Evaluator. Checks requests against whitelisted action patterns.
+// @pocketcoder-core: Permission Evaluator. Resolves requests against tool_permissions allow/ask/deny rules.
 package permission
 
 import (
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/qtpi-automaton/pocketcoder/backend/internal/utils"
+)
+
+// Rule actions, mirroring the tool_permissions "action" select values.
+const (
+	ActionAllow = "allow"
+	ActionAsk   = "ask"
+	ActionDeny  = "deny"
+)
+
+// Permission record statuses produced by the evaluator.
+const (
+	StatusAuthorized = "authorized"
+	StatusDraft      = "draft"
+	StatusDenied     = "denied"
 )
 
 // EvaluationInput represents the data needed to evaluate a permission request.
@@ -31,52 +47,669 @@ type EvaluationInput struct {
 	Permission string
 	Patterns   []string
 	Metadata   map[string]any
+	// AgentID is the ai_agents record the request runs under. Empty means
+	// only global rules apply.
+	AgentID string
+	// Agent is the agent's display name, carried into the trace.
+	Agent string
+	// ChatID is the chat the request belongs to; its chat-scoped rules apply.
+	ChatID string
+	// SessionID is the OpenCode session, which hourly budgets are counted in.
+	SessionID string
+	// CronJobID is the cron job whose run made the request, if any.
+	CronJobID string
+	// Now is the time conditions are checked at. Zero means time.Now().
+	Now time.Time
+}
+
+// Rule is a single tool_permissions entry.
+type Rule struct {
+	ID      string `json:"id,omitempty"`
+	AgentID string `json:"agent,omitempty"`
+	// ChatID is set for rules remembered for a single chat.
+	ChatID  string `json:"chat,omitempty"`
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	// DraftTTL is how long drafts for this tool stay open, in seconds.
+	// Zero means the default TTL.
+	DraftTTL int `json:"draft_ttl,omitempty"`
+	// Quorum is the number of distinct approvers an ask needs. Zero or one
+	// means a single approval. QuorumGroup limits who counts: "admin" for the
+	// admin role, another name for members of that users group.
+	Quorum      int    `json:"quorum,omitempty"`
+	QuorumGroup string `json:"quorum_group,omitempty"`
+	Conditions
+}
+
+// Policy is the rule set a request is evaluated against.
+type Policy struct {
+	Rules []Rule
+	// Usage counts the requests each budgeted rule (MaxPerHour > 0)
+	// auto-approved in the session during the last hour, by rule ID.
+	Usage map[string]int
+	// Protected are admin-defined protected paths, enforced on top of
+	// BuiltinProtectedPaths.
+	Protected []ProtectedPath
+	// McpTools maps the OpenCode names of MCP gateway tools onto their
+	// mcp:<server>/<tool> rule names (see LoadMcpTools).
+	McpTools map[string][]string
+}
+
+// Decision is the outcome of an evaluation together with the trace explaining it.
+type Decision struct {
+	Permitted bool   `json:"permitted"`
+	Status    string `json:"status"`
+	Action    string `json:"action"`
+	Trace     Trace  `json:"trace"`
 }
 
-// Evaluate checks if a permission request is whitelisted based on actions.
-func Evaluate(app core.App, input EvaluationInput) (bool, string) {
+// Trace records how the evaluator arrived at a decision.
+type Trace struct {
+	Permission string `json:"permission"`
+	// RequestedAs is the tool name OpenCode used when the request was
+	// evaluated under another name, such as an MCP gateway tool.
+	RequestedAs    string           `json:"requested_as,omitempty"`
+	Agent          string           `json:"agent,omitempty"`
+	AgentID        string           `json:"agent_id,omitempty"`
+	Scope          string           `json:"scope"`
+	Candidates     []CandidateTrace `json:"candidates"`
+	Subjects       []SubjectTrace   `json:"subjects"`
+	MatchedRuleID  string           `json:"matched_rule_id,omitempty"`
+	MatchedTool    string           `json:"matched_tool,omitempty"`
+	MatchedPattern string           `json:"matched_pattern,omitempty"`
+	Reason         string           `json:"reason"`
+	Action         string           `json:"action"`
+	// Quorum is the largest approver quorum of the subjects, if the request
+	// asks.
+	Quorum      int    `json:"quorum,omitempty"`
+	QuorumGroup string `json:"quorum_group,omitempty"`
+	// Conditional is set when an allow came from a rule with conditions,
+	// which OpenCode cannot check, so the backend approves it.
+	Conditional bool `json:"conditional,omitempty"`
+}
+
+// CandidateTrace is a rule whose tool matched the request.
+type CandidateTrace struct {
+	RuleID  string `json:"rule_id"`
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Scope   string `json:"scope"`
+	// Applied is false when the rule was shadowed by the other scope.
+	Applied bool `json:"applied"`
+}
+
+// SubjectTrace is the resolution of a single subject (a command or a path).
+type SubjectTrace struct {
+	Subject string `json:"subject"`
+	Kind    string `json:"kind"`
+	// Permission is the tool the subject was evaluated as; a bash redirection
+	// is evaluated as an edit of its target.
+	Permission  string `json:"permission"`
+	Scope       string `json:"scope"`
+	RuleID      string `json:"rule_id,omitempty"`
+	Tool        string `json:"tool,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason"`
+	Quorum      int    `json:"quorum,omitempty"`
+	QuorumGroup string `json:"quorum_group,omitempty"`
+	// Conditional is set when the winning rule's conditions held.
+	Conditional bool `json:"conditional,omitempty"`
+	// Protected is the protected path pattern the subject touched, if any.
+	Protected string `json:"protected,omitempty"`
+}
+
+// Subject kinds as reported in traces.
+const (
+	SubjectCommand  = "command"
+	SubjectRedirect = "redirect"
+	SubjectPath     = "path"
+	SubjectPattern  = "pattern"
+)
+
+// subject is a single string to authorize, together with the tool it is
+// evaluated as and the minimum action it can resolve to.
+type subject struct {
+	permission string
+	value      string
+	kind       string
+	floor      string
+	note       string
+	// args are the words of a bash command, checked against protected paths.
+	args []string
+	// target is the redirection target as written.
+	target string
+	// dirs are the directories a bash command may run in once it changes
+	// directory; none means the workspace root.
+	dirs []string
+}
+
+// Rule scopes as reported in traces.
+const (
+	ScopeChat   = "chat"
+	ScopeAgent  = "agent"
+	ScopeGlobal = "global"
+)
+
+// Evaluate resolves a permission request against the active tool_permissions
+// rules and returns the decision along with its trace.
+func Evaluate(app core.App, input EvaluationInput) Decision {
 	log.Printf("🛡️ [Authority] Evaluating Verb: %s, Nouns: %v", input.Permission, input.Patterns)
 
-	isWhitelisted := false
+	policy := LoadPolicy(app, input.AgentID, input.ChatID)
+	policy.Usage = LoadRuleUsage(app, policy.Rules, input.SessionID)
+	decision := Resolve(policy, input)
+	log.Printf("🛡️ [Authority] %s -> %s (%s)", input.Permission, decision.Status, decision.Trace.Reason)
+
+	return decision
+}
+
+// LoadPolicy loads the rules and protected paths relevant to agentID and
+// chatID. Load failures are logged and leave the affected part empty, which
+// only ever makes the evaluator stricter.
+func LoadPolicy(app core.App, agentID, chatID string) Policy {
+	var policy Policy
+	var err error
+
+	if policy.Rules, err = LoadRules(app, agentID, chatID); err != nil {
+		log.Printf("⚠️ [Authority] Failed to load tool_permissions, falling back to ask: %v", err)
+	}
+	if policy.Protected, err = LoadProtectedPaths(app); err != nil {
+		log.Printf("⚠️ [Authority] Failed to load protected_paths, using built-in list only: %v", err)
+	}
+	if policy.McpTools, err = LoadMcpTools(app); err != nil {
+		log.Printf("⚠️ [Authority] Failed to load MCP server tools, gateway tools only match plain rules: %v", err)
+	}
+	return policy
+}
 
-	// --- A. EVALUATE VERB (whitelist_actions) ---
-	actions, _ := app.FindRecordsByFilter(
-		"whitelist_actions",
-		"active = true && permission = {:perm}",
-		"-created", 100, 0,
-		map[string]any{"perm": input.Permission},
+// LoadRules fetches the active global rules plus the rules scoped to agentID
+// and chatID.
+func LoadRules(app core.App, agentID, chatID string) ([]Rule, error) {
+	records, err := app.FindRecordsByFilter(
+		"tool_permissions",
+		"active = true && (agent = '' || agent = {:agent}) && (chat = '' || chat = {:chat})",
+		"", 0, 0,
+		map[string]any{"agent": agentID, "chat": chatID},
 	)
+	if err != nil {
+		return nil, err
+	}
+	return rulesFromRecords(records), nil
+}
+
+// LoadAllRules fetches every active rule, whatever its agent or chat scope.
+// Resolve only applies the ones matching its input.
+func LoadAllRules(app core.App) ([]Rule, error) {
+	records, err := app.FindRecordsByFilter("tool_permissions", "active = true", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return rulesFromRecords(records), nil
+}
+
+func rulesFromRecords(records []*core.Record) []Rule {
+	rules := make([]Rule, 0, len(records))
+	for _, rec := range records {
+		rules = append(rules, Rule{
+			ID:          rec.Id,
+			AgentID:     rec.GetString("agent"),
+			ChatID:      rec.GetString("chat"),
+			Tool:        rec.GetString("tool"),
+			Pattern:     rec.GetString("pattern"),
+			Action:      rec.GetString("action"),
+			DraftTTL:    rec.GetInt("draft_ttl"),
+			Quorum:      rec.GetInt("quorum"),
+			QuorumGroup: rec.GetString("quorum_group"),
+			Conditions:  ConditionsFromRecord(rec),
+		})
+	}
+	return rules
+}
+
+// Resolve picks the effective action for input out of rules.
+//
+// Precedence follows the way OpenCode merges the rendered opencode.json:
+//  1. If the agent has any rule for the tool, its rules replace the global
+//     ones for that tool entirely; otherwise the global rules apply.
+//  2. Within that scope an exact tool name beats a wildcard tool, and a
+//     longer (more specific) pattern beats a shorter one.
+//  3. Every subject (the bash command, or each requested path) is resolved
+//     on its own and the most restrictive outcome wins.
+//  4. Rules remembered for the chat are layered on top: a matching chat rule
+//     overrides the outcome, except that it can never lift a deny.
+//  5. Writes touching a protected path are raised to at least ask (or deny),
+//     whatever the rules say.
+//
+// A tool of an approved MCP server is evaluated as mcp:<server>/<tool>.
+// A request no rule covers falls back to ask.
+func Resolve(policy Policy, input EvaluationInput) Decision {
+	if names := policy.McpTools[input.Permission]; len(names) > 0 {
+		return resolveMcp(policy, input, names)
+	}
+
+	rules := policy.Rules
+	protected := append(append([]ProtectedPath{}, BuiltinProtectedPaths...), policy.Protected...)
+	scoped, scope, candidates := scopeRules(rules, input.Permission, input.AgentID)
+	chatScoped, chatCandidates := chatRules(rules, input.Permission, input.ChatID)
+	candidates = append(chatCandidates, candidates...)
+
+	trace := Trace{
+		Permission: input.Permission,
+		Agent:      input.Agent,
+		AgentID:    input.AgentID,
+		Scope:      scope,
+		Candidates: candidates,
+	}
+
+	scopes := map[string][]Rule{input.Permission: scoped}
+	scopeNames := map[string]string{input.Permission: scope}
+	chatScopes := map[string][]Rule{input.Permission: chatScoped}
 
-	for _, rule := range actions {
-		kind := rule.GetString("kind")
-		value := rule.GetString("value")
-
-		if input.Permission == "bash" {
-			cmdStr, _ := input.Metadata["command"].(string)
-			// For bash commands, use pattern matching only
-			// Note: The "commands" collection was never created, so we removed that dead code path
-			if kind == "pattern" && value != "" {
-				// Check if the command matches a glob pattern
-				if utils.MatchWildcard(cmdStr, value) {
-					isWhitelisted = true
-					break
-				}
+	check := func(r *Rule) (bool, string) {
+		return r.Conditions.check(input, policy.Usage[r.ID])
+	}
+
+	var decisive *SubjectTrace
+	for _, sub := range subjectsFor(input) {
+		if _, ok := scopes[sub.permission]; !ok {
+			scopes[sub.permission], scopeNames[sub.permission], _ = scopeRules(rules, sub.permission, input.AgentID)
+			chatScopes[sub.permission], _ = chatRules(rules, sub.permission, input.ChatID)
+		}
+		st := resolveSubject(scopes[sub.permission], chatScopes[sub.permission], scopeNames[sub.permission], sub, check)
+		applyProtection(&st, protected, sub)
+		trace.Subjects = append(trace.Subjects, st)
+		if decisive == nil || Severity(st.Action) > Severity(decisive.Action) {
+			decisive = &trace.Subjects[len(trace.Subjects)-1]
+		}
+	}
+
+	trace.Action = decisive.Action
+	trace.MatchedRuleID = decisive.RuleID
+	trace.MatchedTool = decisive.Tool
+	trace.MatchedPattern = decisive.Pattern
+	trace.Conditional = decisive.Conditional && trace.Action == ActionAllow
+	trace.Reason = scopeReason(scope, input) + "; " + decisive.Reason
+	if len(trace.Subjects) > 1 {
+		trace.Reason += fmt.Sprintf(" (most restrictive of %d subjects)", len(trace.Subjects))
+	}
+	if trace.Action == ActionAsk {
+		for _, st := range trace.Subjects {
+			if st.Action == ActionAsk && st.Quorum > trace.Quorum {
+				trace.Quorum, trace.QuorumGroup = st.Quorum, st.QuorumGroup
 			}
-		} else {
-			// For non-bash tools (read, write, etc), a pattern of "*" allows all.
-			if kind == "pattern" {
-				if value == "*" || value == "" {
-					isWhitelisted = true
-					break
-				}
+		}
+		if trace.Quorum > 1 {
+			trace.Reason += fmt.Sprintf("; needs %d approvers", trace.Quorum)
+		}
+	}
+
+	return Decision{
+		Permitted: trace.Action == ActionAllow,
+		Status:    StatusForAction(trace.Action),
+		Action:    trace.Action,
+		Trace:     trace,
+	}
+}
+
+// StatusForAction maps a rule action onto a permissions record status.
+func StatusForAction(action string) string {
+	switch action {
+	case ActionAllow:
+		return StatusAuthorized
+	case ActionDeny:
+		return StatusDenied
+	default:
+		return StatusDraft
+	}
+}
+
+// resolveSubject finds the winning rule for one subject. check reports
+// whether a rule's conditions hold.
+func resolveSubject(rules, chatScoped []Rule, scope string, sub subject, check func(*Rule) (bool, string)) SubjectTrace {
+	st := SubjectTrace{
+		Subject:    sub.value,
+		Kind:       sub.kind,
+		Permission: sub.permission,
+		Scope:      scope,
+		Action:     ActionAsk,
+		Reason:     "no rule matched, defaulting to ask",
+	}
+
+	if rule := bestMatch(rules, sub); rule != nil {
+		st.RuleID = rule.ID
+		st.Tool = rule.Tool
+		st.Pattern = rule.Pattern
+		st.Action = rule.Action
+		if Severity(st.Action) == Severity(ActionAsk) {
+			st.Action = ActionAsk
+		}
+		st.Reason = fmt.Sprintf("matched rule `%s: %s`", rule.Tool, rule.Pattern)
+		applyConditions(&st, rule, check)
+		if st.Action == ActionAsk && rule.Quorum > 1 {
+			st.Quorum, st.QuorumGroup = rule.Quorum, rule.QuorumGroup
+		}
+	}
+	if rule := bestMatch(chatScoped, sub); rule != nil {
+		switch {
+		case st.Action == ActionDeny:
+			st.Reason += fmt.Sprintf("; chat rule `%s: %s` cannot lift a deny", rule.Tool, rule.Pattern)
+		case st.Quorum > 1:
+			st.Reason += fmt.Sprintf("; chat rule `%s: %s` cannot lift a %d-approver quorum", rule.Tool, rule.Pattern, st.Quorum)
+		default:
+			st.Scope = ScopeChat
+			st.RuleID = rule.ID
+			st.Tool = rule.Tool
+			st.Pattern = rule.Pattern
+			st.Action = rule.Action
+			st.Reason = fmt.Sprintf("matched chat rule `%s: %s`", rule.Tool, rule.Pattern)
+			st.Conditional = false
+			applyConditions(&st, rule, check)
+		}
+	}
+	if sub.kind == SubjectRedirect {
+		st.Reason = "redirect target evaluated as " + sub.permission + ": " + st.Reason
+	}
+
+	if sub.floor != "" && Severity(st.Action) < Severity(sub.floor) {
+		st.Action = sub.floor
+		st.Reason += "; raised to " + sub.floor + ": " + sub.note
+	}
+	return st
+}
+
+// applyConditions falls back to ask when the matched rule's conditions do
+// not hold.
+func applyConditions(st *SubjectTrace, rule *Rule, check func(*Rule) (bool, string)) {
+	if rule.Conditions.IsZero() || st.Action == ActionAsk {
+		return
+	}
+	if ok, note := check(rule); !ok {
+		st.Action = ActionAsk
+		st.Reason += "; falls back to ask: " + note
+		return
+	}
+	st.Conditional = true
+}
+
+// applyProtection raises a subject's action when it touches a protected path.
+func applyProtection(st *SubjectTrace, protected []ProtectedPath, sub subject) {
+	hit := protectedHit(protected, sub)
+	if hit == nil {
+		return
+	}
+	st.Protected = hit.Pattern
+
+	floor := ActionAsk
+	if hit.Action == ActionDeny {
+		floor = ActionDeny
+	}
+	if Severity(st.Action) < Severity(floor) {
+		st.Action = floor
+		st.Reason += fmt.Sprintf("; raised to %s: protected path %s (%s)", floor, hit.Pattern, hit.Reason)
+	}
+}
+
+// scopeReason explains which rule scope was used.
+func scopeReason(scope string, input EvaluationInput) string {
+	agent := input.Agent
+	if agent == "" {
+		agent = input.AgentID
+	}
+	switch {
+	case scope == ScopeAgent:
+		return fmt.Sprintf("agent %s rules override global rules for %s", agent, input.Permission)
+	case agent != "":
+		return fmt.Sprintf("agent %s has no rules for %s, global rules apply", agent, input.Permission)
+	default:
+		return "global rules apply"
+	}
+}
+
+// scopeRules returns the rules that apply to tool, preferring the agent scope,
+// together with the scope used and every candidate considered.
+func scopeRules(rules []Rule, tool, agentID string) ([]Rule, string, []CandidateTrace) {
+	var agentRules, globalRules []Rule
+	for _, r := range rules {
+		if r.ChatID != "" || !r.matchTool(tool) {
+			continue
+		}
+		switch {
+		case r.AgentID == "":
+			globalRules = append(globalRules, r)
+		case agentID != "" && r.AgentID == agentID:
+			agentRules = append(agentRules, r)
+		}
+	}
+
+	scoped, scope := globalRules, ScopeGlobal
+	if len(agentRules) > 0 {
+		scoped, scope = agentRules, ScopeAgent
+	}
+
+	candidates := make([]CandidateTrace, 0, len(agentRules)+len(globalRules))
+	for _, group := range []struct {
+		scope string
+		rules []Rule
+	}{{ScopeAgent, agentRules}, {ScopeGlobal, globalRules}} {
+		for _, r := range group.rules {
+			candidates = append(candidates, CandidateTrace{
+				RuleID:  r.ID,
+				Tool:    r.Tool,
+				Pattern: r.Pattern,
+				Action:  r.Action,
+				Scope:   group.scope,
+				Applied: group.scope == scope,
+			})
+		}
+	}
+
+	return scoped, scope, candidates
+}
+
+// chatRules returns the rules remembered for chatID that apply to tool.
+func chatRules(rules []Rule, tool, chatID string) ([]Rule, []CandidateTrace) {
+	if chatID == "" {
+		return nil, nil
+	}
+	var scoped []Rule
+	var candidates []CandidateTrace
+	for _, r := range rules {
+		if r.ChatID != chatID || !r.matchTool(tool) {
+			continue
+		}
+		scoped = append(scoped, r)
+		candidates = append(candidates, CandidateTrace{
+			RuleID:  r.ID,
+			Tool:    r.Tool,
+			Pattern: r.Pattern,
+			Action:  r.Action,
+			Scope:   ScopeChat,
+			Applied: true,
+		})
+	}
+	return scoped, candidates
+}
+
+// bestMatch returns the most specific rule matching subject, or nil.
+func bestMatch(rules []Rule, sub subject) *Rule {
+	var best *Rule
+	for i := range rules {
+		r := &rules[i]
+		if !r.matchSubject(sub) {
+			continue
+		}
+		if best == nil || moreSpecific(r, best, sub.permission) {
+			best = r
+		}
+	}
+	return best
+}
+
+// moreSpecific reports whether a should take precedence over b.
+func moreSpecific(a, b *Rule, tool string) bool {
+	aExact, bExact := a.Tool == tool, b.Tool == tool
+	if aExact != bExact {
+		return aExact
+	}
+	if len(a.Tool) != len(b.Tool) {
+		return len(a.Tool) > len(b.Tool)
+	}
+	if len(a.Pattern) != len(b.Pattern) {
+		return len(a.Pattern) > len(b.Pattern)
+	}
+	// Same specificity: the more restrictive action wins so ties never widen access.
+	return Severity(a.Action) > Severity(b.Action)
+}
+
+// subjectsFor splits a request into the subjects that must each be authorized.
+//
+// Patterns of path tools (read, edit, ...) are normalized against the
+// workspace root with symlinks resolved before matching.
+// A bash command is parsed into its simple commands, and every redirection that
+// writes a file is evaluated as an edit of its target. Commands the parser
+// cannot model are matched as a whole but never resolve to anything weaker
+// than ask.
+func subjectsFor(input EvaluationInput) []subject {
+	if input.Permission == "bash" {
+		if cmd, _ := input.Metadata["command"].(string); strings.TrimSpace(cmd) != "" {
+			return bashSubjects(strings.TrimSpace(cmd))
+		}
+	}
+
+	kind := SubjectPattern
+	if IsPathTool(input.Permission) {
+		kind = SubjectPath
+	}
+
+	if len(input.Patterns) == 0 {
+		sub := subject{permission: input.Permission, kind: SubjectPattern}
+		if writeTools[input.Permission] {
+			// Without a path there is nothing to check protected paths against.
+			sub.floor, sub.note = ActionAsk, "no path given"
+		}
+		return []subject{sub}
+	}
+	subjects := make([]subject, 0, len(input.Patterns))
+	for _, p := range input.Patterns {
+		if kind == SubjectPath {
+			p = NormalizePath(WorkspaceRoot, p)
+		}
+		subjects = append(subjects, subject{permission: input.Permission, value: p, kind: kind})
+	}
+	return subjects
+}
+
+// discardTargets are redirect targets that never touch the filesystem.
+var discardTargets = map[string]bool{
+	"/dev/null":   true,
+	"/dev/stdout": true,
+	"/dev/stderr": true,
+}
+
+// changesDir are the builtins that change the working directory of the
+// commands after them.
+var changesDir = map[string]bool{
+	"cd":    true,
+	"pushd": true,
+	"popd":  true,
+}
+
+func bashSubjects(cmd string) []subject {
+	commands, err := ParseShell(cmd)
+	if err != nil {
+		subjects := []subject{{permission: "bash", value: cmd, kind: SubjectCommand, floor: ActionAsk, note: err.Error()}}
+		for _, loose := range unquoteLoosely(cmd) {
+			if loose != cmd {
+				subjects = append(subjects, subject{permission: "bash", value: loose, kind: SubjectCommand, floor: ActionAsk, note: err.Error()})
+			}
+		}
+		return subjects
+	}
+
+	var subjects []subject
+	// dirs tracks every directory the commands may run in. Subshells are
+	// flattened, so a cd adds its target rather than replacing the old one.
+	var dirs []string
+	lost := false
+	for _, c := range commands {
+		if len(c.Words) > 0 {
+			sub := subject{permission: "bash", value: c.String(), kind: SubjectCommand, args: c.Words, dirs: dirs}
+			if lost {
+				sub.floor, sub.note = ActionAsk, "runs after an untracked change of directory"
+			}
+			subjects = append(subjects, sub)
+		}
+		for _, r := range c.Redirects {
+			if !r.WritesFile() || discardTargets[r.Target] {
+				continue
+			}
+			sub := subject{permission: "edit", value: NormalizePath(WorkspaceRoot, r.Target), kind: SubjectRedirect, target: r.Target, dirs: dirs}
+			switch {
+			case strings.ContainsAny(r.Target, "$`") || strings.HasPrefix(r.Target, "~"):
+				// The real target is only known once the shell expands it.
+				sub.floor, sub.note = ActionAsk, "redirect target depends on shell expansion"
+			case (lost || len(dirs) > 0) && !strings.HasPrefix(r.Target, "/"):
+				sub.floor, sub.note = ActionAsk, "redirect target depends on the working directory"
+			}
+			subjects = append(subjects, sub)
+		}
+
+		if len(c.Words) > 0 && changesDir[c.Words[0]] {
+			target := ""
+			if len(c.Words) > 1 {
+				target = c.Words[len(c.Words)-1]
 			}
+			if c.Words[0] == "popd" || target == "" || target == "-" || strings.HasPrefix(target, "-") ||
+				strings.ContainsAny(target, "$`") || strings.HasPrefix(target, "~") {
+				lost = true
+				continue
+			}
+			if len(dirs) == 0 {
+				dirs = []string{WorkspaceRoot}
+			}
+			next := append([]string(nil), dirs...)
+			for _, d := range dirs {
+				next = append(next, NormalizePath(d, target))
+			}
+			dirs = next
 		}
 	}
+	if len(subjects) == 0 {
+		return []subject{{permission: "bash", value: cmd, kind: SubjectCommand, floor: ActionAsk, note: "no command found"}}
+	}
+	return subjects
+}
 
-	status := "draft"
-	if isWhitelisted {
-		status = "authorized"
+// looseUnquoter strips quoting and splits brace lists into words.
+var looseUnquoter = strings.NewReplacer("$'", "", `$"`, "", "'", "", `"`, "", `\`, "", "{", " ", "}", " ", ",", " ")
+
+// unquoteLoosely approximates the commands bash would run for input the
+// parser cannot model, so that `$'rm' -rf /` or `ls; {rm,-rf,/}` still
+// matches a deny rule for `rm *`. Their subjects are floored to ask, so they
+// can only tighten the decision.
+func unquoteLoosely(cmd string) []string {
+	var commands []string
+	for _, part := range strings.FieldsFunc(looseUnquoter.Replace(cmd), func(r rune) bool {
+		return strings.ContainsRune(";&|()\n`", r)
+	}) {
+		if words := strings.Fields(part); len(words) > 0 {
+			commands = append(commands, strings.Join(words, " "))
+		}
 	}
+	return commands
+}
 
-	return isWhitelisted, status
+// Severity orders actions from most to least permissive: allow, ask, deny.
+func Severity(action string) int {
+	switch action {
+	case ActionAllow:
+		return 0
+	case ActionDeny:
+		return 2
+	default:
+		return 1
+	}
 }
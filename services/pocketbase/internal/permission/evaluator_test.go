@@ -2,12 +2,15 @@ package permission_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pocketbase/pocketbase"
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
 )
 
-// This test requires a running PocketBase or a mock. 
+// This test requires a running PocketBase or a mock.
 // Since we are in a live environment, we'll try to use a temporary DB.
 func TestEvaluate(t *testing.T) {
 	// Setup a temporary pocketbase app for testing
@@ -24,7 +27,386 @@ func TestEvaluate(t *testing.T) {
 	// We need to bootstrap enough of the app to run queries
 	// In a real unit test, we'd mock the core.App interface.
 	// But let's verify the logic flow manually or via integration test.
-	
+
 	t.Log("Testing permission verification logic...")
 	// ... actual test logic would go here if we were doing deep unit tests ...
 }
+
+func TestResolve(t *testing.T) {
+	rules := []permission.Rule{
+		{ID: "g1", Tool: "*", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g2", Tool: "bash", Pattern: "ls *", Action: permission.ActionAllow},
+		{ID: "g3", Tool: "bash", Pattern: "rm -rf *", Action: permission.ActionDeny},
+		{ID: "g4", Tool: "read", Pattern: "*", Action: permission.ActionAllow},
+		{ID: "a1", AgentID: "poco", Tool: "bash", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "a2", AgentID: "poco", Tool: "bash", Pattern: "git *", Action: permission.ActionAllow},
+	}
+
+	bash := func(cmd, agent string) permission.EvaluationInput {
+		return permission.EvaluationInput{
+			Permission: "bash",
+			Metadata:   map[string]any{"command": cmd},
+			AgentID:    agent,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		input permission.EvaluationInput
+		want  string
+	}{
+		{"global allow", bash("ls -la", ""), permission.ActionAllow},
+		{"global allow bare command", bash("ls", ""), permission.ActionAllow},
+		{"global deny", bash("rm -rf /", ""), permission.ActionDeny},
+		{"global fallback", bash("cat README.md", ""), permission.ActionAsk},
+		{"agent overrides global", bash("ls -la", "poco"), permission.ActionAsk},
+		{"agent specific allow", bash("git status", "poco"), permission.ActionAllow},
+		{"unknown agent uses global", bash("ls", "other"), permission.ActionAllow},
+		{"exact tool beats wildcard", permission.EvaluationInput{Permission: "read", Patterns: []string{"/workspace/a.go"}}, permission.ActionAllow},
+		{"wildcard tool", permission.EvaluationInput{Permission: "webfetch"}, permission.ActionAsk},
+		{"no rules", bash("ls", ""), permission.ActionAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := rules
+			if tt.name == "no rules" {
+				in = nil
+			}
+			if got := permission.Resolve(permission.Policy{Rules: in}, tt.input).Action; got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTrace(t *testing.T) {
+	rules := []permission.Rule{
+		{ID: "g1", Tool: "bash", Pattern: "git *", Action: permission.ActionAllow},
+		{ID: "a1", AgentID: "agent1", Tool: "bash", Pattern: "git *", Action: permission.ActionAllow},
+		{ID: "a2", AgentID: "agent1", Tool: "bash", Pattern: "git push *", Action: permission.ActionAsk},
+	}
+
+	d := permission.Resolve(permission.Policy{Rules: rules}, permission.EvaluationInput{
+		Permission: "bash",
+		Metadata:   map[string]any{"command": "git push origin main"},
+		AgentID:    "agent1",
+		Agent:      "poco",
+	})
+
+	if d.Status != permission.StatusDraft || d.Permitted {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	if d.Trace.MatchedRuleID != "a2" || d.Trace.MatchedPattern != "git push *" {
+		t.Errorf("matched %q (%q), want a2 (git push *)", d.Trace.MatchedRuleID, d.Trace.MatchedPattern)
+	}
+	if d.Trace.Scope != permission.ScopeAgent {
+		t.Errorf("scope = %q, want %q", d.Trace.Scope, permission.ScopeAgent)
+	}
+	if len(d.Trace.Candidates) != 3 {
+		t.Errorf("got %d candidates, want 3", len(d.Trace.Candidates))
+	}
+	for _, c := range d.Trace.Candidates {
+		if c.Applied != (c.Scope == permission.ScopeAgent) {
+			t.Errorf("candidate %s applied = %v", c.RuleID, c.Applied)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/workspace/src/**", "/workspace/src", true},
+		{"/workspace/src/**", "/workspace/src/a/b/c.go", true},
+		{"/workspace/src/*", "/workspace/src/a.go", true},
+		{"/workspace/src/*", "/workspace/src/a/b.go", false},
+		{"/workspace/**/*.go", "/workspace/a/b/c.go", true},
+		{"/workspace/**/*.go", "/workspace/c.go", true},
+		{"/workspace/**/*.go", "/workspace/c.ts", false},
+		{"/workspace/.opencode/**", "/workspace/src/.opencode", false},
+		{"/workspace/file?.[ch]", "/workspace/file1.c", true},
+		{"*", "/etc/passwd", true},
+		{"/workspace/{src,test}/**/*.{go,ts}", "/workspace/test/a/b.ts", true},
+		{"/workspace/{src,test}/**/*.{go,ts}", "/workspace/docs/b.ts", false},
+		{"!/workspace/**", "/etc/passwd", true},
+	}
+	for _, tt := range tests {
+		if got := permission.MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePathResolvesSymlinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, ".git"), filepath.Join(root, "src")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"a/../b.txt":       filepath.Join(root, "b.txt"),
+		"src/config":       filepath.Join(root, ".git", "config"),
+		root + "/src/x/y":  filepath.Join(root, ".git", "x", "y"),
+		"/nonexistent/dir": "/nonexistent/dir",
+	}
+	for in, want := range tests {
+		if got := permission.NormalizePath(root, in); got != want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	rules := []permission.Rule{
+		{ID: "g1", Tool: "*", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g2", Tool: "edit", Pattern: "/workspace/src/**", Action: permission.ActionAllow},
+		{ID: "g3", Tool: "edit", Pattern: "/workspace/**/.git/**", Action: permission.ActionAsk},
+		{ID: "g4", Tool: "edit", Pattern: ".opencode/**", Action: permission.ActionDeny},
+	}
+
+	tests := []struct {
+		patterns []string
+		want     string
+	}{
+		{[]string{"/workspace/src/main.go"}, permission.ActionAllow},
+		{[]string{"src/pkg/util.go"}, permission.ActionAllow},
+		{[]string{"/workspace/src/../.opencode/opencode.json"}, permission.ActionDeny},
+		{[]string{"/workspace/src/.git/config"}, permission.ActionAsk},
+		{[]string{"/workspace/src/a.go", "/workspace/README.md"}, permission.ActionAsk},
+	}
+	for _, tt := range tests {
+		d := permission.Resolve(permission.Policy{Rules: rules}, permission.EvaluationInput{Permission: "edit", Patterns: tt.patterns})
+		if d.Action != tt.want {
+			t.Errorf("Resolve(edit %v) = %q, want %q (%s)", tt.patterns, d.Action, tt.want, d.Trace.Reason)
+		}
+	}
+}
+
+func TestResolveProtectedPaths(t *testing.T) {
+	policy := permission.Policy{
+		Rules: []permission.Rule{
+			{ID: "g1", Tool: "edit", Pattern: "*", Action: permission.ActionAllow},
+			{ID: "g2", Tool: "bash", Pattern: "*", Action: permission.ActionAllow},
+		},
+		Protected: []permission.ProtectedPath{
+			{Pattern: "secrets/**", Action: permission.ActionDeny, Reason: "test"},
+		},
+	}
+
+	edit := func(path string) permission.EvaluationInput {
+		return permission.EvaluationInput{Permission: "edit", Patterns: []string{path}}
+	}
+	bash := func(cmd string) permission.EvaluationInput {
+		return permission.EvaluationInput{Permission: "bash", Metadata: map[string]any{"command": cmd}}
+	}
+
+	tests := []struct {
+		name  string
+		input permission.EvaluationInput
+		want  string
+	}{
+		{"unprotected edit", edit("/workspace/src/a.go"), permission.ActionAllow},
+		{"builtin deny", edit("/workspace/.opencode/opencode.json"), permission.ActionDeny},
+		{"builtin ask", edit(".opencode/proposals/new.md"), permission.ActionAsk},
+		{"admin deny", edit("/workspace/secrets/token"), permission.ActionDeny},
+		{"redirect", bash("echo x > /workspace/.opencode/llm.env"), permission.ActionDeny},
+		{"tee argument", bash("echo x | tee .opencode/proposals/p.md"), permission.ActionAsk},
+		{"recursive parent", bash("rm -rf /workspace/.opencode"), permission.ActionDeny},
+		{"listing parent", bash("ls /workspace"), permission.ActionAllow},
+		{"glob argument", bash("cp x.md .opencode/proposals/*"), permission.ActionAsk},
+		{"read", bash("cat .opencode/opencode.json"), permission.ActionAllow},
+		{"diff", bash("git diff .opencode/opencode.json"), permission.ActionAllow},
+		{"copy source", bash("cp .opencode/opencode.json /tmp/opencode.json"), permission.ActionAllow},
+		{"in-place edit", bash("sed -i s/a/b/ .opencode/opencode.json"), permission.ActionDeny},
+		{"wrapped writer", bash("sudo rm .opencode/llm.env"), permission.ActionDeny},
+		{"redirect after cd", bash("cd .opencode && echo x > opencode.json"), permission.ActionDeny},
+		{"writer after cd", bash("cd .opencode && rm opencode.json"), permission.ActionDeny},
+		{"cd elsewhere", bash("cd src && ls"), permission.ActionAllow},
+		{"untracked cd", bash("cd $DIR && touch x"), permission.ActionAsk},
+		{"edit without path", permission.EvaluationInput{Permission: "edit"}, permission.ActionAsk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := permission.Resolve(policy, tt.input)
+			if d.Action != tt.want {
+				t.Errorf("Resolve() = %q, want %q (%s)", d.Action, tt.want, d.Trace.Reason)
+			}
+		})
+	}
+}
+
+func TestDefaultDraftTTL(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", 0},
+		{"45s", 45 * time.Second},
+		{"2h", 2 * time.Hour},
+		{"soon", 0},
+		{"-5m", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("PERMISSION_DRAFT_TTL", tt.env)
+		if got := permission.DefaultDraftTTL(); got != tt.want {
+			t.Errorf("DefaultDraftTTL() with %q = %s, want %s", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestResolveQuorum(t *testing.T) {
+	rules := []permission.Rule{
+		{ID: "g1", Tool: "bash", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g2", Tool: "bash", Pattern: "rm -rf *", Action: permission.ActionAsk, Quorum: 2, QuorumGroup: "ops"},
+		{ID: "c1", ChatID: "chat1", Tool: "bash", Pattern: "rm -rf *", Action: permission.ActionAllow},
+		{ID: "c2", ChatID: "chat1", Tool: "bash", Pattern: "make *", Action: permission.ActionAllow},
+	}
+	resolve := func(cmd string) permission.Trace {
+		return permission.Resolve(permission.Policy{Rules: rules}, permission.EvaluationInput{
+			Permission: "bash",
+			Metadata:   map[string]any{"command": cmd},
+			ChatID:     "chat1",
+		}).Trace
+	}
+
+	if tr := resolve("rm -rf build"); tr.Action != permission.ActionAsk || tr.Quorum != 2 || tr.QuorumGroup != "ops" {
+		t.Errorf("chat rule lifted a quorum: %s quorum=%d (%s)", tr.Action, tr.Quorum, tr.Reason)
+	}
+	if tr := resolve("make && rm -rf dist"); tr.Quorum != 2 {
+		t.Errorf("compound command quorum = %d, want 2 (%s)", tr.Quorum, tr.Reason)
+	}
+	if tr := resolve("make test"); tr.Action != permission.ActionAllow || tr.Quorum != 0 {
+		t.Errorf("unrelated command = %s quorum=%d", tr.Action, tr.Quorum)
+	}
+
+	tests := []struct {
+		role   string
+		groups []string
+		group  string
+		want   bool
+	}{
+		{"user", nil, "", true},
+		{"agent", []string{"ops"}, "", false},
+		{"user", []string{"ops"}, "ops", true},
+		{"admin", nil, "ops", false},
+		{"admin", nil, permission.QuorumGroupAdmin, true},
+		{"user", nil, permission.QuorumGroupAdmin, false},
+	}
+	for _, tt := range tests {
+		if got := permission.CanApproveQuorum(tt.role, tt.groups, tt.group); got != tt.want {
+			t.Errorf("CanApproveQuorum(%s, %v, %q) = %v, want %v", tt.role, tt.groups, tt.group, got, tt.want)
+		}
+	}
+}
+
+func TestResolveConditions(t *testing.T) {
+	weekdays := permission.Conditions{Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}, Hours: "09:00-18:00", Timezone: "UTC"}
+	policy := permission.Policy{
+		Rules: []permission.Rule{
+			{ID: "g1", Tool: "bash", Pattern: "*", Action: permission.ActionAsk},
+			{ID: "g2", Tool: "bash", Pattern: "git *", Action: permission.ActionAllow, Conditions: weekdays},
+			{ID: "g3", Tool: "bash", Pattern: "npm *", Action: permission.ActionAllow, Conditions: permission.Conditions{CronJobID: "job1", MaxPerHour: 3}},
+			{ID: "g4", Tool: "bash", Pattern: "deploy *", Action: permission.ActionDeny, Conditions: permission.Conditions{Hours: "22:00-06:00", Timezone: "UTC"}},
+		},
+		Usage: map[string]int{"g3": 2},
+	}
+
+	monday := time.Date(2026, 10, 12, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		command string
+		now     time.Time
+		cronJob string
+		usage   int
+		want    string
+	}{
+		{"inside window", "git push", monday, "", 0, permission.ActionAllow},
+		{"after hours", "git push", monday.Add(8 * time.Hour), "", 0, permission.ActionAsk},
+		{"weekend", "git push", monday.AddDate(0, 0, 5), "", 0, permission.ActionAsk},
+		{"cron job with budget", "npm test", monday, "job1", 2, permission.ActionAllow},
+		{"budget used up", "npm test", monday, "job1", 3, permission.ActionAsk},
+		{"other cron job", "npm test", monday, "job2", 0, permission.ActionAsk},
+		{"deny window past midnight", "deploy prod", monday.Add(14 * time.Hour), "", 0, permission.ActionDeny},
+		{"deny outside its window", "deploy prod", monday, "", 0, permission.ActionAsk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy.Usage["g3"] = tt.usage
+			d := permission.Resolve(policy, permission.EvaluationInput{
+				Permission: "bash",
+				Metadata:   map[string]any{"command": tt.command},
+				CronJobID:  tt.cronJob,
+				Now:        tt.now,
+			})
+			if d.Action != tt.want {
+				t.Errorf("Resolve() = %q, want %q (%s)", d.Action, tt.want, d.Trace.Reason)
+			}
+			if d.Trace.Conditional != (d.Action == permission.ActionAllow) {
+				t.Errorf("Conditional = %v for %q", d.Trace.Conditional, d.Action)
+			}
+		})
+	}
+
+	for _, bad := range []permission.Conditions{{Hours: "9-17"}, {Hours: "09:00-09:00"}, {Weekdays: []string{"someday"}}, {Timezone: "Mars/Base"}} {
+		if bad.Validate() == nil {
+			t.Errorf("Validate(%+v) accepted invalid conditions", bad)
+		}
+	}
+}
+
+func TestResolveMcpTools(t *testing.T) {
+	policy := permission.Policy{
+		Rules: []permission.Rule{
+			{ID: "g1", Tool: "*", Pattern: "*", Action: permission.ActionAsk},
+			{ID: "g2", Tool: "mcp:github/*", Pattern: "*", Action: permission.ActionDeny},
+			{ID: "g3", Tool: "mcp:github/{get,list,search}_*", Pattern: "*", Action: permission.ActionAllow},
+			{ID: "g4", Tool: "mcp:fetch/*", Pattern: "*", Action: permission.ActionAllow},
+		},
+		McpTools: map[string][]string{
+			permission.GatewayToolName("get_file"):     {"mcp:github/get_file"},
+			permission.GatewayToolName("create_issue"): {"mcp:github/create_issue"},
+			permission.GatewayToolName("fetch"):        {"mcp:fetch/fetch"},
+			permission.GatewayToolName("search_code"):  {"mcp:fetch/search_code", "mcp:github/search_code"},
+			permission.GatewayToolName("delete"):       {"mcp:fetch/delete", "mcp:github/delete"},
+		},
+	}
+
+	tests := []struct {
+		tool string
+		want string
+		as   string
+	}{
+		{"mcp_gateway_get_file", permission.ActionAllow, "mcp:github/get_file"},
+		{"mcp_gateway_create_issue", permission.ActionDeny, "mcp:github/create_issue"},
+		{"mcp_gateway_fetch", permission.ActionAllow, "mcp:fetch/fetch"},
+		{"mcp_gateway_search_code", permission.ActionAllow, "mcp:fetch/search_code"},
+		{"mcp_gateway_delete", permission.ActionDeny, "mcp:github/delete"},
+		{"mcp_gateway_unknown", permission.ActionAsk, "mcp_gateway_unknown"},
+		{"mcp:github/delete_repo", permission.ActionDeny, "mcp:github/delete_repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			d := permission.Resolve(policy, permission.EvaluationInput{Permission: tt.tool, Patterns: []string{"*"}})
+			if d.Action != tt.want || d.Trace.Permission != tt.as {
+				t.Errorf("Resolve(%s) = %q as %q, want %q as %q (%s)", tt.tool, d.Action, d.Trace.Permission, tt.want, tt.as, d.Trace.Reason)
+			}
+		})
+	}
+
+	if got := permission.GatewayToolName("mcp-config-set"); got != "mcp_gateway_mcp_config_set" {
+		t.Errorf("GatewayToolName() = %q", got)
+	}
+	for tool, ok := range map[string]bool{"mcp:github/create_issue": true, "mcp:*": true, "mcp:github": false, "mcp:/x": false} {
+		if err := permission.ValidatePatterns(tool, "*"); (err == nil) != ok {
+			t.Errorf("ValidatePatterns(%q) = %v", tool, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Draft Expiry. Resolves how long a draft permission may wait for a human decision.
+package permission
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultDraftTTL returns the draft TTL from PERMISSION_DRAFT_TTL (a Go
+// duration such as "45m"). It is 0, meaning drafts never expire, when the
+// variable is unset or invalid.
+func DefaultDraftTTL() time.Duration {
+	raw := os.Getenv("PERMISSION_DRAFT_TTL")
+	if raw == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		log.Printf("⚠️ [Authority] Invalid PERMISSION_DRAFT_TTL %q, drafts will not expire", raw)
+		return 0
+	}
+	return ttl
+}
+
+// DraftTTL returns how long a draft for tool may stay open, or 0 if it may
+// wait indefinitely. The most specific rule for the tool (in the same
+// agent-over-global scope the evaluator uses) that sets draft_ttl wins;
+// otherwise DefaultDraftTTL applies.
+func DraftTTL(app core.App, tool, agentID string) time.Duration {
+	rules, err := LoadRules(app, agentID, "")
+	if err != nil {
+		return DefaultDraftTTL()
+	}
+	policy := Policy{}
+	if policy.McpTools, err = LoadMcpTools(app); err != nil {
+		return draftTTLFor(rules, tool, agentID)
+	}
+
+	// A gateway tool several servers expose gets the shortest of their TTLs.
+	ttl := time.Duration(0)
+	for _, name := range policy.toolNames(tool) {
+		if t := draftTTLFor(rules, name, agentID); t > 0 && (ttl == 0 || t < ttl) {
+			ttl = t
+		}
+	}
+	return ttl
+}
+
+func draftTTLFor(rules []Rule, tool, agentID string) time.Duration {
+	scoped, _, _ := scopeRules(rules, tool, agentID)
+
+	var best *Rule
+	for i := range scoped {
+		r := &scoped[i]
+		if r.DraftTTL <= 0 {
+			continue
+		}
+		if best == nil || moreSpecific(r, best, tool) {
+			best = r
+		}
+	}
+	if best == nil {
+		return DefaultDraftTTL()
+	}
+	return time.Duration(best.DraftTTL) * time.Second
+}
@@ -0,0 +1,97 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Rule Globs. Compiles rule tools and patterns once and caches them by rule ID.
+package permission
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/glob"
+)
+
+// ruleGlobs holds the compiled globs of every rule evaluated so far, keyed by
+// rule ID and the role the pattern plays. Entries recompile by themselves when
+// a rule's pattern changes; ForgetRule drops them when the record does.
+var ruleGlobs = glob.NewCache(0)
+
+// ForgetRule drops the compiled globs of rule id.
+func ForgetRule(id string) {
+	ruleGlobs.Invalidate(id+"|tool", id+"|command", id+"|path")
+}
+
+// ValidatePatterns reports whether a rule's tool and pattern compile.
+func ValidatePatterns(tool, pattern string) error {
+	if err := glob.Validate(tool); err != nil {
+		return fmt.Errorf("invalid tool: %w", err)
+	}
+	if IsMcpRule(tool) {
+		if err := validateMcpRule(tool); err != nil {
+			return err
+		}
+	}
+	if err := glob.Validate(commandPattern(pattern)); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	if IsPathTool(tool) {
+		if err := glob.Validate(normalizePattern(WorkspaceRoot, pattern), '/'); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// match matches s against pattern, compiled under the rule's key for role.
+// Rules without an ID (proposed or test rules) share the pattern-keyed cache.
+func (r *Rule) match(role, pattern, s string, separators ...rune) bool {
+	if r.ID == "" {
+		return glob.Match(pattern, s, separators...)
+	}
+	return ruleGlobs.Match(r.ID+"|"+role, pattern, s, separators...)
+}
+
+// matchTool reports whether the rule applies to tool.
+func (r *Rule) matchTool(tool string) bool {
+	return r.match("tool", r.Tool, tool)
+}
+
+// matchSubject matches the rule pattern against a subject. Paths use
+// segment-aware globs relative to the workspace; everything else is matched
+// whole, with OpenCode's wildcard conventions.
+func (r *Rule) matchSubject(sub subject) bool {
+	switch sub.kind {
+	case SubjectPath, SubjectRedirect:
+		if r.Pattern == "*" {
+			return true
+		}
+		return r.match("path", normalizePattern(WorkspaceRoot, r.Pattern), sub.value, '/')
+	default:
+		return r.match("command", commandPattern(r.Pattern), sub.value)
+	}
+}
+
+// commandPattern applies OpenCode's one wildcard convention that plain globs
+// lack: a pattern ending in " *" also matches the bare command, so `git *`
+// covers `git` as well as `git status`.
+func commandPattern(pattern string) string {
+	if strings.HasSuffix(pattern, " *") && !strings.HasSuffix(pattern, `\ *`) {
+		return strings.TrimSuffix(pattern, " *") + "{, *}"
+	}
+	return pattern
+}
@@ -0,0 +1,118 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Ledger Chain. Hash-chains decided permissions so edits and deletions are detectable.
+package permission
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// LedgerEntry is the canonical content of a decided permission covered by
+// the hash chain. JSON fields are decoded values, so that re-encoding them
+// gives the same bytes however they were stored.
+type LedgerEntry struct {
+	Seq             int    `json:"seq"`
+	ID              string `json:"id"`
+	Created         string `json:"created"`
+	OpencodeID      string `json:"opencode_id"`
+	SessionID       string `json:"session_id"`
+	Permission      string `json:"permission"`
+	Patterns        any    `json:"patterns"`
+	Metadata        any    `json:"metadata"`
+	Message         string `json:"message"`
+	MessageID       string `json:"message_id"`
+	CallID          string `json:"call_id"`
+	Challenge       string `json:"challenge"`
+	RequestHash     string `json:"request_hash"`
+	Status          string `json:"status"`
+	ApprovedBy      string `json:"approved_by"`
+	ApprovedAt      string `json:"approved_at"`
+	Signature       string `json:"signature"`
+	SignedDevice    string `json:"signed_device"`
+	DecisionReason  string `json:"decision_reason"`
+	ApprovalScope   string `json:"approval_scope"`
+	ApprovalPattern string `json:"approval_pattern"`
+	Quorum          int    `json:"quorum"`
+	Approvals       int    `json:"approvals"`
+	RiskScore       int    `json:"risk_score"`
+	Trace           any    `json:"trace"`
+}
+
+// ChainHash returns the hash of an entry linked to the previous entry's hash.
+func ChainHash(prevHash string, entry LedgerEntry) string {
+	content, _ := json.Marshal(entry)
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), content...))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainLink is a stored ledger record together with its recomputed hash.
+type ChainLink struct {
+	ID        string `json:"id"`
+	Seq       int    `json:"seq"`
+	PrevHash  string `json:"prev_hash"`
+	EntryHash string `json:"entry_hash"`
+	// Computed is ChainHash over the record's current content.
+	Computed string `json:"-"`
+}
+
+// ChainBreak is the first link that does not verify.
+type ChainBreak struct {
+	Seq    int    `json:"seq"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// VerifyChain walks links in sequence order and reports the first one that
+// is missing, out of order, edited or not linked to its predecessor.
+func VerifyChain(links []ChainLink) *ChainBreak {
+	var v ChainVerifier
+	return v.Verify(links)
+}
+
+// ChainVerifier checks a chain one batch of links at a time, so that a long
+// chain never has to be held in memory at once.
+type ChainVerifier struct {
+	// Entries is the number of links verified so far.
+	Entries int
+	// Head is the entry hash of the last verified link.
+	Head string
+}
+
+// Verify checks the next links of the chain in sequence order and reports
+// the first one that is missing, out of order, edited or not linked to its
+// predecessor.
+func (v *ChainVerifier) Verify(links []ChainLink) *ChainBreak {
+	for _, link := range links {
+		want := v.Entries + 1
+		switch {
+		case link.Seq != want:
+			return &ChainBreak{Seq: want, ID: link.ID, Reason: fmt.Sprintf("expected entry %d, found %d: an entry was removed or renumbered", want, link.Seq)}
+		case link.PrevHash != v.Head:
+			return &ChainBreak{Seq: link.Seq, ID: link.ID, Reason: "prev_hash does not match the previous entry"}
+		case link.EntryHash != link.Computed:
+			return &ChainBreak{Seq: link.Seq, ID: link.ID, Reason: "content does not match entry_hash: the record was modified"}
+		}
+		v.Entries++
+		v.Head = link.EntryHash
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package permission_test
+
+import (
+	"testing"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+func TestVerifyChain(t *testing.T) {
+	entries := []permission.LedgerEntry{
+		{Seq: 1, ID: "a", Permission: "bash", Status: permission.StatusAuthorized, Metadata: map[string]any{"command": "ls"}},
+		{Seq: 2, ID: "b", Permission: "bash", Status: permission.StatusDenied, Metadata: map[string]any{"command": "rm -rf /"}},
+		{Seq: 3, ID: "c", Permission: "edit", Status: permission.StatusAuthorized, Patterns: []any{"/workspace/a.go"}},
+	}
+	chain := func() []permission.ChainLink {
+		var links []permission.ChainLink
+		prev := ""
+		for _, e := range entries {
+			hash := permission.ChainHash(prev, e)
+			links = append(links, permission.ChainLink{ID: e.ID, Seq: e.Seq, PrevHash: prev, EntryHash: hash, Computed: hash})
+			prev = hash
+		}
+		return links
+	}
+
+	if broken := permission.VerifyChain(chain()); broken != nil {
+		t.Fatalf("intact chain reported broken: %+v", broken)
+	}
+
+	edited := chain()
+	entries[1].Status = permission.StatusAuthorized
+	edited[1].Computed = permission.ChainHash(edited[1].PrevHash, entries[1])
+	entries[1].Status = permission.StatusDenied
+	if broken := permission.VerifyChain(edited); broken == nil || broken.ID != "b" {
+		t.Errorf("edited entry: got %+v, want break at b", broken)
+	}
+
+	removed := chain()
+	removed = append(removed[:1], removed[2:]...)
+	if broken := permission.VerifyChain(removed); broken == nil || broken.Seq != 2 {
+		t.Errorf("removed entry: got %+v, want break at seq 2", broken)
+	}
+
+	var batched permission.ChainVerifier
+	links := chain()
+	for _, batch := range [][]permission.ChainLink{links[:2], links[2:]} {
+		if broken := batched.Verify(batch); broken != nil {
+			t.Fatalf("intact chain reported broken across batches: %+v", broken)
+		}
+	}
+	if batched.Entries != 3 || batched.Head != links[2].EntryHash {
+		t.Errorf("batched verifier at %d entries, head %q, want 3 and the last hash", batched.Entries, batched.Head)
+	}
+	var split permission.ChainVerifier
+	_ = split.Verify(removed[:1])
+	if broken := split.Verify(removed[1:]); broken == nil || broken.Seq != 2 {
+		t.Errorf("removed entry across batches: got %+v, want break at seq 2", broken)
+	}
+
+	relinked := chain()
+	relinked[2].PrevHash = relinked[0].EntryHash
+	if broken := permission.VerifyChain(relinked); broken == nil || broken.ID != "c" {
+		t.Errorf("relinked entry: got %+v, want break at c", broken)
+	}
+}
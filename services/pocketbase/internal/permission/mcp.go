@@ -0,0 +1,173 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: MCP Tools. Maps MCP gateway tool calls onto per-server mcp:<server>/<tool> rule names.
+package permission
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// McpRulePrefix starts the tool name of a rule that gates a single MCP tool:
+// "mcp:github/create_issue", or with globs "mcp:github/*".
+const McpRulePrefix = "mcp:"
+
+// McpGatewayPrefix is the prefix OpenCode gives the tools of the
+// "mcp-gateway" MCP server, which proxies every approved server.
+const McpGatewayPrefix = "mcp_gateway_"
+
+// McpTool is a tool recorded on an mcp_servers record.
+type McpTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// ReadOnly is the server's own hint that the tool has no side effects.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// IsMcpRule reports whether tool names MCP tools rather than an OpenCode tool.
+func IsMcpRule(tool string) bool {
+	return strings.HasPrefix(tool, McpRulePrefix)
+}
+
+// McpRuleName returns the rule tool name of tool on server.
+func McpRuleName(server, tool string) string {
+	return McpRulePrefix + server + "/" + tool
+}
+
+// validateMcpRule checks the mcp:<server>/<tool> shape of an MCP rule's tool.
+func validateMcpRule(tool string) error {
+	if tool == McpRulePrefix+"*" {
+		return nil
+	}
+	server, name, ok := strings.Cut(strings.TrimPrefix(tool, McpRulePrefix), "/")
+	if !ok || server == "" || name == "" {
+		return fmt.Errorf("invalid tool %q: MCP rules take the form mcp:<server>/<tool>", tool)
+	}
+	return nil
+}
+
+var unsafeToolChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// GatewayToolName returns the name OpenCode gives an MCP tool served by the
+// gateway.
+func GatewayToolName(tool string) string {
+	return McpGatewayPrefix + unsafeToolChars.ReplaceAllString(tool, "_")
+}
+
+// McpToolsFromRecord reads the tools field of an mcp_servers record. Plain
+// strings are accepted as tool names; entries without a name are dropped and
+// only the first entry of a name is kept.
+func McpToolsFromRecord(rec *core.Record) []McpTool {
+	var raw []any
+	if err := rec.UnmarshalJSONField("tools", &raw); err != nil {
+		return nil
+	}
+	return NormalizeMcpTools(raw)
+}
+
+// NormalizeMcpTools converts a decoded JSON tool list into McpTools sorted by
+// name.
+func NormalizeMcpTools(raw []any) []McpTool {
+	byName := map[string]McpTool{}
+	for _, item := range raw {
+		var t McpTool
+		switch v := item.(type) {
+		case string:
+			t.Name = v
+		case map[string]any:
+			t.Name, _ = v["name"].(string)
+			t.Description, _ = v["description"].(string)
+			t.ReadOnly, _ = v["read_only"].(bool)
+		}
+		if t.Name = strings.TrimSpace(t.Name); t.Name == "" {
+			continue
+		}
+		if _, dup := byName[t.Name]; !dup {
+			byName[t.Name] = t
+		}
+	}
+
+	tools := make([]McpTool, 0, len(byName))
+	for _, t := range byName {
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// LoadMcpTools maps the OpenCode name of every tool of the approved MCP
+// servers onto its rule names. A tool name exposed by several servers maps
+// onto each of them.
+func LoadMcpTools(app core.App) (map[string][]string, error) {
+	records, err := app.FindRecordsByFilter("mcp_servers", "status = 'approved'", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string][]string{}
+	seen := map[string]bool{}
+	for _, rec := range records {
+		server := rec.GetString("name")
+		for _, t := range McpToolsFromRecord(rec) {
+			name := McpRuleName(server, t.Name)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			key := GatewayToolName(t.Name)
+			index[key] = append(index[key], name)
+		}
+	}
+	for _, names := range index {
+		sort.Strings(names)
+	}
+	return index, nil
+}
+
+// toolNames returns the names tool is evaluated under: its MCP rule names if
+// it is a tool of an approved MCP server, itself otherwise.
+func (p Policy) toolNames(tool string) []string {
+	if names := p.McpTools[tool]; len(names) > 0 {
+		return names
+	}
+	return []string{tool}
+}
+
+// resolveMcp evaluates a gateway tool call under each of its MCP rule names.
+// When several servers expose the tool, the most restrictive outcome wins.
+func resolveMcp(policy Policy, input EvaluationInput, names []string) Decision {
+	var decision Decision
+	for i, name := range names {
+		in := input
+		in.Permission = name
+		d := Resolve(policy, in)
+		if i == 0 || Severity(d.Action) > Severity(decision.Action) {
+			decision = d
+		}
+	}
+	decision.Trace.RequestedAs = input.Permission
+	if len(names) > 1 {
+		decision.Trace.Reason += fmt.Sprintf(" (most restrictive of %d servers exposing %s)", len(names), input.Permission)
+	}
+	return decision
+}
@@ -0,0 +1,102 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Path Matcher. Normalizes requested paths and matches them against segment-aware globs.
+package permission
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/glob"
+)
+
+// WorkspaceRoot is the directory relative paths are resolved against.
+var WorkspaceRoot = "/workspace"
+
+// pathTools are the tools whose patterns are filesystem paths.
+var pathTools = map[string]bool{
+	"read":  true,
+	"write": true,
+	"edit":  true,
+	"patch": true,
+	"list":  true,
+}
+
+// IsPathTool reports whether the tool's patterns are filesystem paths.
+func IsPathTool(tool string) bool {
+	return pathTools[tool]
+}
+
+// NormalizePath makes p absolute (relative to root), cleans it and resolves
+// symlinks. Components that do not exist yet are kept as-is on top of the
+// longest existing, resolved prefix, so a new file inside a symlinked
+// directory still resolves to its real location.
+func NormalizePath(root, p string) string {
+	if p == "" {
+		return ""
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = filepath.Clean(p)
+
+	existing, rest := p, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return p
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// normalizePattern makes a rule path pattern absolute relative to root.
+// The bare "*" pattern keeps its meaning of "any path", and a negated pattern
+// stays negated.
+func normalizePattern(root, pattern string) string {
+	if pattern == "" || pattern == "*" {
+		return pattern
+	}
+	if strings.HasPrefix(pattern, "!") {
+		return "!" + normalizePattern(root, pattern[1:])
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(root, pattern)
+	}
+	return filepath.Clean(pattern)
+}
+
+// MatchPath matches an absolute path against a segment-aware glob pattern.
+// `**` matches any number of path segments (including none), while `*`, `?`
+// and `[...]` only match within a single segment. See the glob package for
+// the full syntax.
+func MatchPath(pattern, path string) bool {
+	if pattern == "*" {
+		return true
+	}
+	return glob.Match(pattern, path, '/')
+}
+
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' })
+}
@@ -0,0 +1,271 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Policy File. Parses policy.yaml and diffs it against tool_permissions.
+package permission
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyFileVersion is the only policy.yaml schema version understood.
+const PolicyFileVersion = 1
+
+// PolicyFile is the declarative form of tool_permissions:
+//
+//	version: 1
+//	rules:
+//	  - tool: bash
+//	    pattern: "npm *"
+//	    action: allow
+//	  - agent: poco
+//	    tool: edit
+//	    pattern: "*"
+//	    action: ask
+//	    draft_ttl: 600
+//	  - tool: bash
+//	    pattern: "rm -rf *"
+//	    action: ask
+//	    quorum: 2
+//	    quorum_group: admin
+//	  - tool: bash
+//	    pattern: "git *"
+//	    action: allow
+//	    weekdays: [mon, tue, wed, thu, fri]
+//	    hours: "08:00-20:00"
+//	    max_per_hour: 30
+type PolicyFile struct {
+	Version int          `yaml:"version"`
+	Rules   []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is one rule of the policy file. Agent is an ai_agents name.
+type PolicyRule struct {
+	Agent    string `yaml:"agent,omitempty" json:"agent,omitempty"`
+	Tool     string `yaml:"tool" json:"tool"`
+	Pattern  string `yaml:"pattern" json:"pattern"`
+	Action   string `yaml:"action" json:"action"`
+	DraftTTL int    `yaml:"draft_ttl,omitempty" json:"draft_ttl,omitempty"`
+	// Quorum and QuorumGroup require several approvers, see Rule.
+	Quorum      int    `yaml:"quorum,omitempty" json:"quorum,omitempty"`
+	QuorumGroup string `yaml:"quorum_group,omitempty" json:"quorum_group,omitempty"`
+	// Conditions, see Conditions. CronJob is a cron_jobs name.
+	Weekdays   []string `yaml:"weekdays,omitempty,flow" json:"weekdays,omitempty"`
+	Hours      string   `yaml:"hours,omitempty" json:"hours,omitempty"`
+	Timezone   string   `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	CronJob    string   `yaml:"cron_job,omitempty" json:"cron_job,omitempty"`
+	MaxPerHour int      `yaml:"max_per_hour,omitempty" json:"max_per_hour,omitempty"`
+	// Active defaults to true; inactive rules are kept but not enforced.
+	Active *bool `yaml:"active,omitempty" json:"active,omitempty"`
+}
+
+// IsActive reports whether the rule is enforced.
+func (r PolicyRule) IsActive() bool {
+	return r.Active == nil || *r.Active
+}
+
+func (r PolicyRule) key() string {
+	return r.Agent + "\x00" + r.Tool + "\x00" + r.Pattern
+}
+
+func (r PolicyRule) same(o PolicyRule) bool {
+	return r.Action == o.Action && r.DraftTTL == o.DraftTTL && r.IsActive() == o.IsActive() &&
+		r.Quorum == o.Quorum && r.QuorumGroup == o.QuorumGroup &&
+		slices.Equal(r.Weekdays, o.Weekdays) && r.Hours == o.Hours && r.Timezone == o.Timezone &&
+		r.CronJob == o.CronJob && r.MaxPerHour == o.MaxPerHour
+}
+
+// PolicyRecord is an existing global or agent tool_permissions row.
+type PolicyRecord struct {
+	ID string
+	PolicyRule
+	// Remembered is set for rules saved from an "approve and remember"
+	// decision. The policy file may override them but never deletes them.
+	Remembered bool
+}
+
+// PolicyChange is one entry of a reconcile report.
+type PolicyChange struct {
+	PolicyRule
+	// From is the previous action of an updated rule.
+	From   string `json:"from,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PolicyReport summarizes a reconcile.
+type PolicyReport struct {
+	Added     []PolicyChange `json:"added"`
+	Updated   []PolicyChange `json:"updated"`
+	Removed   []PolicyChange `json:"removed"`
+	Conflicts []PolicyChange `json:"conflicts"`
+	// Unmanaged are remembered rules missing from the file. They are kept;
+	// export the policy to adopt them.
+	Unmanaged []PolicyChange `json:"unmanaged"`
+}
+
+// Changed reports whether the reconcile modifies any rule.
+func (r PolicyReport) Changed() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Removed) > 0
+}
+
+// PolicyPlan is the set of writes that makes tool_permissions match a file.
+type PolicyPlan struct {
+	Create []PolicyRule
+	Update []PolicyRecord
+	Delete []PolicyRecord
+	Report PolicyReport
+}
+
+// ParsePolicyFile decodes and validates a policy file. Unknown keys are errors
+// so typos never silently drop a rule.
+func ParsePolicyFile(data []byte) (PolicyFile, error) {
+	var file PolicyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return file, fmt.Errorf("invalid policy file: %w", err)
+	}
+	if file.Version != PolicyFileVersion {
+		return file, fmt.Errorf("unsupported policy file version %d (want %d)", file.Version, PolicyFileVersion)
+	}
+	return file, nil
+}
+
+// MarshalPolicyFile encodes rules as a policy file, sorted global rules
+// first, then by agent, tool and pattern, so exports diff cleanly.
+func MarshalPolicyFile(rules []PolicyRule) ([]byte, error) {
+	sorted := append([]PolicyRule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Agent != b.Agent {
+			return a.Agent < b.Agent
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Pattern < b.Pattern
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString("# PocketCoder tool policy. Synced into tool_permissions by the backend.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(PolicyFile{Version: PolicyFileVersion, Rules: sorted}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// PlanPolicy diffs a policy file against the existing rows. knownAgent and
+// knownCronJob report whether an agent or cron job name exists.
+func PlanPolicy(file PolicyFile, existing []PolicyRecord, knownAgent, knownCronJob func(string) bool) PolicyPlan {
+	plan := PolicyPlan{Report: PolicyReport{
+		Added: []PolicyChange{}, Updated: []PolicyChange{}, Removed: []PolicyChange{},
+		Conflicts: []PolicyChange{}, Unmanaged: []PolicyChange{},
+	}}
+
+	// Validate the file and drop contradictory duplicates.
+	wanted := map[string]PolicyRule{}
+	var order []string
+	conflicted := map[string]bool{}
+	for _, r := range file.Rules {
+		if reason := validatePolicyRule(r, knownAgent, knownCronJob); reason != "" {
+			plan.Report.Conflicts = append(plan.Report.Conflicts, PolicyChange{PolicyRule: r, Reason: reason})
+			conflicted[r.key()] = true
+			continue
+		}
+		if prev, ok := wanted[r.key()]; ok {
+			if !prev.same(r) {
+				plan.Report.Conflicts = append(plan.Report.Conflicts, PolicyChange{PolicyRule: r, From: prev.Action, Reason: "defined twice with different settings"})
+				conflicted[r.key()] = true
+			}
+			continue
+		}
+		wanted[r.key()] = r
+		order = append(order, r.key())
+	}
+
+	current := map[string]PolicyRecord{}
+	for _, rec := range existing {
+		current[rec.key()] = rec
+	}
+
+	for _, key := range order {
+		if conflicted[key] {
+			// Leave whatever is in the database until the file is fixed.
+			continue
+		}
+		r := wanted[key]
+		rec, ok := current[key]
+		switch {
+		case !ok:
+			plan.Create = append(plan.Create, r)
+			plan.Report.Added = append(plan.Report.Added, PolicyChange{PolicyRule: r})
+		case !rec.same(r):
+			change := PolicyChange{PolicyRule: r, From: rec.Action}
+			if rec.Remembered {
+				change.Reason = "overrides a remembered approval"
+			}
+			plan.Update = append(plan.Update, PolicyRecord{ID: rec.ID, PolicyRule: r, Remembered: rec.Remembered})
+			plan.Report.Updated = append(plan.Report.Updated, change)
+		}
+	}
+
+	for _, rec := range existing {
+		if _, ok := wanted[rec.key()]; ok || conflicted[rec.key()] {
+			continue
+		}
+		if rec.Remembered {
+			plan.Report.Unmanaged = append(plan.Report.Unmanaged, PolicyChange{PolicyRule: rec.PolicyRule, Reason: "remembered approval not in the policy file"})
+			continue
+		}
+		plan.Delete = append(plan.Delete, rec)
+		plan.Report.Removed = append(plan.Report.Removed, PolicyChange{PolicyRule: rec.PolicyRule})
+	}
+	return plan
+}
+
+func validatePolicyRule(r PolicyRule, knownAgent, knownCronJob func(string) bool) string {
+	switch {
+	case r.Tool == "" || r.Pattern == "":
+		return "tool and pattern are required"
+	case r.Action != ActionAllow && r.Action != ActionAsk && r.Action != ActionDeny:
+		return fmt.Sprintf("invalid action %q", r.Action)
+	case r.DraftTTL < 0:
+		return "draft_ttl must not be negative"
+	case r.Quorum < 0:
+		return "quorum must not be negative"
+	case r.Agent != "" && !knownAgent(r.Agent):
+		return fmt.Sprintf("unknown agent %q", r.Agent)
+	case r.CronJob != "" && !knownCronJob(r.CronJob):
+		return fmt.Sprintf("unknown cron job %q", r.CronJob)
+	}
+	if err := ValidatePatterns(r.Tool, r.Pattern); err != nil {
+		return err.Error()
+	}
+	conditions := Conditions{Weekdays: r.Weekdays, Hours: r.Hours, Timezone: r.Timezone, MaxPerHour: r.MaxPerHour}
+	if err := conditions.Validate(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
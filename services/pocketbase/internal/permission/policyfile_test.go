@@ -0,0 +1,80 @@
+package permission_test
+
+import (
+	"testing"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+func TestPlanPolicy(t *testing.T) {
+	file, err := permission.ParsePolicyFile([]byte(`
+version: 1
+rules:
+  - {tool: "*", pattern: "*", action: ask}
+  - {tool: bash, pattern: "npm *", action: allow}
+  - {agent: poco, tool: bash, pattern: "git *", action: deny}
+  - {tool: bash, pattern: "make *", action: allow}
+  - {tool: bash, pattern: "make *", action: deny}
+  - {agent: ghost, tool: edit, pattern: "*", action: allow}
+  - {tool: read, pattern: "*", action: maybe}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing := []permission.PolicyRecord{
+		{ID: "r1", PolicyRule: permission.PolicyRule{Tool: "*", Pattern: "*", Action: "ask"}},
+		{ID: "r2", PolicyRule: permission.PolicyRule{Agent: "poco", Tool: "bash", Pattern: "git *", Action: "allow"}, Remembered: true},
+		{ID: "r3", PolicyRule: permission.PolicyRule{Tool: "bash", Pattern: "ls *", Action: "allow"}},
+		{ID: "r4", PolicyRule: permission.PolicyRule{Tool: "bash", Pattern: "pytest *", Action: "allow"}, Remembered: true},
+		{ID: "r5", PolicyRule: permission.PolicyRule{Tool: "bash", Pattern: "make *", Action: "ask"}},
+	}
+
+	plan := permission.PlanPolicy(file, existing,
+		func(name string) bool { return name == "poco" },
+		func(name string) bool { return name == "nightly" })
+
+	if len(plan.Create) != 1 || plan.Create[0].Pattern != "npm *" {
+		t.Errorf("Create = %+v, want npm *", plan.Create)
+	}
+	if len(plan.Update) != 1 || plan.Update[0].ID != "r2" || plan.Update[0].Action != "deny" {
+		t.Errorf("Update = %+v, want r2 -> deny", plan.Update)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0].ID != "r3" {
+		t.Errorf("Delete = %+v, want r3 only", plan.Delete)
+	}
+	if n := len(plan.Report.Conflicts); n != 3 {
+		t.Errorf("got %d conflicts, want 3: %+v", n, plan.Report.Conflicts)
+	}
+	if len(plan.Report.Unmanaged) != 1 || plan.Report.Unmanaged[0].Pattern != "pytest *" {
+		t.Errorf("Unmanaged = %+v, want pytest *", plan.Report.Unmanaged)
+	}
+}
+
+func TestPolicyFileRoundTrip(t *testing.T) {
+	inactive := false
+	rules := []permission.PolicyRule{
+		{Agent: "poco", Tool: "edit", Pattern: "*", Action: "ask", DraftTTL: 600},
+		{Tool: "bash", Pattern: "rm -rf *", Action: "deny", Active: &inactive},
+		{Tool: "*", Pattern: "*", Action: "ask"},
+	}
+	data, err := permission.MarshalPolicyFile(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := permission.ParsePolicyFile(data)
+	if err != nil {
+		t.Fatalf("exported file does not parse: %v\n%s", err, data)
+	}
+	plan := permission.PlanPolicy(file, nil, func(string) bool { return true }, func(string) bool { return true })
+	if len(plan.Create) != 3 || plan.Create[0].Tool != "*" || plan.Create[2].Agent != "poco" {
+		t.Errorf("round trip = %+v", plan.Create)
+	}
+	if plan.Create[1].IsActive() {
+		t.Error("inactive rule became active")
+	}
+
+	if _, err := permission.ParsePolicyFile([]byte("version: 1\nrules:\n  - {tool: bash, patern: x, action: allow}\n")); err == nil {
+		t.Error("unknown key accepted")
+	}
+}
@@ -0,0 +1,279 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Protected Paths. Files the backend renders and trusts, which can never be auto-approved.
+package permission
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ProtectedPath is a path pattern that writes can never be auto-approved for.
+type ProtectedPath struct {
+	Pattern string
+	// Action is the minimum action for touching the path: ask or deny.
+	Action string
+	Reason string
+}
+
+// BuiltinProtectedPaths are always enforced, whatever tool_permissions or the
+// protected_paths collection say.
+var BuiltinProtectedPaths = []ProtectedPath{
+	{Pattern: "/workspace/.opencode/opencode.json", Action: ActionDeny, Reason: "rendered by the backend from tool_permissions"},
+	{Pattern: "/workspace/.opencode/llm.env", Action: ActionDeny, Reason: "rendered by the backend from llm_keys"},
+	{Pattern: "/workspace/.opencode/proposals/**", Action: ActionAsk, Reason: "ingested as human-authored SOP proposals"},
+}
+
+// writeTools are the path tools whose requests modify files.
+var writeTools = map[string]bool{
+	"write": true,
+	"edit":  true,
+	"patch": true,
+}
+
+// IsWriteTool reports whether tool's requests modify the paths they name.
+func IsWriteTool(tool string) bool {
+	return writeTools[tool]
+}
+
+// OpenCodeProtectedRules returns protected paths as rules OpenCode can apply
+// on its own: one per write tool, with the pattern both absolute and relative
+// to the workspace root, since OpenCode asks with workspace-relative paths.
+// Deny entries come last, so they win wherever both kinds match.
+func OpenCodeProtectedRules(protected []ProtectedPath) []Rule {
+	ordered := make([]ProtectedPath, 0, len(protected))
+	for _, action := range []string{ActionAsk, ActionDeny} {
+		for _, pp := range protected {
+			if pp.Action == action {
+				ordered = append(ordered, pp)
+			}
+		}
+	}
+
+	tools := make([]string, 0, len(writeTools))
+	for tool := range writeTools {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	var rules []Rule
+	for _, tool := range tools {
+		for _, pp := range ordered {
+			abs := normalizePattern(WorkspaceRoot, pp.Pattern)
+			patterns := []string{abs}
+			if rel := strings.TrimPrefix(abs, WorkspaceRoot+"/"); rel != abs {
+				patterns = append(patterns, rel)
+			}
+			for _, pattern := range patterns {
+				rules = append(rules, Rule{Tool: tool, Pattern: pattern, Action: pp.Action})
+			}
+		}
+	}
+	return rules
+}
+
+// LoadProtectedPaths returns the active admin-defined protected paths.
+func LoadProtectedPaths(app core.App) ([]ProtectedPath, error) {
+	records, err := app.FindRecordsByFilter("protected_paths", "active = true", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]ProtectedPath, 0, len(records))
+	for _, rec := range records {
+		paths = append(paths, ProtectedPath{
+			Pattern: rec.GetString("pattern"),
+			Action:  rec.GetString("action"),
+			Reason:  rec.GetString("reason"),
+		})
+	}
+	return paths, nil
+}
+
+// protectedHit returns the most restrictive protected path written by sub:
+// the path of a write tool, a redirection target, or a path argument of a
+// command that writes files. Relative paths are resolved in every directory
+// the command may run in.
+func protectedHit(protected []ProtectedPath, sub subject) *ProtectedPath {
+	var raw []string
+	recursive := false
+	switch {
+	case sub.kind == SubjectRedirect:
+		raw = []string{sub.target}
+	case sub.kind == SubjectPath && writeTools[sub.permission]:
+		raw = []string{sub.value}
+	case sub.kind == SubjectCommand:
+		var name string
+		name, raw = writtenPaths(sub.args)
+		recursive = treeWriters[name]
+	}
+
+	dirs := sub.dirs
+	if len(dirs) == 0 {
+		dirs = []string{WorkspaceRoot}
+	}
+
+	var hit *ProtectedPath
+	for _, r := range raw {
+		for _, dir := range dirs {
+			p := NormalizePath(dir, r)
+			for i := range protected {
+				pp := &protected[i]
+				if !touchesProtected(normalizePattern(WorkspaceRoot, pp.Pattern), p, recursive) {
+					continue
+				}
+				if hit == nil || Severity(pp.Action) > Severity(hit.Action) {
+					hit = pp
+				}
+			}
+		}
+	}
+	return hit
+}
+
+// fileWriters are commands that create, modify or remove every path they are
+// given.
+var fileWriters = map[string]bool{
+	"rm": true, "rmdir": true, "unlink": true, "shred": true, "mv": true,
+	"tee": true, "touch": true, "truncate": true, "mkdir": true,
+	"chmod": true, "chown": true, "chgrp": true,
+}
+
+// copyWriters only write their last path argument, the destination.
+var copyWriters = map[string]bool{
+	"cp": true, "ln": true, "install": true, "rsync": true,
+}
+
+// inPlaceEditors write their file arguments only when editing in place.
+var inPlaceEditors = map[string]bool{
+	"sed": true, "perl": true,
+}
+
+// commandWrappers run the command that follows them.
+var commandWrappers = map[string]bool{
+	"sudo": true, "env": true, "command": true, "exec": true, "nice": true,
+	"nohup": true, "time": true, "xargs": true,
+}
+
+// treeWriters are commands that modify whole directory trees, so naming a
+// parent directory of a protected path touches it.
+var treeWriters = map[string]bool{
+	"rm": true, "rmdir": true, "mv": true, "cp": true,
+	"chmod": true, "chown": true, "chgrp": true, "rsync": true,
+}
+
+// writtenPaths returns the name of a bash command, past any wrappers and
+// variable assignments, and the arguments it writes to. Commands that do not
+// write files, such as cat or git diff, write none.
+func writtenPaths(words []string) (string, []string) {
+	for len(words) > 0 && (commandWrappers[words[0]] || isAssignment(words[0]) ||
+		(len(words[0]) > 1 && strings.HasPrefix(words[0], "-"))) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	name, args := words[0], words[1:]
+	switch {
+	case name == "dd":
+		var paths []string
+		for _, a := range args {
+			if strings.HasPrefix(a, "of=") {
+				paths = append(paths, strings.TrimPrefix(a, "of="))
+			}
+		}
+		return name, paths
+	case fileWriters[name]:
+		return name, operands(args)
+	case copyWriters[name]:
+		if ops := operands(args); len(ops) > 0 {
+			return name, ops[len(ops)-1:]
+		}
+	case inPlaceEditors[name]:
+		for _, a := range args {
+			if strings.HasPrefix(a, "-i") || a == "--in-place" || strings.HasPrefix(a, "--in-place=") ||
+				(name == "perl" && strings.HasPrefix(a, "-") && strings.Contains(a, "i")) {
+				return name, operands(args)
+			}
+		}
+	}
+	return name, nil
+}
+
+// operands returns the arguments that are not options.
+func operands(args []string) []string {
+	var ops []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") && a != "-" {
+			continue
+		}
+		ops = append(ops, a)
+	}
+	return ops
+}
+
+// isAssignment reports whether word is a NAME=value prefix of a command.
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && (i == 0 || !(r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// touchesProtected reports whether path p is covered by the protected pattern,
+// is itself a glob that expands onto it, or (for recursive commands such as
+// `rm -rf .opencode`) is one of its parent directories.
+func touchesProtected(pattern, p string, recursive bool) bool {
+	if MatchPath(pattern, p) {
+		return true
+	}
+	prefix := literalPrefix(pattern)
+	if prefix == "" {
+		return false
+	}
+	if recursive && strings.HasPrefix(prefix+"/", strings.TrimSuffix(p, "/")+"/") {
+		return true
+	}
+	return strings.ContainsAny(p, "*?[{") && MatchPath(p, prefix)
+}
+
+// literalPrefix returns the leading segments of a pattern that contain no glob
+// metacharacters.
+func literalPrefix(pattern string) string {
+	var segments []string
+	for _, seg := range splitPath(pattern) {
+		if strings.ContainsAny(seg, "*?[{") {
+			break
+		}
+		segments = append(segments, seg)
+	}
+	if len(segments) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -16,28 +16,27 @@ You should have received a copy of the GNU Affero General Public License
 along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
-// @pocketcoder-core: Wildcard Matcher. Glob-to-regex pattern matching for permission paths.
-package utils
+// @pocketcoder-core: Approver Quorum. Decides who counts towards a multi-approver permission.
+package permission
 
-import (
-	"regexp"
-	"strings"
-)
+import "slices"
 
-// MatchWildcard implements a simple glob-like pattern matching (e.g. /workspace/**).
-// It converts internal wildcards (*, ?) into regex patterns.
-func MatchWildcard(str string, pattern string) bool {
-	escaped := regexp.QuoteMeta(pattern)
-	escaped = strings.ReplaceAll(escaped, "\\*", ".*")
-	escaped = strings.ReplaceAll(escaped, "\\?", ".")
+// QuorumGroupAdmin limits a quorum to users with the admin role.
+const QuorumGroupAdmin = "admin"
 
-	if strings.HasSuffix(escaped, " .*") {
-		escaped = escaped[:len(escaped)-3] + "( .*|$|\\n)?"
-	}
-
-	re, err := regexp.Compile("(?s)^" + escaped + "$")
-	if err != nil {
+// CanApproveQuorum reports whether a user with role and groups counts
+// towards a quorum restricted to group. An empty group accepts any human
+// user; agents never count.
+func CanApproveQuorum(role string, groups []string, group string) bool {
+	if role != "user" && role != "admin" {
 		return false
 	}
-	return re.MatchString(str)
+	switch group {
+	case "":
+		return true
+	case QuorumGroupAdmin:
+		return role == "admin" || slices.Contains(groups, group)
+	default:
+		return slices.Contains(groups, group)
+	}
 }
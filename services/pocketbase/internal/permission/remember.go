@@ -0,0 +1,232 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Approval Scopes. Turns "approve and remember" decisions into tool_permissions rules.
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Approval scopes, mirroring the permissions "approval_scope" select values.
+const (
+	ApprovalOnce   = "once"
+	ApprovalChat   = "chat"
+	ApprovalAgent  = "agent"
+	ApprovalAlways = "always"
+)
+
+// ErrNotRememberable is returned when no safe rule can be generated for a
+// request, e.g. a compound bash command. The user can still approve it once,
+// or provide the pattern explicitly.
+var ErrNotRememberable = errors.New("request cannot be remembered automatically")
+
+// Approval describes a human approval together with how widely it should apply.
+type Approval struct {
+	Scope      string
+	Permission string
+	Patterns   []string
+	Metadata   map[string]any
+	AgentID    string
+	ChatID     string
+	// Pattern overrides the generated pattern when set.
+	Pattern string
+}
+
+// RulePlan is the set of tool_permissions rows an approval would save.
+type RulePlan struct {
+	Scope string `json:"scope"`
+	// Rules are the allow rules generated from the request.
+	Rules []Rule `json:"rules"`
+	// Seeded are global rules copied into the agent scope first. An agent rule
+	// for a tool shadows every global rule for it, so without the copies a
+	// single remembered command would drop the global denies for that tool.
+	Seeded []Rule `json:"seeded,omitempty"`
+}
+
+// IsApprovalScope reports whether scope is a known approval scope.
+func IsApprovalScope(scope string) bool {
+	switch scope {
+	case ApprovalOnce, ApprovalChat, ApprovalAgent, ApprovalAlways:
+		return true
+	}
+	return false
+}
+
+// PlanApproval returns the rules to save for an approval. existing are the
+// active rules visible to the agent (see LoadRules); they are used to seed a
+// new agent scope. A once approval yields an empty plan.
+func PlanApproval(existing []Rule, a Approval) (RulePlan, error) {
+	plan := RulePlan{Scope: a.Scope, Rules: []Rule{}}
+	if !IsApprovalScope(a.Scope) {
+		return plan, fmt.Errorf("unknown approval scope %q", a.Scope)
+	}
+	if a.Scope == ApprovalOnce {
+		return plan, nil
+	}
+
+	var agentID, chatID string
+	switch a.Scope {
+	case ApprovalChat:
+		if a.ChatID == "" {
+			return plan, errors.New("chat scope requires a permission linked to a chat")
+		}
+		chatID = a.ChatID
+	case ApprovalAgent:
+		if a.AgentID == "" {
+			return plan, errors.New("agent scope requires a permission linked to an agent")
+		}
+		agentID = a.AgentID
+	}
+
+	patterns, err := rulePatterns(a)
+	if err != nil {
+		return plan, err
+	}
+	for _, p := range patterns {
+		plan.Rules = append(plan.Rules, Rule{
+			AgentID: agentID,
+			ChatID:  chatID,
+			Tool:    a.Permission,
+			Pattern: p,
+			Action:  ActionAllow,
+		})
+	}
+
+	if a.Scope == ApprovalAgent {
+		plan.Seeded = seedAgentScope(existing, a.Permission, agentID)
+	}
+	return plan, nil
+}
+
+// rulePatterns generates the patterns an approval remembers.
+func rulePatterns(a Approval) ([]string, error) {
+	if a.Pattern != "" {
+		if IsPathTool(a.Permission) {
+			return []string{normalizePattern(WorkspaceRoot, a.Pattern)}, nil
+		}
+		return []string{a.Pattern}, nil
+	}
+
+	if a.Permission == "bash" {
+		cmd, _ := a.Metadata["command"].(string)
+		if strings.TrimSpace(cmd) == "" && len(a.Patterns) == 1 {
+			cmd = a.Patterns[0]
+		}
+		p, err := CommandPattern(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return []string{p}, nil
+	}
+
+	if len(a.Patterns) == 0 {
+		return []string{"*"}, nil
+	}
+	patterns := make([]string, 0, len(a.Patterns))
+	seen := map[string]bool{}
+	for _, p := range a.Patterns {
+		if IsPathTool(a.Permission) {
+			// Remember the exact (resolved) path, never its directory.
+			p = NormalizePath(WorkspaceRoot, p)
+		}
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%w: no patterns to remember", ErrNotRememberable)
+	}
+	return patterns, nil
+}
+
+// subcommandWord matches words treated as a subcommand, as in `npm test`,
+// `git commit` or `docker compose`.
+var subcommandWord = regexp.MustCompile(`^[a-z][a-z0-9:_-]*$`)
+
+// CommandPattern generates the rule pattern remembered for a bash command:
+// the program, its subcommand if it has one, and a trailing " *" so later
+// arguments are covered (`npm test --watch` → `npm test *`). Compound
+// commands, redirections and leading variable assignments are refused, since
+// no single pattern describes them safely.
+func CommandPattern(cmd string) (string, error) {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return "", fmt.Errorf("%w: empty command", ErrNotRememberable)
+	}
+
+	commands, err := ParseShell(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNotRememberable, err)
+	}
+	if len(commands) != 1 {
+		return "", fmt.Errorf("%w: compound commands can only be approved once", ErrNotRememberable)
+	}
+	c := commands[0]
+	if len(c.Redirects) > 0 {
+		return "", fmt.Errorf("%w: commands with redirections can only be approved once", ErrNotRememberable)
+	}
+	if len(c.Words) == 0 || strings.Contains(c.Words[0], "=") {
+		return "", fmt.Errorf("%w: commands with variable assignments can only be approved once", ErrNotRememberable)
+	}
+
+	words := []string{c.Words[0]}
+	if len(c.Words) > 1 && subcommandWord.MatchString(c.Words[1]) {
+		words = append(words, c.Words[1])
+	}
+	return strings.Join(words, " ") + " *", nil
+}
+
+// seedAgentScope returns copies of the global rules that apply to tool when
+// the agent has no rules of its own for it yet. Copies use the exact tool
+// name so they never spill over onto other tools.
+func seedAgentScope(existing []Rule, tool, agentID string) []Rule {
+	scoped, scope, _ := scopeRules(existing, tool, agentID)
+	if scope == ScopeAgent {
+		return nil
+	}
+
+	// Most specific first, so a wildcard-tool rule never replaces an exact
+	// one with the same pattern.
+	sort.SliceStable(scoped, func(i, j int) bool {
+		return moreSpecific(&scoped[i], &scoped[j], tool)
+	})
+
+	var seeded []Rule
+	seen := map[string]bool{}
+	for _, r := range scoped {
+		if seen[r.Pattern] {
+			continue
+		}
+		seen[r.Pattern] = true
+		seeded = append(seeded, Rule{
+			AgentID:  agentID,
+			Tool:     tool,
+			Pattern:  r.Pattern,
+			Action:   r.Action,
+			DraftTTL: r.DraftTTL,
+		})
+	}
+	return seeded
+}
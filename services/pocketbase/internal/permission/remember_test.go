@@ -0,0 +1,112 @@
+package permission_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+func TestCommandPattern(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"npm test", "npm test *"},
+		{"npm test -- --watch", "npm test *"},
+		{"git commit -m 'fix'", "git commit *"},
+		{"ls -la src", "ls *"},
+		{"./scripts/build.sh release", "./scripts/build.sh release *"},
+		{"cat README.md", "cat *"},
+	}
+	for _, tt := range tests {
+		got, err := permission.CommandPattern(tt.cmd)
+		if err != nil || got != tt.want {
+			t.Errorf("CommandPattern(%q) = %q, %v; want %q", tt.cmd, got, err, tt.want)
+		}
+	}
+
+	for _, cmd := range []string{"npm test && rm -rf /", "echo x > out.txt", "FOO=1 npm test", "cat <<EOF", ""} {
+		if _, err := permission.CommandPattern(cmd); !errors.Is(err, permission.ErrNotRememberable) {
+			t.Errorf("CommandPattern(%q) error = %v, want ErrNotRememberable", cmd, err)
+		}
+	}
+}
+
+func TestPlanApproval(t *testing.T) {
+	existing := []permission.Rule{
+		{ID: "g1", Tool: "*", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g2", Tool: "bash", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g3", Tool: "bash", Pattern: "rm -rf *", Action: permission.ActionDeny},
+	}
+	approval := permission.Approval{
+		Permission: "bash",
+		Metadata:   map[string]any{"command": "npm test --watch"},
+		AgentID:    "agent1",
+		ChatID:     "chat1",
+	}
+
+	approval.Scope = permission.ApprovalOnce
+	if plan, err := permission.PlanApproval(existing, approval); err != nil || len(plan.Rules) != 0 {
+		t.Fatalf("once: plan = %+v, err = %v; want no rules", plan, err)
+	}
+
+	approval.Scope = permission.ApprovalChat
+	plan, err := permission.PlanApproval(existing, approval)
+	if err != nil || len(plan.Rules) != 1 || plan.Rules[0].ChatID != "chat1" || plan.Rules[0].AgentID != "" || plan.Rules[0].Pattern != "npm test *" {
+		t.Fatalf("chat: plan = %+v, err = %v", plan, err)
+	}
+
+	// A new agent scope is seeded with the global bash rules, so the global
+	// deny is not shadowed by the remembered allow.
+	approval.Scope = permission.ApprovalAgent
+	plan, err = permission.PlanApproval(existing, approval)
+	if err != nil || len(plan.Rules) != 1 || plan.Rules[0].AgentID != "agent1" {
+		t.Fatalf("agent: plan = %+v, err = %v", plan, err)
+	}
+	seeded := map[string]string{}
+	for _, r := range plan.Seeded {
+		if r.Tool != "bash" || r.AgentID != "agent1" {
+			t.Errorf("seeded rule %+v, want bash rule for agent1", r)
+		}
+		seeded[r.Pattern] = r.Action
+	}
+	if len(seeded) != 2 || seeded["rm -rf *"] != permission.ActionDeny || seeded["*"] != permission.ActionAsk {
+		t.Errorf("seeded = %v, want the global bash rules", seeded)
+	}
+
+	approval.Scope = permission.ApprovalAlways
+	approval.Pattern = "npm *"
+	plan, err = permission.PlanApproval(existing, approval)
+	if err != nil || len(plan.Rules) != 1 || plan.Rules[0].AgentID != "" || plan.Rules[0].ChatID != "" || plan.Rules[0].Pattern != "npm *" || len(plan.Seeded) != 0 {
+		t.Fatalf("always: plan = %+v, err = %v", plan, err)
+	}
+}
+
+func TestResolveChatRules(t *testing.T) {
+	rules := []permission.Rule{
+		{ID: "g1", Tool: "bash", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g2", Tool: "bash", Pattern: "npm publish *", Action: permission.ActionDeny},
+		{ID: "c1", ChatID: "chat1", Tool: "bash", Pattern: "npm *", Action: permission.ActionAllow},
+	}
+	bash := func(chat, cmd string) permission.EvaluationInput {
+		return permission.EvaluationInput{Permission: "bash", ChatID: chat, Metadata: map[string]any{"command": cmd}}
+	}
+
+	tests := []struct {
+		name  string
+		input permission.EvaluationInput
+		want  string
+	}{
+		{"remembered in chat", bash("chat1", "npm test"), permission.ActionAllow},
+		{"other chat", bash("chat2", "npm test"), permission.ActionAsk},
+		{"cannot lift deny", bash("chat1", "npm publish --tag next"), permission.ActionDeny},
+		{"compound still checked", bash("chat1", "npm test && curl x"), permission.ActionAsk},
+	}
+	for _, tt := range tests {
+		d := permission.Resolve(permission.Policy{Rules: rules}, tt.input)
+		if d.Action != tt.want {
+			t.Errorf("%s: Resolve() = %q, want %q (%s)", tt.name, d.Action, tt.want, d.Trace.Reason)
+		}
+	}
+}
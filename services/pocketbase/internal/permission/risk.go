@@ -0,0 +1,374 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Risk Classifier. Scores permission requests with explainable reasons.
+package permission
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Risk levels, mirroring the permissions "risk_level" select values.
+const (
+	RiskLow      = "low"
+	RiskMedium   = "medium"
+	RiskHigh     = "high"
+	RiskCritical = "critical"
+)
+
+// Risk categories reported with each reason.
+const (
+	RiskDestructive = "destructive"
+	RiskNetwork     = "network"
+	RiskPrivilege   = "privilege"
+	RiskInstall     = "install"
+	RiskSecrets     = "secrets"
+	RiskUnparsed    = "unparsed"
+)
+
+// Risk is the assessed risk of a request. Score is 0-100.
+type Risk struct {
+	Score   int          `json:"score"`
+	Level   string       `json:"level"`
+	Reasons []RiskReason `json:"reasons"`
+}
+
+// RiskReason explains one contribution to the score.
+type RiskReason struct {
+	Category string `json:"category"`
+	Subject  string `json:"subject"`
+	Reason   string `json:"reason"`
+	Score    int    `json:"score"`
+}
+
+// Summary returns the most significant reason, for notification text.
+func (r Risk) Summary() string {
+	if len(r.Reasons) == 0 {
+		return ""
+	}
+	return r.Reasons[0].Reason
+}
+
+// RiskLevelFor maps a score onto a level.
+func RiskLevelFor(score int) string {
+	switch {
+	case score >= 75:
+		return RiskCritical
+	case score >= 50:
+		return RiskHigh
+	case score >= 25:
+		return RiskMedium
+	default:
+		return RiskLow
+	}
+}
+
+// AssessRisk scores a request. Each category counts once, at its highest
+// finding, and the categories add up (capped at 100), so `curl x | sudo sh`
+// ranks above either half on its own.
+func AssessRisk(input EvaluationInput) Risk {
+	var findings []RiskReason
+
+	switch {
+	case input.Permission == "bash":
+		cmd, _ := input.Metadata["command"].(string)
+		if strings.TrimSpace(cmd) == "" {
+			cmd = strings.Join(input.Patterns, " ")
+		}
+		findings = bashRisks(strings.TrimSpace(cmd))
+	case IsPathTool(input.Permission):
+		for _, p := range input.Patterns {
+			findings = append(findings, pathRisks(input.Permission, NormalizePath(WorkspaceRoot, p))...)
+		}
+	case input.Permission == "webfetch" || input.Permission == "websearch":
+		for _, p := range input.Patterns {
+			findings = append(findings, RiskReason{RiskNetwork, p, "fetches " + p + " from the network", 20})
+		}
+	}
+
+	best := map[string]RiskReason{}
+	for _, f := range findings {
+		if cur, ok := best[f.Category]; !ok || f.Score > cur.Score {
+			best[f.Category] = f
+		}
+	}
+
+	risk := Risk{Reasons: []RiskReason{}}
+	for _, f := range best {
+		risk.Reasons = append(risk.Reasons, f)
+		risk.Score += f.Score
+	}
+	sort.Slice(risk.Reasons, func(i, j int) bool {
+		if risk.Reasons[i].Score != risk.Reasons[j].Score {
+			return risk.Reasons[i].Score > risk.Reasons[j].Score
+		}
+		return risk.Reasons[i].Category < risk.Reasons[j].Category
+	})
+	if risk.Score > 100 {
+		risk.Score = 100
+	}
+	risk.Level = RiskLevelFor(risk.Score)
+	return risk
+}
+
+// shells are interpreters that run whatever is piped or passed into them.
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true,
+}
+
+var networkTools = map[string]bool{
+	"curl": true, "wget": true, "nc": true, "ncat": true, "netcat": true, "telnet": true,
+	"ssh": true, "scp": true, "sftp": true, "ftp": true, "socat": true,
+}
+
+var escalationTools = map[string]bool{
+	"sudo": true, "su": true, "doas": true, "pkexec": true, "setcap": true,
+}
+
+// installers maps package managers to the subcommands that install packages.
+var installers = map[string][]string{
+	"npm": {"install", "i", "add", "ci"}, "pnpm": {"install", "i", "add"}, "yarn": {"add", "install"},
+	"bun": {"add", "install"}, "pip": {"install"}, "pip3": {"install"}, "pipx": {"install"},
+	"uv": {"add", "pip"}, "poetry": {"add", "install"}, "apt": {"install"}, "apt-get": {"install"},
+	"apk": {"add"}, "dnf": {"install"}, "yum": {"install"}, "brew": {"install"},
+	"cargo": {"install", "add"}, "go": {"install", "get"}, "gem": {"install"},
+}
+
+// Files and directories that usually hold credentials.
+var (
+	secretNames    = []string{"id_rsa", "id_ed25519", "id_ecdsa", "credentials", "credentials.json", ".netrc", ".npmrc", ".pypirc", "shadow", "sudoers"}
+	secretSuffixes = []string{".env", ".pem", ".key", ".p12", ".pfx"}
+	secretDirs     = []string{"/.ssh/", "/.aws/", "/.gnupg/", "/.docker/", "/.kube/"}
+)
+
+func bashRisks(cmd string) []RiskReason {
+	if cmd == "" {
+		return nil
+	}
+	commands, err := ParseShell(cmd)
+	if err != nil {
+		return []RiskReason{{RiskUnparsed, cmd, "command uses shell constructs that cannot be analyzed (" + err.Error() + ")", 25}}
+	}
+
+	var findings []RiskReason
+	for i, c := range commands {
+		words := c.Words
+		for len(words) > 0 && escalationTools[words[0]] {
+			findings = append(findings, RiskReason{RiskPrivilege, c.String(), "runs with elevated privileges via " + words[0], 40})
+			words = stripEscalation(words)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		findings = append(findings, commandRisks(c.String(), words)...)
+
+		// Something downloaded and piped straight into an interpreter.
+		if shells[filepath.Base(words[0])] && i > 0 && hasNetworkTool(commands[:i]) {
+			findings = append(findings, RiskReason{RiskNetwork, cmd, "pipes downloaded content into " + words[0], 60})
+		}
+		for _, r := range c.Redirects {
+			if r.WritesFile() && !discardTargets[r.Target] {
+				findings = append(findings, pathRisks("edit", NormalizePath(WorkspaceRoot, r.Target))...)
+			}
+		}
+	}
+	return findings
+}
+
+// stripEscalation drops sudo and its options, leaving the escalated command.
+func stripEscalation(words []string) []string {
+	words = words[1:]
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		opt := words[0]
+		words = words[1:]
+		// Options that take a value, e.g. `sudo -u root`.
+		if (opt == "-u" || opt == "-g" || opt == "-C") && len(words) > 0 {
+			words = words[1:]
+		}
+	}
+	return words
+}
+
+func hasNetworkTool(commands []Command) bool {
+	for _, c := range commands {
+		if len(c.Words) > 0 && networkTools[c.Words[0]] {
+			return true
+		}
+	}
+	return false
+}
+
+func commandRisks(text string, words []string) []RiskReason {
+	prog := filepath.Base(words[0])
+	args := words[1:]
+	has := func(flags ...string) bool {
+		for _, a := range args {
+			for _, f := range flags {
+				if a == f || (len(f) == 2 && strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.Contains(a[1:], f[1:])) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	var findings []RiskReason
+	add := func(category, reason string, score int) {
+		findings = append(findings, RiskReason{category, text, reason, score})
+	}
+
+	switch prog {
+	case "rm":
+		switch {
+		case has("-r", "-R", "--recursive") && has("-f", "--force"):
+			add(RiskDestructive, "recursively force-deletes files", 45)
+		case has("-r", "-R", "--recursive"):
+			add(RiskDestructive, "recursively deletes files", 35)
+		default:
+			add(RiskDestructive, "deletes files", 15)
+		}
+		for _, a := range args {
+			if a == "/" || a == "~" || a == "/*" || a == "." || a == ".." || a == "*" {
+				add(RiskDestructive, "deletes "+a, 70)
+			}
+		}
+	case "dd", "mkfs", "shred", "wipefs", "fdisk", "parted":
+		add(RiskDestructive, prog+" can overwrite disks or files irrecoverably", 60)
+	case "truncate":
+		add(RiskDestructive, "truncates files", 25)
+	case "find":
+		if has("-delete") || containsWord(args, "rm") {
+			add(RiskDestructive, "deletes the files find matches", 40)
+		}
+	case "chmod", "chown", "chgrp":
+		if has("-R", "--recursive") {
+			add(RiskDestructive, "recursively changes ownership or permissions", 30)
+		}
+		if prog == "chmod" && (containsWord(args, "777") || containsWord(args, "+s") || containsWord(args, "u+s")) {
+			add(RiskPrivilege, "makes files world-writable or setuid", 35)
+		}
+		if prog == "chown" && len(args) > 0 && strings.HasPrefix(args[0], "root") {
+			add(RiskPrivilege, "hands files to root", 30)
+		}
+	case "git":
+		switch {
+		case sub == "push" && has("-f", "--force", "--force-with-lease"):
+			add(RiskDestructive, "force-pushes, rewriting remote history", 45)
+			add(RiskNetwork, "pushes to a remote", 20)
+		case sub == "push":
+			add(RiskNetwork, "pushes to a remote", 20)
+		case sub == "reset" && has("--hard"):
+			add(RiskDestructive, "discards uncommitted changes", 35)
+		case sub == "clean" && has("-f"):
+			add(RiskDestructive, "deletes untracked files", 35)
+		case sub == "clone" || sub == "fetch" || sub == "pull":
+			add(RiskNetwork, "downloads from a remote", 10)
+		}
+	case "npm", "pnpm", "yarn":
+		if sub == "publish" {
+			add(RiskNetwork, "publishes a package to a registry", 50)
+		}
+	case "env", "printenv", "set", "export":
+		if len(args) == 0 {
+			add(RiskSecrets, "dumps environment variables, which may hold secrets", 30)
+		}
+	case "kill", "pkill", "killall", "shutdown", "reboot", "systemctl":
+		add(RiskDestructive, prog+" stops processes or services", 25)
+	case "docker":
+		if sub == "run" && has("--privileged") {
+			add(RiskPrivilege, "starts a privileged container", 50)
+		}
+	}
+
+	if networkTools[prog] {
+		score := 20
+		if has("-d", "--data", "-F", "--form", "-T", "--upload-file", "--post-data", "--post-file") {
+			score = 35
+		}
+		add(RiskNetwork, prog+" talks to the network", score)
+	}
+	if subs, ok := installers[prog]; ok && containsWord(subs, sub) {
+		add(RiskInstall, "installs packages with "+prog, 25)
+	}
+	if shells[prog] && has("-c", "-e") {
+		add(RiskUnparsed, "runs an inline "+prog+" script that cannot be analyzed", 20)
+	}
+	for _, a := range args {
+		if isSecretPath(a) {
+			add(RiskSecrets, "touches "+a+", which may hold secrets", 40)
+		}
+	}
+	return findings
+}
+
+func pathRisks(tool, path string) []RiskReason {
+	var findings []RiskReason
+	if isSecretPath(path) {
+		verb := "reads"
+		score := 35
+		if writeTools[tool] {
+			verb, score = "writes", 45
+		}
+		findings = append(findings, RiskReason{RiskSecrets, path, fmt.Sprintf("%s %s, which may hold secrets", verb, path), score})
+	}
+	if writeTools[tool] && WorkspaceRoot != "" && !strings.HasPrefix(path+"/", WorkspaceRoot+"/") {
+		findings = append(findings, RiskReason{RiskDestructive, path, "writes outside the workspace", 40})
+	}
+	return findings
+}
+
+func isSecretPath(p string) bool {
+	lower := "/" + strings.ToLower(strings.TrimPrefix(p, "~"))
+	base := filepath.Base(lower)
+	for _, safe := range []string{".example", ".sample", ".template"} {
+		if strings.HasSuffix(base, safe) {
+			return false
+		}
+	}
+	if base == ".env" || strings.HasPrefix(base, ".env.") || containsWord(secretNames, base) {
+		return true
+	}
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	for _, dir := range secretDirs {
+		if strings.Contains(lower, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWord(words []string, w string) bool {
+	for _, x := range words {
+		if x == w {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,502 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Shell Parser. Splits bash strings into simple commands so each one is authorized on its own.
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedShell is returned for bash constructs the parser does not model.
+// Callers must treat such commands as needing a human decision.
+var ErrUnsupportedShell = errors.New("unsupported shell construct")
+
+// Command is a single simple command extracted from a bash string.
+type Command struct {
+	// Words is the argv of the command after quote removal.
+	Words []string
+	// Redirects lists the redirections attached to the command.
+	Redirects []Redirect
+}
+
+// String renders the command as the text rule patterns are matched against.
+func (c Command) String() string {
+	return strings.Join(c.Words, " ")
+}
+
+// Redirect is a single redirection such as `> out.txt` or `2>&1`.
+type Redirect struct {
+	Op     string
+	Target string
+}
+
+// WritesFile reports whether the redirection writes to a file (as opposed to
+// reading, or duplicating another file descriptor).
+func (r Redirect) WritesFile() bool {
+	if !strings.Contains(r.Op, ">") {
+		return false
+	}
+	if strings.HasSuffix(r.Op, "&") && (r.Target == "-" || isDigits(r.Target)) {
+		return false
+	}
+	return true
+}
+
+// shellKeywords are compound-command keywords whose control flow the parser
+// does not model.
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "while": true, "until": true, "do": true, "done": true,
+	"case": true, "esac": true, "select": true, "function": true,
+	"[[": true, "]]": true, "coproc": true,
+}
+
+// ParseShell splits a bash string into its simple commands. Pipelines,
+// `&&` / `||` / `;` / `&` sequences, subshells, `{ ...; }` groups, command
+// substitution (`$(...)` and backticks) and redirections are decomposed;
+// commands nested in substitutions are returned alongside the outer command.
+//
+// Here-documents, process substitution, arithmetic expansion, brace
+// expansion, `$'...'` and `$"..."` quoting, control-flow keywords and
+// function definitions return ErrUnsupportedShell.
+func ParseShell(src string) ([]Command, error) {
+	p := &shellParser{src: []rune(src)}
+	if err := p.parseList(0); err != nil {
+		return nil, err
+	}
+	return p.cmds, nil
+}
+
+type shellParser struct {
+	src  []rune
+	pos  int
+	cmds []Command
+}
+
+func unsupported(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrUnsupportedShell, fmt.Sprintf(format, args...))
+}
+
+func (p *shellParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *shellParser) peek() rune { return p.peekAt(0) }
+
+func (p *shellParser) peekAt(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+func (p *shellParser) skipBlanks() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\\':
+			// Line continuation.
+			if p.peekAt(1) != '\n' {
+				return
+			}
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// parseList parses commands and operators until EOF or the term rune.
+func (p *shellParser) parseList(term rune) error {
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			if term != 0 {
+				return fmt.Errorf("unterminated %q", term)
+			}
+			return nil
+		}
+
+		c := p.peek()
+		switch {
+		case term != 0 && c == term:
+			p.pos++
+			return nil
+		case c == ')':
+			return fmt.Errorf("unexpected ')'")
+		case c == ';' && p.peekAt(1) == ';':
+			return unsupported("case terminator ';;'")
+		case c == ';' || c == '\n':
+			p.pos++
+			continue
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+			continue
+		}
+
+		if err := p.parseCommand(term); err != nil {
+			return err
+		}
+
+		// Operator following the command.
+		p.skipBlanks()
+		if p.eof() {
+			continue
+		}
+		switch c := p.peek(); {
+		case c == '&' && p.peekAt(1) == '&', c == '|' && p.peekAt(1) == '|', c == '|' && p.peekAt(1) == '&':
+			p.pos += 2
+			if err := p.requireCommand(term); err != nil {
+				return err
+			}
+		case c == '|':
+			p.pos++
+			if err := p.requireCommand(term); err != nil {
+				return err
+			}
+		case c == '&':
+			p.pos++
+		}
+	}
+}
+
+// requireCommand checks that a binary operator is followed by a command.
+func (p *shellParser) requireCommand(term rune) error {
+	for {
+		p.skipBlanks()
+		if p.peek() != '\n' {
+			break
+		}
+		p.pos++
+	}
+	c := p.peek()
+	if p.eof() || c == ';' || c == '&' || c == '|' || (term != 0 && c == term) {
+		return fmt.Errorf("syntax error: operator without a command")
+	}
+	return nil
+}
+
+// parseCommand parses a simple command, subshell or group with its redirections.
+func (p *shellParser) parseCommand(term rune) error {
+	idx := len(p.cmds)
+	p.cmds = append(p.cmds, Command{})
+	var cmd Command
+	subshell := false
+
+	if p.peek() == '(' {
+		if p.peekAt(1) == '(' {
+			return unsupported("arithmetic command '(( ))'")
+		}
+		p.pos++
+		if err := p.parseList(')'); err != nil {
+			return err
+		}
+		subshell = true
+	}
+
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			break
+		}
+		c := p.peek()
+		if c == ';' || c == '|' || c == '\n' || (c == '&' && p.peekAt(1) != '>') || (term != 0 && c == term) || c == ')' {
+			break
+		}
+		if c == '#' {
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+			break
+		}
+		if c == '(' {
+			return unsupported("unexpected '(' (function definition or array)")
+		}
+		if c == '>' || c == '<' || c == '&' {
+			r, err := p.parseRedirect("")
+			if err != nil {
+				return err
+			}
+			cmd.Redirects = append(cmd.Redirects, r)
+			continue
+		}
+
+		start := p.pos
+		word, err := p.readWord()
+		if err != nil {
+			return err
+		}
+		// `2>file`, `1>&2`: a bare file descriptor number glued to a redirect.
+		if isDigits(string(p.src[start:p.pos])) && (p.peek() == '>' || p.peek() == '<') {
+			r, err := p.parseRedirect(word)
+			if err != nil {
+				return err
+			}
+			cmd.Redirects = append(cmd.Redirects, r)
+			continue
+		}
+
+		if subshell {
+			return fmt.Errorf("syntax error: unexpected word %q after subshell", word)
+		}
+		if len(cmd.Words) == 0 {
+			if shellKeywords[word] {
+				return unsupported("keyword %q", word)
+			}
+			// Pipeline negation and brace groups don't change what runs.
+			if word == "!" || word == "{" || word == "}" {
+				continue
+			}
+			if p.peek() == '(' {
+				return unsupported("function definition %q", word)
+			}
+		}
+		cmd.Words = append(cmd.Words, word)
+	}
+
+	if len(cmd.Words) == 0 && len(cmd.Redirects) == 0 {
+		p.cmds = append(p.cmds[:idx], p.cmds[idx+1:]...)
+		return nil
+	}
+	p.cmds[idx] = cmd
+	return nil
+}
+
+// parseRedirect parses a redirection operator and its target. fd is the
+// optional file descriptor number that preceded the operator.
+func (p *shellParser) parseRedirect(fd string) (Redirect, error) {
+	var op string
+	switch {
+	case p.peek() == '<' && p.peekAt(1) == '<' && p.peekAt(2) == '<':
+		op = "<<<"
+	case p.peek() == '<' && p.peekAt(1) == '<':
+		return Redirect{}, unsupported("here-document")
+	case (p.peek() == '<' || p.peek() == '>') && p.peekAt(1) == '(':
+		return Redirect{}, unsupported("process substitution")
+	case p.peek() == '&' && p.peekAt(1) == '>' && p.peekAt(2) == '>':
+		op = "&>>"
+	case p.peek() == '&' && p.peekAt(1) == '>':
+		op = "&>"
+	case p.peek() == '>' && (p.peekAt(1) == '>' || p.peekAt(1) == '|' || p.peekAt(1) == '&'):
+		op = string(p.src[p.pos : p.pos+2])
+	case p.peek() == '<' && (p.peekAt(1) == '&' || p.peekAt(1) == '>'):
+		op = string(p.src[p.pos : p.pos+2])
+	case p.peek() == '>' || p.peek() == '<':
+		op = string(p.peek())
+	default:
+		return Redirect{}, fmt.Errorf("unexpected %q", p.peek())
+	}
+	p.pos += len([]rune(op))
+
+	p.skipBlanks()
+	if p.eof() || strings.ContainsRune(";&|<>()\n", p.peek()) {
+		return Redirect{}, fmt.Errorf("syntax error: redirect %q without a target", op)
+	}
+	target, err := p.readWord()
+	if err != nil {
+		return Redirect{}, err
+	}
+	return Redirect{Op: fd + op, Target: target}, nil
+}
+
+// readWord reads a single shell word, performing quote removal and parsing
+// any command substitutions it contains.
+func (p *shellParser) readWord() (string, error) {
+	var b strings.Builder
+	// braces counts the unquoted '{' not yet closed; a ',' or '..' inside
+	// one makes the word a brace expansion.
+	braces, expands := 0, false
+	for !p.eof() {
+		c := p.peek()
+		switch c {
+		case ' ', '\t', '\r', '\n', ';', '&', '|', '<', '>', '(', ')':
+			return b.String(), nil
+		case '\'':
+			p.pos++
+			end := p.indexFrom('\'')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated single quote")
+			}
+			b.WriteString(string(p.src[p.pos:end]))
+			p.pos = end + 1
+		case '"':
+			p.pos++
+			if err := p.readDoubleQuoted(&b); err != nil {
+				return "", err
+			}
+		case '\\':
+			p.pos++
+			if p.eof() {
+				return b.String(), nil
+			}
+			if p.peek() != '\n' {
+				b.WriteRune(p.peek())
+			}
+			p.pos++
+		case '$':
+			switch p.peekAt(1) {
+			case '\'':
+				return "", unsupported("ANSI-C quoting \"$'...'\"")
+			case '"':
+				return "", unsupported("locale quoting '$\"...\"'")
+			}
+			if err := p.readDollar(&b); err != nil {
+				return "", err
+			}
+		case '`':
+			if err := p.readBackticks(&b); err != nil {
+				return "", err
+			}
+		default:
+			switch {
+			case c == '{':
+				braces++
+			case braces > 0 && (c == ',' || c == '.' && p.peekAt(1) == '.'):
+				expands = true
+			case braces > 0 && c == '}':
+				if expands {
+					return "", unsupported("brace expansion")
+				}
+				braces--
+			}
+			b.WriteRune(c)
+			p.pos++
+		}
+	}
+	return b.String(), nil
+}
+
+// readDoubleQuoted reads the body of a "..." string (opening quote consumed).
+func (p *shellParser) readDoubleQuoted(b *strings.Builder) error {
+	for !p.eof() {
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return nil
+		case '\\':
+			next := p.peekAt(1)
+			if next == '$' || next == '`' || next == '"' || next == '\\' {
+				b.WriteRune(next)
+				p.pos += 2
+			} else if next == '\n' {
+				p.pos += 2
+			} else {
+				b.WriteRune(c)
+				p.pos++
+			}
+		case '$':
+			if err := p.readDollar(b); err != nil {
+				return err
+			}
+		case '`':
+			if err := p.readBackticks(b); err != nil {
+				return err
+			}
+		default:
+			b.WriteRune(c)
+			p.pos++
+		}
+	}
+	return fmt.Errorf("unterminated double quote")
+}
+
+// readDollar handles `$(...)`, `${...}` and plain `$` expansions.
+func (p *shellParser) readDollar(b *strings.Builder) error {
+	start := p.pos
+	switch p.peekAt(1) {
+	case '(':
+		if p.peekAt(2) == '(' {
+			return unsupported("arithmetic expansion '$(( ))'")
+		}
+		p.pos += 2
+		if err := p.parseList(')'); err != nil {
+			return err
+		}
+		b.WriteString(string(p.src[start:p.pos]))
+	case '{':
+		end := p.indexFrom('}')
+		if end < 0 {
+			return fmt.Errorf("unterminated parameter expansion")
+		}
+		body := string(p.src[p.pos:end])
+		if strings.Contains(body, "$(") || strings.Contains(body, "`") {
+			return unsupported("command substitution inside parameter expansion")
+		}
+		b.WriteString(string(p.src[start : end+1]))
+		p.pos = end + 1
+	default:
+		b.WriteRune('$')
+		p.pos++
+	}
+	return nil
+}
+
+// readBackticks parses a legacy `...` command substitution.
+func (p *shellParser) readBackticks(b *strings.Builder) error {
+	start := p.pos
+	p.pos++
+	var inner strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		if c == '\\' && (p.peekAt(1) == '`' || p.peekAt(1) == '\\' || p.peekAt(1) == '$') {
+			inner.WriteRune(p.peekAt(1))
+			p.pos += 2
+			continue
+		}
+		if c == '`' {
+			p.pos++
+			nested, err := ParseShell(inner.String())
+			if err != nil {
+				return err
+			}
+			p.cmds = append(p.cmds, nested...)
+			b.WriteString(string(p.src[start:p.pos]))
+			return nil
+		}
+		inner.WriteRune(c)
+		p.pos++
+	}
+	return fmt.Errorf("unterminated backtick substitution")
+}
+
+// indexFrom returns the index of the next occurrence of r at or after pos.
+func (p *shellParser) indexFrom(r rune) int {
+	for i := p.pos; i < len(p.src); i++ {
+		if p.src[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
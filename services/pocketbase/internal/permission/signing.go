@@ -0,0 +1,135 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Approval Signatures. Hashes permission requests and verifies device Ed25519 signatures over them.
+package permission
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// SignedRequest is the part of a permission record an approval signature
+// covers. Anything that changes what would run changes the hash.
+type SignedRequest struct {
+	OpencodeID string         `json:"ai_engine_permission_id"`
+	SessionID  string         `json:"session_id"`
+	ChatID     string         `json:"chat"`
+	Permission string         `json:"permission"`
+	Patterns   []string       `json:"patterns"`
+	Metadata   map[string]any `json:"metadata"`
+	MessageID  string         `json:"message_id"`
+	CallID     string         `json:"call_id"`
+}
+
+// RequestHash returns the hex SHA-256 of the request's canonical JSON
+// encoding (struct field order, map keys sorted).
+func RequestHash(r SignedRequest) string {
+	if r.Patterns == nil {
+		r.Patterns = []string{}
+	}
+	if r.Metadata == nil {
+		r.Metadata = map[string]any{}
+	}
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ApprovalMessage is the exact byte string a device signs to approve a
+// permission: "<challenge>.<request_hash>".
+func ApprovalMessage(challenge, requestHash string) []byte {
+	return []byte(challenge + "." + requestHash)
+}
+
+// ParsePublicKey decodes a base64 (standard or URL-safe) Ed25519 public key.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("public key is not valid base64: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// VerifyApproval checks a base64 Ed25519 signature over
+// ApprovalMessage(challenge, requestHash).
+func VerifyApproval(publicKey, signature, challenge, requestHash string) error {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeBase64(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return errors.New("signature is not a valid base64 Ed25519 signature")
+	}
+	if challenge == "" || requestHash == "" {
+		return errors.New("permission has no challenge to sign")
+	}
+	if !ed25519.Verify(key, ApprovalMessage(challenge, requestHash), sig) {
+		return errors.New("signature does not match the challenge and request")
+	}
+	return nil
+}
+
+// Approval signature modes, set with PERMISSION_SIGNATURES.
+const (
+	// SignaturesRequired rejects approvals without a valid device signature.
+	SignaturesRequired = "required"
+	// SignaturesOptional verifies a signature when one is given, and accepts
+	// unsigned approvals from clients that cannot sign yet.
+	SignaturesOptional = "optional"
+	// SignaturesOff ignores signatures entirely.
+	SignaturesOff = "off"
+)
+
+// SignatureMode returns the approval signature mode from
+// PERMISSION_SIGNATURES. It is optional when unset, and required for values
+// it does not recognize.
+func SignatureMode() string {
+	raw := strings.ToLower(strings.TrimSpace(os.Getenv("PERMISSION_SIGNATURES")))
+	switch raw {
+	case "":
+		return SignaturesOptional
+	case SignaturesRequired, SignaturesOptional, SignaturesOff:
+		return raw
+	default:
+		log.Printf("⚠️ [Authority] Unknown PERMISSION_SIGNATURES %q, requiring signatures", raw)
+		return SignaturesRequired
+	}
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if raw, err := enc.DecodeString(s); err == nil {
+			return raw, nil
+		}
+	}
+	return nil, errors.New("invalid base64")
+}
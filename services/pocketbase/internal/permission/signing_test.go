@@ -0,0 +1,48 @@
+package permission_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+func TestVerifyApproval(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := base64.StdEncoding.EncodeToString(pub)
+
+	req := permission.SignedRequest{
+		Permission: "bash",
+		Patterns:   []string{"rm -rf build"},
+		Metadata:   map[string]any{"command": "rm -rf build", "cwd": "/workspace"},
+	}
+	hash := permission.RequestHash(req)
+	if again := permission.RequestHash(req); again != hash {
+		t.Fatalf("RequestHash is not deterministic: %s != %s", hash, again)
+	}
+
+	sign := func(msg []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	}
+	good := sign(permission.ApprovalMessage("challenge-1", hash))
+
+	if err := permission.VerifyApproval(publicKey, good, "challenge-1", hash); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := permission.VerifyApproval(publicKey, good, "challenge-2", hash); err == nil {
+		t.Error("signature accepted for another challenge")
+	}
+
+	tampered := req
+	tampered.Patterns = []string{"rm -rf /"}
+	if err := permission.VerifyApproval(publicKey, good, "challenge-1", permission.RequestHash(tampered)); err == nil {
+		t.Error("signature accepted for tampered request")
+	}
+	if err := permission.VerifyApproval("not-a-key", good, "challenge-1", hash); err == nil {
+		t.Error("invalid public key accepted")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Rule Suggestions. Mines repeated human approvals for allow rules worth adding.
+package permission
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSuggestionExamples caps the example requests listed per suggestion.
+const maxSuggestionExamples = 5
+
+// HistoryEntry is a decided permission a suggestion is mined from or checked
+// against.
+type HistoryEntry struct {
+	ID string
+	// Input is the request as evaluated, with AgentID and Now set to the
+	// original agent and creation time.
+	Input EvaluationInput
+	// Request is the request rendered for display.
+	Request string
+	Status  string
+	// Human is set for authorized requests a person had to approve.
+	Human bool
+}
+
+// Suggestion is a generalized allow rule together with its effect on the
+// history it was mined from.
+type Suggestion struct {
+	AgentID string `json:"agent,omitempty"`
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern"`
+	// Covered counts the human approvals the rule would have made automatic.
+	Covered  int       `json:"covered"`
+	Examples []string  `json:"examples"`
+	LastSeen time.Time `json:"last_seen"`
+	// Denied are the denied requests the rule matches. Safe is false when
+	// the rule would have allowed any of them.
+	Denied []DeniedMatch `json:"denied"`
+	Safe   bool          `json:"safe"`
+}
+
+// DeniedMatch is a denied request matched by a suggested rule.
+type DeniedMatch struct {
+	ID      string `json:"id"`
+	Request string `json:"request"`
+	// WouldAllow is set when the suggested rule turns the deny into an allow;
+	// otherwise a more specific rule or a protected path still blocks it.
+	WouldAllow bool `json:"would_allow"`
+}
+
+// SuggestRules groups the human approvals in history by agent scope, tool and
+// request shape, and proposes one allow rule per group that covers at least
+// minCovered of them under policy. Suggestions are ordered by coverage.
+func SuggestRules(policy Policy, history []HistoryEntry, minCovered int) []Suggestion {
+	if minCovered < 1 {
+		minCovered = 1
+	}
+
+	type key struct{ agent, tool, pattern string }
+	counts := map[key]int{}
+	var keys []key
+	for _, h := range history {
+		if !h.Human {
+			continue
+		}
+		seen := map[key]bool{}
+		for _, s := range suggestedShapes(policy, h) {
+			k := key{s.AgentID, s.Tool, s.Pattern}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if counts[k] == 0 {
+				keys = append(keys, k)
+			}
+			counts[k]++
+		}
+	}
+
+	var suggestions []Suggestion
+	for _, k := range keys {
+		if counts[k] < minCovered {
+			continue
+		}
+		s := AssessRule(policy, history, Rule{AgentID: k.agent, Tool: k.tool, Pattern: k.pattern, Action: ActionAllow})
+		if s.Covered >= minCovered {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Covered != suggestions[j].Covered {
+			return suggestions[i].Covered > suggestions[j].Covered
+		}
+		return suggestions[i].LastSeen.After(suggestions[j].LastSeen)
+	})
+	return suggestions
+}
+
+// AssessRule replays history with rule added to policy: the human approvals
+// it would have made automatic, and the denied requests it matches.
+func AssessRule(policy Policy, history []HistoryEntry, rule Rule) Suggestion {
+	s := Suggestion{
+		AgentID:  rule.AgentID,
+		Tool:     rule.Tool,
+		Pattern:  rule.Pattern,
+		Examples: []string{},
+		Denied:   []DeniedMatch{},
+		Safe:     true,
+	}
+
+	proposed := policy
+	proposed.Rules = append(append([]Rule{}, policy.Rules...), rule)
+	examples := map[string]bool{}
+
+	for _, h := range history {
+		if !ruleTouches(policy, &rule, h.Input) {
+			continue
+		}
+		after := Resolve(proposed, h.Input)
+		switch {
+		case h.Human && h.Status == StatusAuthorized:
+			if after.Action != ActionAllow || Resolve(policy, h.Input).Action == ActionAllow {
+				continue
+			}
+			s.Covered++
+			if at := h.Input.Now; at.After(s.LastSeen) {
+				s.LastSeen = at
+			}
+			if !examples[h.Request] && len(examples) < maxSuggestionExamples {
+				examples[h.Request] = true
+				s.Examples = append(s.Examples, h.Request)
+			}
+		case h.Status == StatusDenied:
+			match := DeniedMatch{ID: h.ID, Request: h.Request, WouldAllow: after.Action == ActionAllow}
+			s.Denied = append(s.Denied, match)
+			if match.WouldAllow {
+				s.Safe = false
+			}
+		}
+	}
+	return s
+}
+
+// ruleTouches reports whether rule matches the tool and one of the subjects of
+// input.
+func ruleTouches(policy Policy, rule *Rule, input EvaluationInput) bool {
+	for _, tool := range policy.toolNames(input.Permission) {
+		in := input
+		in.Permission = tool
+		for _, sub := range subjectsFor(in) {
+			if rule.matchTool(sub.permission) && rule.matchSubject(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// suggestedShapes generalizes a request into candidate rules: bash commands
+// by program and subcommand (as remembered approvals are), paths by their
+// directory, URLs by their host, anything else by tool alone. The rule is
+// scoped to the request's agent when the agent has rules of its own for the
+// tool, since a global rule would be shadowed there.
+func suggestedShapes(policy Policy, h HistoryEntry) []Suggestion {
+	input := h.Input
+	tool := input.Permission
+	if names := policy.toolNames(tool); len(names) == 1 {
+		tool = names[0]
+	}
+
+	agentID := ""
+	if input.AgentID != "" {
+		if _, scope, _ := scopeRules(policy.Rules, tool, input.AgentID); scope == ScopeAgent {
+			agentID = input.AgentID
+		}
+	}
+
+	var patterns []string
+	switch {
+	case tool == "bash":
+		cmd, _ := input.Metadata["command"].(string)
+		if strings.TrimSpace(cmd) == "" && len(input.Patterns) == 1 {
+			cmd = input.Patterns[0]
+		}
+		commands, err := ParseShell(strings.TrimSpace(cmd))
+		if err != nil {
+			return nil
+		}
+		for _, c := range commands {
+			if p, err := CommandPattern(strings.Join(c.Words, " ")); err == nil {
+				patterns = append(patterns, p)
+			}
+		}
+	case IsPathTool(tool):
+		for _, p := range input.Patterns {
+			if p = NormalizePath(WorkspaceRoot, p); p != "" && p != "/" {
+				patterns = append(patterns, path.Join(path.Dir(p), "*"))
+			}
+		}
+	default:
+		for _, p := range input.Patterns {
+			if u, err := url.Parse(p); err == nil && u.Scheme != "" && u.Host != "" {
+				patterns = append(patterns, u.Scheme+"://"+u.Host+"/*")
+			}
+		}
+		if len(patterns) == 0 {
+			patterns = []string{"*"}
+		}
+	}
+
+	shapes := make([]Suggestion, 0, len(patterns))
+	for _, p := range patterns {
+		shapes = append(shapes, Suggestion{AgentID: agentID, Tool: tool, Pattern: p})
+	}
+	return shapes
+}
@@ -0,0 +1,65 @@
+package permission_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/permission"
+)
+
+func TestSuggestRules(t *testing.T) {
+	policy := permission.Policy{Rules: []permission.Rule{
+		{ID: "g1", Tool: "*", Pattern: "*", Action: permission.ActionAsk},
+		{ID: "g2", Tool: "bash", Pattern: "git push --force*", Action: permission.ActionDeny},
+		{ID: "g3", Tool: "bash", Pattern: "ls *", Action: permission.ActionAllow},
+	}}
+
+	var history []permission.HistoryEntry
+	add := func(status string, human bool, cmd string) {
+		history = append(history, permission.HistoryEntry{
+			ID:      fmt.Sprintf("p%d", len(history)),
+			Input:   permission.EvaluationInput{Permission: "bash", Metadata: map[string]any{"command": cmd}},
+			Request: cmd,
+			Status:  status,
+			Human:   human,
+		})
+	}
+	for _, cmd := range []string{"npm test", "npm test -- --watch", "npm test", "cd web && npm test"} {
+		add(permission.StatusAuthorized, true, cmd)
+	}
+	for _, cmd := range []string{"git push origin main", "git push", "git push origin dev"} {
+		add(permission.StatusAuthorized, true, cmd)
+	}
+	add(permission.StatusDenied, false, "git push --force origin main")
+	for _, cmd := range []string{"rm -rf build", "rm -rf dist", "rm -rf node_modules"} {
+		add(permission.StatusAuthorized, true, cmd)
+	}
+	add(permission.StatusDenied, false, "rm -rf src")
+	add(permission.StatusAuthorized, true, "ls -la")
+	add(permission.StatusAuthorized, false, "npm test")
+
+	got := map[string]permission.Suggestion{}
+	for _, s := range permission.SuggestRules(policy, history, 3) {
+		got[s.Tool+": "+s.Pattern] = s
+	}
+	if len(got) != 3 {
+		t.Fatalf("SuggestRules() = %v, want npm test, git push and rm", got)
+	}
+
+	// The compound command needs a rule for cd too, so it is not covered.
+	if s := got["bash: npm test *"]; s.Covered != 3 || !s.Safe || len(s.Examples) != 2 {
+		t.Errorf("npm test = %+v, want 3 covered, 2 distinct examples", s)
+	}
+	// The denied force push stays denied by the more specific rule.
+	if s := got["bash: git push *"]; s.Covered != 3 || !s.Safe || len(s.Denied) != 1 || s.Denied[0].WouldAllow {
+		t.Errorf("git push = %+v, want safe with one denied match", s)
+	}
+	if s := got["bash: rm *"]; s.Safe || len(s.Denied) != 1 || !s.Denied[0].WouldAllow {
+		t.Errorf("rm = %+v, want unsafe", s)
+	}
+
+	s := permission.AssessRule(policy, history, permission.Rule{Tool: "bash", Pattern: "rm -rf build*", Action: permission.ActionAllow})
+	if s.Covered != 1 || !s.Safe {
+		t.Errorf("AssessRule(rm -rf build*) = %+v, want 1 covered and safe", s)
+	}
+}
@@ -0,0 +1,325 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Secret Redactor. Detects credentials in text and masks them before they are stored or pushed.
+package redact
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Secret is a credential found in a text.
+type Secret struct {
+	Kind  string `json:"kind"`
+	Value string `json:"-"`
+}
+
+// Secret kinds that do not come from a known key format.
+const (
+	KindKnown   = "known"   // a value configured in PocketBase
+	KindEntropy = "entropy" // a long random-looking token
+	KindNamed   = "named"   // the value of a secret-looking variable, flag or field
+)
+
+// MinKnownLength is the shortest configured value that is treated as a
+// secret, so flags like "true" in a config never mask anything.
+const MinKnownLength = 8
+
+// Mask is the replacement for a secret of the given kind.
+func Mask(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// formats are known key formats, matched anywhere.
+var formats = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"private_key", regexp.MustCompile(`-----BEGIN[ A-Z0-9]*PRIVATE KEY-----[\s\S]*?(?:-----END[ A-Z0-9]*PRIVATE KEY-----|$)`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"anthropic_key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openai_key", regexp.MustCompile(`\bsk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{20,}`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"stripe_key", regexp.MustCompile(`\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+}
+
+// contexts find secrets by what surrounds them; the first group is the secret.
+// Values starting with $ are references, not secrets.
+var contexts = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"authorization", regexp.MustCompile(`(?i)\b(?:proxy-)?authorization\s*:\s*(?:(?:bearer|basic|token|bot)\s+)?([^\s'"$][^\s'"]*)`)},
+	{"url_credentials", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@'"]+:([^\s@/'"]+)@`)},
+	{KindNamed, regexp.MustCompile(`(?i)\b[a-z0-9_]*` + secretName + `[a-z0-9_]*["']?\s*[:=]\s*["']?([^\s'"$,;}][^\s'",;}]*)`)},
+	{KindNamed, regexp.MustCompile(`(?i)(?:^|\s)--?(?:api-?key|access-?key|secret(?:-key)?|token|auth-?token|password|passwd)(?:=|\s+)["']?([^\s'"$-][^\s'"]*)`)},
+}
+
+// secretName matches the names of variables and fields that hold secrets.
+const secretName = `(?:api_?key|access_?key|secret|token|password|passwd|pwd|credentials?|private_?key)`
+
+var secretKey = regexp.MustCompile(`(?i)` + secretName)
+
+// entropyToken is a run of characters random tokens are made of.
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+_-]{20,}`)
+
+// Redactor finds and masks secrets. The zero value knows the built-in
+// formats only.
+type Redactor struct {
+	known []string // longest first
+}
+
+// New returns a redactor that also treats the given values as secrets.
+// Values shorter than MinKnownLength are ignored.
+func New(known ...string) *Redactor {
+	r := &Redactor{}
+	seen := map[string]bool{}
+	for _, v := range known {
+		if len(v) >= MinKnownLength && !seen[v] {
+			seen[v] = true
+			r.known = append(r.known, v)
+		}
+	}
+	sort.Slice(r.known, func(i, j int) bool { return len(r.known[i]) > len(r.known[j]) })
+	return r
+}
+
+type span struct {
+	start, end int
+	kind       string
+}
+
+// find returns the non-overlapping secret spans of s, in order.
+func (r *Redactor) find(s string) []span {
+	var spans []span
+	for _, v := range r.known {
+		for i := 0; ; {
+			j := strings.Index(s[i:], v)
+			if j < 0 {
+				break
+			}
+			spans = append(spans, span{i + j, i + j + len(v), KindKnown})
+			i += j + len(v)
+		}
+	}
+	for _, f := range formats {
+		for _, m := range f.re.FindAllStringIndex(s, -1) {
+			spans = append(spans, span{m[0], m[1], f.kind})
+		}
+	}
+	for _, c := range contexts {
+		for _, m := range c.re.FindAllStringSubmatchIndex(s, -1) {
+			if plausibleSecret(s[m[2]:m[3]]) {
+				spans = append(spans, span{m[2], m[3], c.kind})
+			}
+		}
+	}
+	for _, m := range entropyToken.FindAllStringIndex(s, -1) {
+		if randomLooking(s[m[0]:m[1]]) {
+			spans = append(spans, span{m[0], m[1], KindEntropy})
+		}
+	}
+
+	// Earlier spans win, then longer ones; known values and formats were
+	// added first, so they name a secret a context also found.
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	var out []span
+	for _, sp := range spans {
+		if n := len(out); n > 0 && sp.start < out[n-1].end {
+			if sp.end > out[n-1].end {
+				out[n-1].end = sp.end
+			}
+			continue
+		}
+		out = append(out, sp)
+	}
+	return out
+}
+
+// Find returns the secrets in s.
+func (r *Redactor) Find(s string) []Secret {
+	var secrets []Secret
+	for _, sp := range r.find(s) {
+		secrets = append(secrets, Secret{Kind: sp.kind, Value: s[sp.start:sp.end]})
+	}
+	return secrets
+}
+
+// String masks every secret in s.
+func (r *Redactor) String(s string) string {
+	masked, _ := r.redactString(s)
+	return masked
+}
+
+func (r *Redactor) redactString(s string) (string, []Secret) {
+	spans := r.find(s)
+	if len(spans) == 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	var secrets []Secret
+	last := 0
+	for _, sp := range spans {
+		b.WriteString(s[last:sp.start])
+		b.WriteString(Mask(sp.kind))
+		secrets = append(secrets, Secret{Kind: sp.kind, Value: s[sp.start:sp.end]})
+		last = sp.end
+	}
+	b.WriteString(s[last:])
+	return b.String(), secrets
+}
+
+// Value masks the secrets in every string of a decoded JSON value (maps,
+// slices and strings, at any depth). String values under secret-looking keys
+// such as "api_key" are masked whole. It returns the masked copy and the
+// secrets it removed.
+func (r *Redactor) Value(v any) (any, []Secret) {
+	var secrets []Secret
+	var walk func(key string, v any) any
+	walk = func(key string, v any) any {
+		switch t := v.(type) {
+		case string:
+			if key != "" && secretKey.MatchString(key) && !strings.HasPrefix(t, "$") && plausibleSecret(t) {
+				secrets = append(secrets, Secret{Kind: KindNamed, Value: t})
+				return Mask(KindNamed)
+			}
+			masked, found := r.redactString(t)
+			secrets = append(secrets, found...)
+			return masked
+		case []string:
+			out := make([]string, len(t))
+			for i, s := range t {
+				out[i] = walk("", s).(string)
+			}
+			return out
+		case []any:
+			out := make([]any, len(t))
+			for i, e := range t {
+				out[i] = walk("", e)
+			}
+			return out
+		case map[string]any:
+			out := make(map[string]any, len(t))
+			for k, e := range t {
+				out[k] = walk(k, e)
+			}
+			return out
+		default:
+			return v
+		}
+	}
+	return walk("", v), secrets
+}
+
+// Replace masks the given secrets wherever they occur in the strings of a
+// decoded JSON value, for text derived from a redacted request (such as a
+// decision trace). Secrets shorter than four characters are left alone there,
+// as replacing them would mangle unrelated text.
+func Replace(v any, secrets []Secret) any {
+	secrets = append([]Secret(nil), secrets...)
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i].Value) > len(secrets[j].Value) })
+	var pairs []string
+	for _, s := range secrets {
+		if len(s.Value) >= 4 {
+			pairs = append(pairs, s.Value, Mask(s.Kind))
+		}
+	}
+	if len(pairs) == 0 {
+		return v
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch t := v.(type) {
+		case string:
+			return replacer.Replace(t)
+		case []any:
+			out := make([]any, len(t))
+			for i, e := range t {
+				out[i] = walk(e)
+			}
+			return out
+		case map[string]any:
+			out := make(map[string]any, len(t))
+			for k, e := range t {
+				out[k] = walk(e)
+			}
+			return out
+		default:
+			return v
+		}
+	}
+	return walk(v)
+}
+
+// plausibleSecret filters out values a secret-looking name often holds
+// that are not secrets, such as `max_tokens=100` or `verify_token=false`.
+func plausibleSecret(v string) bool {
+	if v == "" || strings.Trim(v, "0123456789") == "" {
+		return false
+	}
+	switch strings.ToLower(v) {
+	case "true", "false", "null", "none", "yes", "no":
+		return false
+	}
+	return true
+}
+
+// randomLooking reports whether a token looks like a random credential:
+// letters and digits mixed with high per-character entropy. Hex strings
+// (hashes, commit IDs) never qualify.
+func randomLooking(token string) bool {
+	var letters, digits, hex int
+	counts := map[rune]int{}
+	for _, c := range token {
+		counts[c]++
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+			hex++
+		case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+			letters++
+			hex++
+		case c >= 'g' && c <= 'z' || c >= 'G' && c <= 'Z':
+			letters++
+		}
+	}
+	if letters == 0 || digits == 0 || hex+strings.Count(token, "-") == len(token) {
+		return false
+	}
+
+	var entropy float64
+	n := float64(len(token))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= 4.0
+}
@@ -0,0 +1,330 @@
+/*
+PocketCoder: An accessible, secure, and user-friendly open-source coding assistant platform.
+Copyright (C) 2026 Qtpi Bonding LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// @pocketcoder-core: Cron Schedules. Parses cron expressions with precise errors and previews their next runs.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/cron"
+)
+
+// ErrInvalid is wrapped by every parse error.
+var ErrInvalid = errors.New("invalid cron expression")
+
+// MaxPreview caps the number of fire times Preview returns.
+const MaxPreview = 50
+
+// maxSearchDays bounds the search for the next fire time. Every valid
+// month/day pair falls on every weekday within 28 years.
+const maxSearchDays = 29 * 366
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes one of the five cron fields.
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day-of-month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day-of-week", min: 0, max: 6, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// daysInMonth is the most days each month can have.
+var daysInMonth = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// Schedule is a parsed cron expression.
+//
+// The grammar is the one PocketBase's scheduler accepts: five
+// space-separated fields (minute, hour, day of month, month, day of week) or
+// a macro (@hourly, @daily, @weekly, @monthly, @yearly). A field is a
+// comma-separated list of `*`, `n` or `n-m`, the last two optionally with a
+// `/step`. Month and weekday names (jan, mon) are accepted too. As in
+// PocketBase, a time must match the day of month and the day of week alike.
+type Schedule struct {
+	expr  string
+	slots [5][]bool
+}
+
+// Parse parses expr. The error names the offending field and value.
+func Parse(expr string) (*Schedule, error) {
+	source := strings.TrimSpace(expr)
+	if source == "" {
+		return nil, fmt.Errorf("%w: expression is empty", ErrInvalid)
+	}
+	if strings.HasPrefix(source, "@") {
+		v, ok := macros[strings.ToLower(source)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown macro %q (use @hourly, @daily, @weekly, @monthly or @yearly)", ErrInvalid, source)
+		}
+		source = v
+	}
+
+	segments := strings.Fields(source)
+	if len(segments) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields (minute hour day-of-month month day-of-week), got %d", ErrInvalid, len(segments))
+	}
+
+	s := &Schedule{}
+	normalized := make([]string, 5)
+	for i, seg := range segments {
+		slots, norm, err := parseField(fields[i], seg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s field %q: %s", ErrInvalid, fields[i].name, seg, err)
+		}
+		s.slots[i] = slots
+		normalized[i] = norm
+	}
+	s.expr = strings.Join(normalized, " ")
+
+	if !s.anyDate() {
+		return nil, fmt.Errorf("%w: %q never fires (no month has such a day)", ErrInvalid, expr)
+	}
+	// The normalized form is what the scheduler gets; it must agree.
+	if _, err := cron.NewSchedule(s.expr); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	return s, nil
+}
+
+// Validate reports whether expr parses.
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+// String returns the normalized expression: single spaces, numbers instead
+// of names, macros expanded.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Due reports whether the schedule fires in the minute of t, with the
+// expression read in t's location.
+func (s *Schedule) Due(t time.Time) bool {
+	t = t.Truncate(time.Minute)
+	next, ok := s.Next(t.Add(-time.Minute))
+	return ok && next.Equal(t)
+}
+
+// Next returns the first fire time strictly after after, with the expression
+// read in after's location. ok is false if there is none within
+// maxSearchDays.
+//
+// Daylight saving transitions are handled the same way every time: a wall
+// time skipped by a spring-forward transition fires shifted forward by the
+// length of the gap (02:30 fires at 03:30 when 02:00 jumps to 03:00), and a
+// wall time repeated by a fall-back transition fires only at its first
+// occurrence.
+func (s *Schedule) Next(after time.Time) (next time.Time, ok bool) {
+	loc := after.Location()
+	y, m, d := after.Date()
+
+	// Fire times do not follow wall-clock order across a gap, so the day
+	// before is searched too, and the day after the first hit.
+	found := -1
+	for i := -1; i < maxSearchDays; i++ {
+		if found >= 0 && i > found+1 {
+			break
+		}
+		date := time.Date(y, m, d+i, 0, 0, 0, 0, time.UTC)
+		if !s.matchDate(date) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if !s.slots[1][h] {
+				continue
+			}
+			for mi := 0; mi < 60; mi++ {
+				if !s.slots[0][mi] {
+					continue
+				}
+				t := resolve(date.Year(), date.Month(), date.Day(), h, mi, loc)
+				if t.After(after) && (found < 0 || t.Before(next)) {
+					next, found = t, i
+				}
+			}
+		}
+	}
+	return next, found >= 0
+}
+
+// Preview returns the next n fire times after from, capped at MaxPreview.
+func (s *Schedule) Preview(from time.Time, n int) []time.Time {
+	if n > MaxPreview {
+		n = MaxPreview
+	}
+	runs := make([]time.Time, 0, n)
+	for len(runs) < n {
+		next, ok := s.Next(from)
+		if !ok {
+			break
+		}
+		runs = append(runs, next)
+		from = next
+	}
+	return runs
+}
+
+// resolve returns the instant a wall time in loc fires at: its first
+// occurrence, or for a wall time in a spring-forward gap, the wall time read
+// with the offset from before the gap.
+func resolve(year int, month time.Month, day, hour, minute int, loc *time.Location) time.Time {
+	wall := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	// Any transition near wall lies between these two offsets.
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+
+	var first time.Time
+	for _, offset := range []int{before, after} {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if sameWall(t, wall) && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if first.IsZero() {
+		return wall.Add(-time.Duration(before) * time.Second).In(loc)
+	}
+	return first
+}
+
+// sameWall reports whether t reads as the wall time wall, given in UTC.
+func sameWall(t, wall time.Time) bool {
+	y, m, d := t.Date()
+	wy, wm, wd := wall.Date()
+	return y == wy && m == wm && d == wd && t.Hour() == wall.Hour() && t.Minute() == wall.Minute()
+}
+
+func (s *Schedule) matchDate(t time.Time) bool {
+	return s.slots[2][t.Day()] && s.slots[3][int(t.Month())] && s.slots[4][int(t.Weekday())]
+}
+
+// anyDate reports whether some month in the schedule has one of its days.
+func (s *Schedule) anyDate() bool {
+	for m := 1; m <= 12; m++ {
+		if !s.slots[3][m] {
+			continue
+		}
+		for d := 1; d <= daysInMonth[m]; d++ {
+			if s.slots[2][d] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var nameToken = regexp.MustCompile(`[A-Za-z]+`)
+
+// parseField parses one field into its slots and its normalized text.
+func parseField(f field, seg string) ([]bool, string, error) {
+	var nameErr error
+	seg = nameToken.ReplaceAllStringFunc(seg, func(name string) string {
+		v, ok := f.names[strings.ToLower(name)]
+		if !ok {
+			if nameErr == nil {
+				nameErr = fmt.Errorf("unknown name %q", name)
+			}
+			return name
+		}
+		return strconv.Itoa(v)
+	})
+	if nameErr != nil {
+		return nil, "", nameErr
+	}
+
+	slots := make([]bool, f.max+1)
+	for _, part := range strings.Split(seg, ",") {
+		if part == "" {
+			return nil, "", errors.New("empty list item")
+		}
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 || n > f.max {
+				return nil, "", fmt.Errorf("step %q must be a number from 1 to %d", stepText, f.max)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loText); err != nil {
+				return nil, "", err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiText); err != nil {
+					return nil, "", err
+				}
+				if hi < lo {
+					return nil, "", fmt.Errorf("range %d-%d runs backwards", lo, hi)
+				}
+			} else if hasStep {
+				return nil, "", fmt.Errorf("a step needs * or a range, as in */%d or %d-%d/%d", step, lo, f.max, step)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			slots[v] = true
+		}
+	}
+	return slots, seg, nil
+}
+
+// value parses a single number of f.
+func (f field) value(text string) (int, error) {
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	if v < f.min || v > f.max {
+		if f.name == "day-of-week" && v == 7 {
+			return 0, errors.New("7 is out of range 0-6 (use 0 for Sunday)")
+		}
+		return 0, fmt.Errorf("%d is out of range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
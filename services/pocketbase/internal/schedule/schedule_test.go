@@ -0,0 +1,118 @@
+package schedule_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qtpi-automaton/pocketcoder/backend/internal/schedule"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]string{
+		"0 9 * * 1-5":        "0 9 * * 1-5",
+		"0  9 * *   MON-FRI": "0 9 * * 1-5",
+		"*/15 * * jan,jul *": "*/15 * * 1,7 *",
+		"@daily":             "0 0 * * *",
+		"30 8 1-7/2 * *":     "30 8 1-7/2 * *",
+		"0 0 29 2 *":         "0 0 29 2 *",
+	}
+	for expr, want := range valid {
+		s, err := schedule.Parse(expr)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", expr, err)
+			continue
+		}
+		if s.String() != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", expr, s.String(), want)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                "empty",
+		"0 9 * *":         "expected 5 fields",
+		"60 * * * *":      `minute field "60": 60 is out of range 0-59`,
+		"0 25 * * *":      `hour field "25"`,
+		"0 9 * * 7":       "use 0 for Sunday",
+		"0 9 * * mon-fry": `unknown name "fry"`,
+		"0 9 5/2 * *":     "a step needs * or a range",
+		"*/0 * * * *":     "step",
+		"0 9 10-1 * *":    "runs backwards",
+		"0 0 31 2 *":      "never fires",
+		"@sometimes":      "unknown macro",
+		"0 9 1,,2 * *":    "empty list item",
+	}
+	for expr, want := range invalid {
+		_, err := schedule.Parse(expr)
+		if !errors.Is(err, schedule.ErrInvalid) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want it to mention %q", expr, err, want)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	s, err := schedule.Parse("0 9 * * mon-fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Friday 2026-10-16 10:00 UTC: the next runs skip the weekend.
+	from := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	got := s.Preview(from, 3)
+	want := []string{"2026-10-19T09:00:00Z", "2026-10-20T09:00:00Z", "2026-10-21T09:00:00Z"}
+	for i := range want {
+		if i >= len(got) || got[i].Format(time.RFC3339) != want[i] {
+			t.Fatalf("Preview() = %v, want %v", got, want)
+		}
+	}
+	if !s.Due(got[0]) || s.Due(from) {
+		t.Errorf("Due() disagrees with Preview()")
+	}
+
+	leap, _ := schedule.Parse("0 0 29 2 1")
+	if next, ok := leap.Next(from); !ok || next.Format("2006-01-02") != "2044-02-29" {
+		t.Errorf("Next(Feb 29 on a Monday) = %v, %v", next, ok)
+	}
+}
+
+func TestPreviewDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+
+	cases := []struct {
+		expr string
+		from time.Time
+		want []string
+	}{
+		// 02:00 jumps to 03:00 on 2026-03-29: 02:30 fires at 03:30.
+		{"30 2 * * *", time.Date(2026, 3, 28, 12, 0, 0, 0, berlin),
+			[]string{"2026-03-29T03:30:00+02:00", "2026-03-30T02:30:00+02:00"}},
+		// 03:00 falls back to 02:00 on 2026-10-25: 02:30 fires once.
+		{"30 2 * * *", time.Date(2026, 10, 24, 12, 0, 0, 0, berlin),
+			[]string{"2026-10-25T02:30:00+02:00", "2026-10-26T02:30:00+01:00"}},
+		// Every 30 minutes across the gap: shifted times merge with real ones.
+		{"*/30 1-3 29 3 *", time.Date(2026, 3, 29, 1, 10, 0, 0, berlin),
+			[]string{"2026-03-29T01:30:00+01:00", "2026-03-29T03:00:00+02:00", "2026-03-29T03:30:00+02:00", "2027-03-29T01:00:00+02:00"}},
+		// 9am stays 9am local on both sides of the change.
+		{"0 9 * * *", time.Date(2026, 10, 24, 12, 0, 0, 0, berlin),
+			[]string{"2026-10-25T09:00:00+01:00", "2026-10-26T09:00:00+01:00"}},
+	}
+	for _, c := range cases {
+		s, err := schedule.Parse(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range s.Preview(c.from, len(c.want)) {
+			got = append(got, r.Format(time.RFC3339))
+			if !s.Due(r.UTC().In(berlin)) {
+				t.Errorf("%s: Due(%s) = false", c.expr, r)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%s from %s: Preview() = %v, want %v", c.expr, c.from, got, c.want)
+		}
+	}
+}
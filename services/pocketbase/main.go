@@ -80,6 +80,7 @@ func main() {
 		api.RegisterProxyApi(app, e)
 		api.RegisterLogsApi(app, e)
 		api.RegisterCronApi(app, e)
+		api.RegisterPolicyApi(app, e)
 		filesystem.RegisterArtifactApi(app, e)
 		hooks.RegisterPushApi(app, e)
 
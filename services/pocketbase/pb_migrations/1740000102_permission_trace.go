@@ -0,0 +1,20 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Decision trace produced by the permission evaluator for each audit record.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("trace"); f == nil {
+			permissions.Fields.Add(&core.JSONField{Name: "trace", MaxSize: 1048576})
+		}
+		return app.Save(permissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
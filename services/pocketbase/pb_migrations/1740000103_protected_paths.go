@@ -0,0 +1,40 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// =========================================================================
+		// PROTECTED PATHS (admin-extendable list on top of the built-in one)
+		// =========================================================================
+		protectedPaths, _ := app.FindCollectionByNameOrId("protected_paths")
+		if protectedPaths == nil {
+			protectedPaths = core.NewCollection(core.CollectionTypeBase, "protected_paths")
+			protectedPaths.Id = "pc_protected_paths"
+		}
+		for _, f := range []core.Field{
+			&core.TextField{Name: "pattern", Required: true},
+			&core.SelectField{Name: "action", Required: true, MaxSelect: 1, Values: []string{"ask", "deny"}},
+			&core.TextField{Name: "reason"},
+			&core.BoolField{Name: "active"},
+		} {
+			if protectedPaths.Fields.GetByName(f.GetName()) == nil {
+				protectedPaths.Fields.Add(f)
+			}
+		}
+		protectedPaths.ListRule = ptr("@request.auth.id != ''")
+		protectedPaths.ViewRule = ptr("@request.auth.id != ''")
+		protectedPaths.CreateRule = ptr("@request.auth.role = 'admin'")
+		protectedPaths.UpdateRule = ptr("@request.auth.role = 'admin'")
+		protectedPaths.DeleteRule = ptr("@request.auth.role = 'admin'")
+		protectedPaths.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_protected_paths_pattern ON protected_paths (pattern)",
+		}
+		return app.Save(protectedPaths)
+	}, func(app core.App) error {
+		return nil
+	})
+}
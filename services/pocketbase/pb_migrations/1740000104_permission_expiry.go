@@ -0,0 +1,31 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Drafts expire and are auto-denied; the reason records why.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("expires_at"); f == nil {
+			permissions.Fields.Add(&core.DateField{Name: "expires_at"})
+		}
+		if f := permissions.Fields.GetByName("decision_reason"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "decision_reason"})
+		}
+		if err := app.Save(permissions); err != nil { return err }
+
+		// Optional per-rule draft TTL, in seconds.
+		toolPermissions, err := app.FindCollectionByNameOrId("tool_permissions")
+		if err != nil { return err }
+		if f := toolPermissions.Fields.GetByName("draft_ttl"); f == nil {
+			toolPermissions.Fields.Add(&core.NumberField{Name: "draft_ttl"})
+		}
+		return app.Save(toolPermissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
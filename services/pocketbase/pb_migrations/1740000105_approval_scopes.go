@@ -0,0 +1,38 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// "Approve and remember": how widely an approval applies.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("approval_scope"); f == nil {
+			permissions.Fields.Add(&core.SelectField{Name: "approval_scope", MaxSelect: 1, Values: []string{"once", "chat", "agent", "always"}})
+		}
+		if f := permissions.Fields.GetByName("approval_pattern"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "approval_pattern"})
+		}
+		if err := app.Save(permissions); err != nil { return err }
+
+		// Rules remembered for a single chat, and the approval a rule came from.
+		chats, err := app.FindCollectionByNameOrId("chats")
+		if err != nil { return err }
+		toolPermissions, err := app.FindCollectionByNameOrId("tool_permissions")
+		if err != nil { return err }
+		if f := toolPermissions.Fields.GetByName("chat"); f == nil {
+			toolPermissions.Fields.Add(&core.RelationField{Name: "chat", CollectionId: chats.Id, MaxSelect: 1, CascadeDelete: true})
+		}
+		if f := toolPermissions.Fields.GetByName("source_permission"); f == nil {
+			toolPermissions.Fields.Add(&core.RelationField{Name: "source_permission", CollectionId: permissions.Id, MaxSelect: 1})
+		}
+		toolPermissions.RemoveIndex("idx_tool_perms_agent_tool_pattern")
+		toolPermissions.AddIndex("idx_tool_perms_scope_tool_pattern", true, "agent, chat, tool, pattern", "")
+		return app.Save(toolPermissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
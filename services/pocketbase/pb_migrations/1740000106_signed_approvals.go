@@ -0,0 +1,34 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Ed25519 public key (base64) used to sign permission approvals.
+		devices, err := app.FindCollectionByNameOrId("devices")
+		if err != nil { return err }
+		if f := devices.Fields.GetByName("public_key"); f == nil {
+			devices.Fields.Add(&core.TextField{Name: "public_key"})
+		}
+		if err := app.Save(devices); err != nil { return err }
+
+		// Approvals carry a device signature over "<challenge>.<request_hash>".
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("request_hash"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "request_hash"})
+		}
+		if f := permissions.Fields.GetByName("signature"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "signature"})
+		}
+		if f := permissions.Fields.GetByName("signed_device"); f == nil {
+			permissions.Fields.Add(&core.RelationField{Name: "signed_device", CollectionId: devices.Id, MaxSelect: 1})
+		}
+		return app.Save(permissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,26 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Risk assessment of each request, used for notification priority.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("risk_score"); f == nil {
+			permissions.Fields.Add(&core.NumberField{Name: "risk_score", OnlyInt: true})
+		}
+		if f := permissions.Fields.GetByName("risk_level"); f == nil {
+			permissions.Fields.Add(&core.SelectField{Name: "risk_level", MaxSelect: 1, Values: []string{"low", "medium", "high", "critical"}})
+		}
+		if f := permissions.Fields.GetByName("risk_reasons"); f == nil {
+			permissions.Fields.Add(&core.JSONField{Name: "risk_reasons"})
+		}
+		return app.Save(permissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Approver groups, for rules that need a quorum from a named group.
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil { return err }
+		if f := users.Fields.GetByName("groups"); f == nil {
+			users.Fields.Add(&core.JSONField{Name: "groups"})
+		}
+		if err := app.Save(users); err != nil { return err }
+
+		// Rules can require N distinct approvers, optionally from a group.
+		toolPerms, err := app.FindCollectionByNameOrId("tool_permissions")
+		if err != nil { return err }
+		if f := toolPerms.Fields.GetByName("quorum"); f == nil {
+			toolPerms.Fields.Add(&core.NumberField{Name: "quorum", OnlyInt: true})
+		}
+		if f := toolPerms.Fields.GetByName("quorum_group"); f == nil {
+			toolPerms.Fields.Add(&core.TextField{Name: "quorum_group"})
+		}
+		if err := app.Save(toolPerms); err != nil { return err }
+
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("quorum"); f == nil {
+			permissions.Fields.Add(&core.NumberField{Name: "quorum", OnlyInt: true})
+		}
+		if f := permissions.Fields.GetByName("quorum_group"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "quorum_group"})
+		}
+		if f := permissions.Fields.GetByName("approvals"); f == nil {
+			permissions.Fields.Add(&core.NumberField{Name: "approvals", OnlyInt: true})
+		}
+		if err := app.Save(permissions); err != nil { return err }
+
+		devices, err := app.FindCollectionByNameOrId("devices")
+		if err != nil { return err }
+
+		// One row per approver of a quorum permission. Written by the backend only.
+		approvals, _ := app.FindCollectionByNameOrId("permission_approvals")
+		if approvals == nil {
+			approvals = core.NewBaseCollection("permission_approvals")
+			approvals.Id = "pc_permission_approvals"
+		}
+		if f := approvals.Fields.GetByName("permission"); f == nil {
+			approvals.Fields.Add(
+				&core.RelationField{Name: "permission", Required: true, CollectionId: permissions.Id, MaxSelect: 1, CascadeDelete: true},
+				&core.RelationField{Name: "user", Required: true, CollectionId: users.Id, MaxSelect: 1},
+				&core.RelationField{Name: "device", CollectionId: devices.Id, MaxSelect: 1},
+				&core.TextField{Name: "signature"},
+				&core.AutodateField{Name: "created", OnCreate: true},
+			)
+		}
+		approvals.ListRule = ptr("@request.auth.id != ''")
+		approvals.ViewRule = ptr("@request.auth.id != ''")
+		approvals.CreateRule = nil
+		approvals.UpdateRule = nil
+		approvals.DeleteRule = nil
+		approvals.AddIndex("idx_permission_approvals_permission_user", true, "permission, user", "")
+		return app.Save(approvals)
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		cronJobs, err := app.FindCollectionByNameOrId("cron_jobs")
+		if err != nil { return err }
+
+		// Conditions a rule's allow or deny depends on; outside them it asks.
+		toolPerms, err := app.FindCollectionByNameOrId("tool_permissions")
+		if err != nil { return err }
+		if f := toolPerms.Fields.GetByName("weekdays"); f == nil {
+			toolPerms.Fields.Add(&core.JSONField{Name: "weekdays"})
+		}
+		if f := toolPerms.Fields.GetByName("hours"); f == nil {
+			toolPerms.Fields.Add(&core.TextField{Name: "hours", Pattern: `^\d{2}:\d{2}-\d{2}:\d{2}$`})
+		}
+		if f := toolPerms.Fields.GetByName("timezone"); f == nil {
+			toolPerms.Fields.Add(&core.TextField{Name: "timezone"})
+		}
+		if f := toolPerms.Fields.GetByName("cron_job"); f == nil {
+			toolPerms.Fields.Add(&core.RelationField{Name: "cron_job", CollectionId: cronJobs.Id, MaxSelect: 1, CascadeDelete: true})
+		}
+		if f := toolPerms.Fields.GetByName("max_per_hour"); f == nil {
+			toolPerms.Fields.Add(&core.NumberField{Name: "max_per_hour", OnlyInt: true})
+		}
+		if err := app.Save(toolPerms); err != nil { return err }
+
+		// Chats created by a cron job run, so rules can be limited to that job.
+		chats, err := app.FindCollectionByNameOrId("chats")
+		if err != nil { return err }
+		if f := chats.Fields.GetByName("cron_job"); f == nil {
+			chats.Fields.Add(&core.RelationField{Name: "cron_job", CollectionId: cronJobs.Id, MaxSelect: 1})
+		}
+		return app.Save(chats)
+	}, func(app core.App) error {
+		return nil
+	})
+}
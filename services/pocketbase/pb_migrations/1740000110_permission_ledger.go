@@ -0,0 +1,28 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Hash chain over decided permissions. The ledger is append-only.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("seq"); f == nil {
+			permissions.Fields.Add(&core.NumberField{Name: "seq", OnlyInt: true})
+		}
+		if f := permissions.Fields.GetByName("prev_hash"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "prev_hash"})
+		}
+		if f := permissions.Fields.GetByName("entry_hash"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "entry_hash"})
+		}
+		permissions.AddIndex("idx_permissions_seq", true, "seq", "seq > 0")
+		permissions.DeleteRule = nil
+		return app.Save(permissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,25 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// One permission per OpenCode request: retries find the existing record.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("idempotency_key"); f == nil {
+			permissions.Fields.Add(&core.TextField{Name: "idempotency_key"})
+		}
+		// Integrations without an OpenCode permission ID key on call_id instead.
+		if f, ok := permissions.Fields.GetByName("ai_engine_permission_id").(*core.TextField); ok {
+			f.Required = false
+		}
+		permissions.AddIndex("idx_permissions_idempotency_key", true, "idempotency_key", "idempotency_key != ''")
+		return app.Save(permissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
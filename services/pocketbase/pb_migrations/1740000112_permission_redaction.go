@@ -0,0 +1,20 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Kinds of secrets masked out of a permission before it was stored.
+		permissions, err := app.FindCollectionByNameOrId("permissions")
+		if err != nil { return err }
+		if f := permissions.Fields.GetByName("redacted"); f == nil {
+			permissions.Fields.Add(&core.JSONField{Name: "redacted"})
+		}
+		return app.Save(permissions)
+	}, func(app core.App) error {
+		return nil
+	})
+}
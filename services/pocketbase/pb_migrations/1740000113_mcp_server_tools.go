@@ -0,0 +1,21 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Tools an MCP server exposes, recorded when it is approved so that
+		// tool_permissions can gate them one by one (mcp:<server>/<tool>).
+		servers, err := app.FindCollectionByNameOrId("mcp_servers")
+		if err != nil { return err }
+		if f := servers.Fields.GetByName("tools"); f == nil {
+			servers.Fields.Add(&core.JSONField{Name: "tools"})
+		}
+		return app.Save(servers)
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// One row per cron job execution. Written by the backend only.
+		cronJobs, err := app.FindCollectionByNameOrId("cron_jobs")
+		if err != nil { return err }
+		chats, err := app.FindCollectionByNameOrId("chats")
+		if err != nil { return err }
+		messages, err := app.FindCollectionByNameOrId("messages")
+		if err != nil { return err }
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil { return err }
+
+		runs, _ := app.FindCollectionByNameOrId("cron_runs")
+		if runs == nil {
+			runs = core.NewBaseCollection("cron_runs")
+			runs.Id = "pc_cron_runs"
+		}
+		if f := runs.Fields.GetByName("job"); f == nil {
+			runs.Fields.Add(
+				&core.RelationField{Name: "job", Required: true, CollectionId: cronJobs.Id, MaxSelect: 1, CascadeDelete: true},
+				&core.RelationField{Name: "user", Required: true, CollectionId: users.Id, MaxSelect: 1},
+				&core.SelectField{Name: "trigger", Required: true, MaxSelect: 1, Values: []string{"schedule"}},
+				&core.DateField{Name: "triggered_at", Required: true},
+				&core.RelationField{Name: "chat", CollectionId: chats.Id, MaxSelect: 1},
+				&core.RelationField{Name: "message", CollectionId: messages.Id, MaxSelect: 1},
+				&core.RelationField{Name: "reply", CollectionId: messages.Id, MaxSelect: 1},
+				&core.SelectField{Name: "status", Required: true, MaxSelect: 1, Values: []string{"running", "completed", "failed", "aborted"}},
+				&core.TextField{Name: "error"},
+				&core.DateField{Name: "finished_at"},
+				&core.NumberField{Name: "duration_ms", OnlyInt: true},
+				&core.AutodateField{Name: "created", OnCreate: true},
+				&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+			)
+		}
+		runs.ListRule = ptr("@request.auth.id != '' && (user = @request.auth.id || @request.auth.role = 'admin')")
+		runs.ViewRule = ptr("@request.auth.id != '' && (user = @request.auth.id || @request.auth.role = 'admin')")
+		runs.CreateRule = nil
+		runs.UpdateRule = nil
+		runs.DeleteRule = nil
+		runs.AddIndex("idx_cron_runs_job_triggered_at", false, "job, triggered_at", "")
+		runs.AddIndex("idx_cron_runs_chat_status", false, "chat, status", "")
+		return app.Save(runs)
+	}, func(app core.App) error {
+		return nil
+	})
+}
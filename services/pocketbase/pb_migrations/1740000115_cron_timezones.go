@@ -0,0 +1,32 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// The user's IANA timezone, the default for the cron jobs they create.
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil { return err }
+		if f := users.Fields.GetByName("timezone"); f == nil {
+			users.Fields.Add(&core.TextField{Name: "timezone"})
+		}
+		if err := app.Save(users); err != nil { return err }
+
+		// The IANA timezone a cron job's expression is evaluated in.
+		cronJobs, err := app.FindCollectionByNameOrId("cron_jobs")
+		if err != nil { return err }
+		if f := cronJobs.Fields.GetByName("timezone"); f == nil {
+			cronJobs.Fields.Add(&core.TextField{Name: "timezone"})
+		}
+		if err := app.Save(cronJobs); err != nil { return err }
+
+		// Existing jobs were scheduled in the server's clock, which is UTC.
+		_, err = app.DB().NewQuery("UPDATE cron_jobs SET timezone = 'UTC' WHERE timezone = '' OR timezone IS NULL").Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
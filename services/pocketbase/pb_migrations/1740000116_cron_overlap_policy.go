@@ -0,0 +1,39 @@
+package pb_migrations
+
+import (
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// What a job does when it fires while its previous run, or the chat
+		// it posts into, is still busy.
+		cronJobs, err := app.FindCollectionByNameOrId("cron_jobs")
+		if err != nil { return err }
+		if f := cronJobs.Fields.GetByName("overlap_policy"); f == nil {
+			cronJobs.Fields.Add(&core.SelectField{Name: "overlap_policy", MaxSelect: 1, Values: []string{"skip", "queue", "new_chat"}})
+		}
+		if err := app.Save(cronJobs); err != nil { return err }
+
+		// Runs can wait behind a busy one, or be skipped.
+		runs, err := app.FindCollectionByNameOrId("cron_runs")
+		if err != nil { return err }
+		if status, ok := runs.Fields.GetByName("status").(*core.SelectField); ok {
+			for _, v := range []string{"queued", "skipped"} {
+				if !slices.Contains(status.Values, v) {
+					status.Values = append(status.Values, v)
+				}
+			}
+		}
+		if err := app.Save(runs); err != nil { return err }
+
+		// Jobs in "new" mode always started a fresh chat, so keep doing that.
+		_, err = app.DB().NewQuery("UPDATE cron_jobs SET overlap_policy = CASE WHEN session_mode = 'new' THEN 'new_chat' ELSE 'skip' END WHERE overlap_policy = '' OR overlap_policy IS NULL").Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,24 @@
+package pb_migrations
+
+import (
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	migrations.Register(func(app core.App) error {
+		// Runs started on demand rather than by the schedule.
+		runs, err := app.FindCollectionByNameOrId("cron_runs")
+		if err != nil { return err }
+		if trigger, ok := runs.Fields.GetByName("trigger").(*core.SelectField); ok {
+			if !slices.Contains(trigger.Values, "manual") {
+				trigger.Values = append(trigger.Values, "manual")
+			}
+		}
+		return app.Save(runs)
+	}, func(app core.App) error {
+		return nil
+	})
+}
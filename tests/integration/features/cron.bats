@@ -12,6 +12,7 @@
 # 6. Updating cron expression re-registers the job
 # 7. Unauthenticated requests are rejected
 # 8. last_executed and last_status are updated after execution
+# 9. Each execution is recorded in cron_runs
 
 load '../../helpers/auth.sh'
 load '../../helpers/cleanup.sh'
@@ -663,3 +664,106 @@ create_test_chat() {
 
     echo "✓ Disabled cron job was not registered with scheduler"
 }
+
+# =============================================================================
+# 9. Run History
+# =============================================================================
+
+@test "Cron Runs: a failed execution is recorded in cron_runs" {
+    authenticate_user
+
+    # session_mode=existing without a chat fails on every run
+    local response
+    response=$(create_cron_job "runs-$TEST_ID" "* * * * *" "Missing chat ref" "existing")
+    local record_id
+    record_id=$(echo "$response" | jq -r '.id // empty')
+    [ -n "$record_id" ] || { echo "❌ Create failed: $response" >&2; return 1; }
+
+    echo "  Waiting for the run to be recorded (up to 90s)..."
+    local attempts=0
+    local max_attempts=30
+
+    while [ $attempts -lt $max_attempts ]; do
+        sleep 3
+        local runs
+        runs=$(curl -s -G \
+            "$PB_URL/api/collections/cron_runs/records" \
+            --data-urlencode "filter=job='$record_id'" \
+            -H "Authorization: $USER_TOKEN")
+
+        local run_status
+        run_status=$(echo "$runs" | jq -r '.items[0].status // empty')
+        if [ "$run_status" = "failed" ]; then
+            local run_error
+            run_error=$(echo "$runs" | jq -r '.items[0].error // empty')
+            [ -n "$(echo "$runs" | jq -r '.items[0].finished_at // empty')" ] || {
+                echo "❌ Failed run has no finished_at" >&2
+                return 1
+            }
+            echo "✓ Failed run recorded (error: $run_error)"
+            return 0
+        fi
+        attempts=$((attempts + 1))
+    done
+
+    echo "❌ No failed run recorded within timeout" >&2
+    return 1
+}
+
+# =============================================================================
+# 10. Lifecycle
+# =============================================================================
+
+@test "Cron Lifecycle: a paused job can be run now and deleted" {
+    authenticate_user
+
+    # session_mode=existing without a chat fails as soon as it runs
+    local response
+    response=$(create_cron_job "lifecycle-$TEST_ID" "0 0 1 1 *" "Missing chat ref" "existing")
+    local record_id
+    record_id=$(echo "$response" | jq -r '.id // empty')
+    [ -n "$record_id" ] || { echo "❌ Create failed: $response" >&2; return 1; }
+
+    response=$(curl -s -X POST "$PB_URL/api/pocketcoder/pause_scheduled_task" \
+        -H "Content-Type: application/json" \
+        -H "Authorization: $USER_TOKEN" \
+        -d "{\"task_id\": \"$record_id\"}")
+    [ "$(echo "$response" | jq -r '.status // empty')" = "paused" ] || {
+        echo "❌ Pause failed: $response" >&2
+        return 1
+    }
+
+    # A manual run ignores the pause and is recorded as such
+    response=$(curl -s -X POST "$PB_URL/api/pocketcoder/run_scheduled_task" \
+        -H "Content-Type: application/json" \
+        -H "Authorization: $USER_TOKEN" \
+        -d "{\"task_id\": \"$record_id\"}")
+    [ "$(echo "$response" | jq -r '.run.trigger // empty')" = "manual" ] || {
+        echo "❌ Run was not recorded as manual: $response" >&2
+        return 1
+    }
+    [ "$(echo "$response" | jq -r '.run.status // empty')" = "failed" ] || {
+        echo "❌ Run without a chat should fail: $response" >&2
+        return 1
+    }
+    echo "✓ Paused job ran on demand"
+
+    response=$(curl -s -X POST "$PB_URL/api/pocketcoder/delete_scheduled_task" \
+        -H "Content-Type: application/json" \
+        -H "Authorization: $USER_TOKEN" \
+        -d "{\"task_id\": \"$record_id\"}")
+    [ "$(echo "$response" | jq -r '.status // empty')" = "deleted" ] || {
+        echo "❌ Delete failed: $response" >&2
+        return 1
+    }
+
+    local http_code
+    http_code=$(curl -s -o /dev/null -w "%{http_code}" \
+        "$PB_URL/api/collections/cron_jobs/records/$record_id" \
+        -H "Authorization: $USER_TOKEN")
+    [ "$http_code" = "404" ] || {
+        echo "❌ Deleted job is still readable (HTTP $http_code)" >&2
+        return 1
+    }
+    echo "✓ Job deleted"
+}